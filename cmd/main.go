@@ -2,21 +2,22 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"sync"
 	"syscall"
+	_ "time/tzdata" // The time zones of the users do not depend on the time zone database of the host
 
 	"log/slog"
 
-	_ "github.com/mattn/go-sqlite3" // Import the SQLite driver
 	"github.com/movax01h/kladovkin-telegram-bot/config"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/eventbus"
 	"github.com/movax01h/kladovkin-telegram-bot/internal/notifier"
 	"github.com/movax01h/kladovkin-telegram-bot/internal/parser"
-	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/sqlite"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/database"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/scheduler"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/supervisor"
 	"github.com/movax01h/kladovkin-telegram-bot/internal/telegram"
 	"github.com/movax01h/kladovkin-telegram-bot/pkg/logger"
 	"github.com/movax01h/kladovkin-telegram-bot/pkg/tools"
@@ -39,109 +40,165 @@ func main() {
 
 	// Initialize the database
 	db, err := initializeDatabase(cfg)
-	defer db.Close()
 	if err != nil {
 		slog.Error("failed to initialize database", "error", err)
 		os.Exit(1)
 	}
+	defer db.Close()
 
 	// Instantiate repositories
-	userRepo := sqlite.NewSQLiteUserRepository(db)
-	unitRepo := sqlite.NewSQLiteUnitRepository(db)
-	subscriptionRepo := sqlite.NewSQLiteSubscriptionRepository(db)
+	userRepo := db.Repositories.Users
+	unitRepo := db.Repositories.Units
+	subscriptionRepo := db.Repositories.Subscriptions
+	chatStateRepo := db.Repositories.ChatStates
+	channelRepo := db.Repositories.DeliveryChannels
+	jobStateRepo := db.Repositories.JobStates
 
 	// Initialize the Telegram bot, passing in the repositories
-	bot, err := telegram.NewBot(cfg.TelegramConfig, userRepo, unitRepo, subscriptionRepo)
+	client, err := telegram.NewClient(cfg.TelegramConfig)
 	if err != nil {
 		log.Fatalf("failed to initialize Telegram bot: %v", err)
 	}
+	bot := telegram.NewBot(
+		cfg.TelegramConfig, client, userRepo, unitRepo, subscriptionRepo, chatStateRepo, channelRepo, jobStateRepo,
+	)
 	slog.Info("Telegram bot initialized")
 
 	// Initialize the notification service
-	notificationService := notifier.NewNotifier(&cfg.NotifierConfig, userRepo, subscriptionRepo, bot)
-	slog.Info("Notifier service initialized")
-
-	// Initialize the parser
-	parserService := parser.NewParser(&cfg.ParserConfig, userRepo, unitRepo, subscriptionRepo)
+	channels := []notifier.Channel{
+		notifier.NewTelegramChannel(bot),
+		notifier.NewWebhookChannel(&cfg.WebhookConfig),
+	}
+	if cfg.EmailConfig.SMTPHost != "" {
+		email := notifier.NewEmailChannel(&cfg.EmailConfig)
+		channels = append(channels, email)
+		// The email addresses are confirmed with a code sent through the same SMTP server
+		bot.SetEmailVerifier(email)
+	}
+	notificationService := notifier.NewNotifier(
+		&cfg.NotifierConfig, userRepo, subscriptionRepo, db.Repositories.Notifications,
+		db.Repositories.Digests, db.Repositories.UnitAlerts, db.Transactor, channels...,
+	)
+	slog.Info("Notifier service initialized", "channels", len(channels))
+
+	// Hand the unit events over from the parser to the notifier
+	bus := eventbus.NewBus(&cfg.EventBusConfig, db.Repositories.UnitEvents)
+	bus.Subscribe(notificationService)
+
+	// Initialize the parser with the enabled sources
+	sources, err := parser.DefaultRegistry().Build(&cfg.ParserConfig)
+	if err != nil {
+		slog.Error("failed to initialize parser sources", "error", err)
+		os.Exit(1)
+	}
+	parserService := parser.NewParser(&cfg.ParserConfig, sources, db.Transactor)
+	parserService.AddEventHandler(bus)
+	parserService.SetAlerter(bot)
 	slog.Info("Parser service initialized")
 
-	// Create a context and wait group for goroutines
+	// Schedule the periodic jobs
+	jobScheduler, err := newScheduler(cfg, db, parserService, notificationService)
+	if err != nil {
+		slog.Error("failed to initialize the scheduler", "error", err)
+		os.Exit(1)
+	}
+
+	// Restart the failed services and report them to the admin
+	services := supervisor.NewSupervisor(&cfg.SupervisorConfig, bot)
+	bot.SetServiceMonitor(services)
+
+	// Create a context for the services
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var wg sync.WaitGroup
-
-	// Start the necessary goroutines (parser, notifier, bot, scheduler)
-	startAllRoutines(ctx, &wg, bot, notificationService, parserService)
+	// Start the supervised services (scheduler, event bus, notifier, bot)
+	startAllRoutines(ctx, services, bot, notificationService, jobScheduler, bus)
 
 	// Wait for shutdown signal
-	waitForShutdown(cancel, &wg)
+	waitForShutdown(cancel, services)
 
 	slog.Info("Shutting down application")
 }
 
-// initializeDatabase initializes the SQLite database connection and ensures that the necessary tables are created.
-func initializeDatabase(cfg *config.Config) (*sql.DB, error) {
-	// Ensure the data directory exists
-	if err := ensureDataDirectoryExists(cfg.DatabaseConfig.Path); err != nil {
-		return nil, err
-	}
-
-	// Initialize the SQLite database connection
-	db, err := sqlite.NewSQLiteDB(cfg.DatabaseConfig.Path)
+// initializeDatabase opens the database of the configured driver and applies the pending schema migrations.
+func initializeDatabase(cfg *config.Config) (*database.Database, error) {
+	db, err := database.Open(&cfg.DatabaseConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	// Ensure that the necessary tables are created
-	if err := sqlite.InitializeDatabase(db); err != nil {
+	// Bring the schema up to date
+	if err := db.Migrate(); err != nil {
+		_ = db.Close()
 		return nil, err
 	}
 
-	slog.Info("SQLite database initialized")
+	slog.Info("Database initialized", "driver", cfg.DatabaseConfig.Driver)
 	return db, nil
 }
 
-// ensureDataDirectoryExists checks if the directory for the database file exists
-func ensureDataDirectoryExists(path string) error {
-	dir := filepath.Dir(path)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return os.MkdirAll(dir, 0755)
+// newScheduler registers the periodic jobs with their configured schedules.
+// The database is backed up only if its driver supports it.
+func newScheduler(
+	cfg *config.Config, db *database.Database, p *parser.Parser, n *notifier.Notifier,
+) (*scheduler.Scheduler, error) {
+	type entry struct {
+		spec string
+		job  scheduler.Job
+	}
+	entries := []entry{
+		{cfg.SchedulerConfig.Parse, scheduler.Job{Name: "parse", Jitter: cfg.SchedulerConfig.ParseJitter, Run: p.Run}},
+		{cfg.SchedulerConfig.Digest, scheduler.Job{Name: "digest", Run: n.SendDigests}},
+		{cfg.SchedulerConfig.Cleanup, scheduler.Job{Name: "cleanup", Run: n.Cleanup}},
+	}
+	if db.CanBackup() {
+		backup := scheduler.Job{Name: "backup", Run: backupDatabase(cfg, db)}
+		entries = append(entries, entry{cfg.SchedulerConfig.Backup, backup})
 	}
-	return nil
-}
 
-// startAllRoutines starts all the necessary goroutines.
-func startAllRoutines(ctx context.Context, wg *sync.WaitGroup, b *telegram.Bot, n *notifier.Notifier, p *parser.Parser) {
-	startRoutine(ctx, wg, p.Start, "Error in parsing", b)
-	startRoutine(ctx, wg, n.Start, "Error in notification routine", b)
-	startRoutine(ctx, wg, b.Start, "Error in Telegram bot interaction", b)
+	s := scheduler.NewScheduler(db.Repositories.JobStates)
+	for _, e := range entries {
+		schedule, err := scheduler.ParseSchedule(e.spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule of the %s job: %w", e.job.Name, err)
+		}
+		e.job.Schedule = schedule
+		s.Add(e.job)
+	}
+	return s, nil
 }
 
-// startRoutine starts a new goroutine with the given routine function.
-func startRoutine(ctx context.Context, wg *sync.WaitGroup, routine func(ctx context.Context) error, errMsg string, bot *telegram.Bot) {
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := routine(ctx); err != nil {
-			slog.Error(errMsg, "error", err)
-			bot.SendErrorNotification("Error in routine: " + errMsg)
+// backupDatabase returns the backup job of the database.
+func backupDatabase(cfg *config.Config, db *database.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		path, err := db.Backup(ctx, cfg.DatabaseConfig.BackupDir, cfg.DatabaseConfig.BackupKeep)
+		if err != nil {
+			return err
 		}
-	}()
+		slog.Info("Database backed up", "path", path)
+		return nil
+	}
 }
 
-// waitForShutdown waits for a shutdown signal and cancels the context.
-func waitForShutdown(cancel context.CancelFunc, wg *sync.WaitGroup) {
+// startAllRoutines starts all the services under the supervisor.
+func startAllRoutines(
+	ctx context.Context, sup *supervisor.Supervisor,
+	b *telegram.Bot, n *notifier.Notifier, s *scheduler.Scheduler, e *eventbus.Bus,
+) {
+	sup.Go(ctx, "scheduler", s.Start)
+	sup.Go(ctx, "event bus", e.Start)
+	sup.Go(ctx, "notifier", n.Start)
+	sup.Go(ctx, "telegram bot", b.Start)
+}
+
+// waitForShutdown waits for a shutdown signal, cancels the context and waits for the services to stop.
+func waitForShutdown(cancel context.CancelFunc, sup *supervisor.Supervisor) {
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 
-	select {
-	case <-stopChan:
-		slog.Info("Received shutdown signal")
-		cancel()
-	case <-context.Background().Done():
-		slog.Info("Context cancelled")
-	}
+	<-stopChan
+	slog.Info("Received shutdown signal")
+	cancel()
 
-	wg.Wait()
+	sup.Wait()
 }
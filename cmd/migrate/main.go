@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/database"
+)
+
+const usage = `Usage: migrate [command]
+
+Commands:
+  up      Apply all pending migrations (default)
+  status  List the migrations and whether they are applied
+
+The database is selected with the DATABASE_DRIVER, DATABASE_PATH and DATABASE_URL environment variables.`
+
+func main() {
+	command := "up"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	if err := run(command); err != nil {
+		log.Fatalf("migrate %s: %s", command, err)
+	}
+}
+
+// run executes the migrate command against the configured database.
+func run(command string) error {
+	if command != "up" && command != "status" {
+		fmt.Fprintln(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	cfg, err := config.NewDatabaseConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if command == "up" {
+		if err := db.Migrate(); err != nil {
+			return err
+		}
+	}
+	return printStatus(db)
+}
+
+// printStatus prints every migration with the time it was applied.
+func printStatus(db *database.Database) error {
+	statuses, err := db.MigrationStatuses()
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d  %-28s %s\n", status.Version, status.Name, applied)
+	}
+	return nil
+}
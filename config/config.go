@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
@@ -15,36 +16,104 @@ type LoggerConfig struct {
 }
 
 type DatabaseConfig struct {
-	Path string `env:"DATABASE_PATH" envDefault:"./data/kladovkin.db"`
+	Driver     string `env:"DATABASE_DRIVER" envDefault:"sqlite"`             // One of sqlite, postgres, memory
+	Path       string `env:"DATABASE_PATH" envDefault:"./data/kladovkin.db"`  // SQLite database file
+	URL        string `env:"DATABASE_URL"`                                    // PostgreSQL connection URL
+	BackupDir  string `env:"DATABASE_BACKUP_DIR" envDefault:"./data/backups"` // Directory of the SQLite backups
+	BackupKeep int    `env:"DATABASE_BACKUP_KEEP" envDefault:"7"`             // Number of backups kept
 }
 
 type TelegramConfig struct {
-	BotToken string `env:"TELEGRAM_BOT_TOKEN,required"`
-	AdminID  int64  `env:"TELEGRAM_ADMIN_ID,required"`
+	BotToken      string `env:"TELEGRAM_BOT_TOKEN,required"`
+	AdminID       int64  `env:"TELEGRAM_ADMIN_ID,required"`
+	APIEndpoint   string `env:"TELEGRAM_API_ENDPOINT" envDefault:"https://api.telegram.org/bot%s/%s"` // Format taking the token and the method
+	RateLimit     int    `env:"TELEGRAM_RATE_LIMIT" envDefault:"30"`                                  // Messages per second across all chats
+	ChatRateLimit int    `env:"TELEGRAM_CHAT_RATE_LIMIT" envDefault:"20"`                             // Messages per minute to a single chat
 }
 
 type NotifierConfig struct {
-	Interval int64 `env:"NOTIFIER_INTERVAL" envDefault:"60"` // Interval in minutes
+	OutboxInterval  time.Duration `env:"NOTIFIER_OUTBOX_INTERVAL" envDefault:"30s"` // How often due notifications are sent
+	MaxAttempts     int           `env:"NOTIFIER_MAX_ATTEMPTS" envDefault:"5"`      // Attempts before giving up
+	RetryBackoff    time.Duration `env:"NOTIFIER_RETRY_BACKOFF" envDefault:"1m"`    // Doubled after every failed attempt
+	MaxRetryBackoff time.Duration `env:"NOTIFIER_MAX_RETRY_BACKOFF" envDefault:"1h"`
+	Retention       time.Duration `env:"NOTIFIER_RETENTION" envDefault:"720h"` // How long sent and given up notifications are kept
+	Lease           time.Duration `env:"NOTIFIER_LEASE" envDefault:"10m"`      // How long claimed notifications are held
+}
+
+type EmailConfig struct {
+	SMTPHost     string        `env:"SMTP_HOST"` // Optional, email delivery is disabled without it
+	SMTPPort     int           `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUsername string        `env:"SMTP_USERNAME"` // Optional, the server is used without authentication if empty
+	SMTPPassword string        `env:"SMTP_PASSWORD"`
+	From         string        `env:"SMTP_FROM"` // Sender address, required if SMTP_HOST is set
+	Timeout      time.Duration `env:"SMTP_TIMEOUT" envDefault:"30s"`
+}
+
+type WebhookConfig struct {
+	Timeout time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`
+}
+
+type EventBusConfig struct {
+	BufferSize      int           `env:"EVENT_BUS_BUFFER_SIZE" envDefault:"16"`   // Published batches held before publishing blocks
+	RetryBackoff    time.Duration `env:"EVENT_BUS_RETRY_BACKOFF" envDefault:"5s"` // Doubled after every failed delivery
+	MaxRetryBackoff time.Duration `env:"EVENT_BUS_MAX_RETRY_BACKOFF" envDefault:"5m"`
+	MaxAttempts     int           `env:"EVENT_BUS_MAX_ATTEMPTS" envDefault:"5"` // Deliveries of a batch before moving on
+}
+
+// SupervisorConfig holds the restart policy of the services.
+// A service failing more than MaxRestarts times within RestartWindow is given up on.
+type SupervisorConfig struct {
+	InitialBackoff time.Duration `env:"SUPERVISOR_INITIAL_BACKOFF" envDefault:"1s"` // Doubled after every failure of a service
+	MaxBackoff     time.Duration `env:"SUPERVISOR_MAX_BACKOFF" envDefault:"5m"`
+	MaxRestarts    int           `env:"SUPERVISOR_MAX_RESTARTS" envDefault:"10"` // Within the window before giving up, unlimited if zero
+	RestartWindow  time.Duration `env:"SUPERVISOR_RESTART_WINDOW" envDefault:"1h"`
+}
+
+// SchedulerConfig holds the schedules of the jobs, each either a cron expression like "30 3 * * *",
+// a shorthand like "@daily" or an interval like "@every 30m".
+type SchedulerConfig struct {
+	Parse       string        `env:"SCHEDULE_PARSE" envDefault:"@every 30m"`
+	ParseJitter time.Duration `env:"SCHEDULE_PARSE_JITTER" envDefault:"2m"` // Random delay added to every parser run
+	Digest      string        `env:"SCHEDULE_DIGEST" envDefault:"@every 1m"`
+	Cleanup     string        `env:"SCHEDULE_CLEANUP" envDefault:"0 4 * * *"`
+	Backup      string        `env:"SCHEDULE_BACKUP" envDefault:"30 3 * * *"` // SQLite only
 }
 
 type ParserConfig struct {
-	URL      string `env:"PARSER_URL,required" envDefault:"https://kladovkin.ru"`
-	Interval int64  `env:"PARSER_INTERVAL" envDefault:"30"` // Interval in minutes
+	URL     string   `env:"PARSER_URL,required" envDefault:"https://kladovkin.ru"`
+	Sources []string `env:"PARSER_SOURCES" envDefault:"kladovkin"` // Comma-separated list of enabled sources
+
+	Timeout         time.Duration `env:"PARSER_TIMEOUT" envDefault:"10s"` // Of a single request
+	Retries         int           `env:"PARSER_RETRIES" envDefault:"3"`   // Of a failed request, on top of the first attempt
+	RetryBackoff    time.Duration `env:"PARSER_RETRY_BACKOFF" envDefault:"1s"`
+	MaxRetryBackoff time.Duration `env:"PARSER_MAX_RETRY_BACKOFF" envDefault:"1m"` // Also bounds the wait asked by Retry-After
+
+	BreakerThreshold int           `env:"PARSER_BREAKER_THRESHOLD" envDefault:"5"` // Failed runs in a row pausing a source, never if zero
+	BreakerCooldown  time.Duration `env:"PARSER_BREAKER_COOLDOWN" envDefault:"30m"`
 }
 
 type Config struct {
-	Environment    string `env:"ENVIRONMENT,required"`
-	LoggerConfig   LoggerConfig
-	DatabaseConfig DatabaseConfig
-	NotifierConfig NotifierConfig
-	ParserConfig   ParserConfig
-	TelegramConfig TelegramConfig
+	Environment      string `env:"ENVIRONMENT,required"`
+	LoggerConfig     LoggerConfig
+	DatabaseConfig   DatabaseConfig
+	NotifierConfig   NotifierConfig
+	EmailConfig      EmailConfig
+	WebhookConfig    WebhookConfig
+	EventBusConfig   EventBusConfig
+	SchedulerConfig  SchedulerConfig
+	SupervisorConfig SupervisorConfig
+	ParserConfig     ParserConfig
+	TelegramConfig   TelegramConfig
 }
 
 const (
 	EnvDevelopment = "development"
 	EnvProduction  = "production"
 
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory" // Nothing is persisted, for local development
+
 	LogLevelDebug = "DEBUG"
 	LogLevelInfo  = "INFO"
 	LogLevelWarn  = "WARN"
@@ -67,6 +136,20 @@ func NewConfig() (*Config, error) {
 	return cfg, nil
 }
 
+// NewDatabaseConfig creates a database configuration instance by parsing and validating environment variables.
+// It is used by tools that only need the database, such as the migrate command.
+func NewDatabaseConfig() (*DatabaseConfig, error) {
+	var cfg DatabaseConfig
+	if err := env.Parse(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse the environment: %w", err)
+	}
+
+	if err := validateDatabaseConfig(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
 // parseConfig handles environment variable parsing and initial setup.
 func parseConfig() (*Config, error) {
 	var cfg Config
@@ -104,6 +187,44 @@ func validateConfig(cfg *Config) error {
 		}
 	}
 
+	if err := validateEmailConfig(&cfg.EmailConfig); err != nil {
+		return err
+	}
+
+	return validateDatabaseConfig(&cfg.DatabaseConfig)
+}
+
+// validateEmailConfig validates the SMTP settings if email delivery is enabled.
+func validateEmailConfig(cfg *EmailConfig) error {
+	if cfg.SMTPHost == "" {
+		return nil
+	}
+	if cfg.From == "" {
+		return fmt.Errorf("email delivery requires SMTP_FROM")
+	}
+	if cfg.SMTPPort <= 0 || cfg.SMTPPort > 65535 {
+		return fmt.Errorf("invalid SMTP port: %d", cfg.SMTPPort)
+	}
+	return nil
+}
+
+// validateDatabaseConfig validates the database driver and its settings.
+func validateDatabaseConfig(cfg *DatabaseConfig) error {
+	switch cfg.Driver {
+	case DriverSQLite:
+		if cfg.Path == "" {
+			return fmt.Errorf("the %s driver requires DATABASE_PATH", DriverSQLite)
+		}
+	case DriverPostgres:
+		if cfg.URL == "" {
+			return fmt.Errorf("the %s driver requires DATABASE_URL", DriverPostgres)
+		}
+	case DriverMemory:
+	default:
+		return fmt.Errorf("invalid database driver: %s possible values are: %s, %s, %s",
+			cfg.Driver, DriverSQLite, DriverPostgres, DriverMemory,
+		)
+	}
 	return nil
 }
 
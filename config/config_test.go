@@ -16,9 +16,9 @@ func TestConfig_ValidInput(t *testing.T) {
 	t.Setenv("DATABASE_PATH", "./data/telegram_bot.db")
 	t.Setenv("TELEGRAM_BOT_TOKEN", "dummy_token")
 	t.Setenv("TELEGRAM_ADMIN_ID", "123456")
-	t.Setenv("NOTIFIER_INTERVAL", "10")
 	t.Setenv("PARSER_URL", "https://kladovkin.ru/")
-	t.Setenv("PARSER_INTERVAL", "10")
+	t.Setenv("SCHEDULE_PARSE", "*/10 * * * *")
+	t.Setenv("PARSER_SOURCES", "kladovkin,other")
 
 	cfg, err := NewConfig()
 	require.NoError(t, err)
@@ -28,9 +28,34 @@ func TestConfig_ValidInput(t *testing.T) {
 	assert.Equal(t, "./data/telegram_bot.db", cfg.DatabaseConfig.Path)
 	assert.Equal(t, "dummy_token", cfg.TelegramConfig.BotToken)
 	assert.Equal(t, int64(123456), cfg.TelegramConfig.AdminID)
-	assert.Equal(t, int64(10), cfg.NotifierConfig.Interval)
 	assert.Equal(t, "https://kladovkin.ru/", cfg.ParserConfig.URL)
-	assert.Equal(t, int64(10), cfg.ParserConfig.Interval)
+	assert.Equal(t, "*/10 * * * *", cfg.SchedulerConfig.Parse)
+	assert.Equal(t, "@every 1m", cfg.SchedulerConfig.Digest)
+	assert.Equal(t, []string{"kladovkin", "other"}, cfg.ParserConfig.Sources)
+}
+
+func TestNewDatabaseConfig(t *testing.T) {
+	t.Run("should use SQLite at the default path", func(t *testing.T) {
+		cfg, err := NewDatabaseConfig()
+		require.NoError(t, err)
+		assert.Equal(t, DriverSQLite, cfg.Driver)
+		assert.Equal(t, "./data/kladovkin.db", cfg.Path)
+	})
+
+	t.Run("should not require the other settings", func(t *testing.T) {
+		t.Setenv("DATABASE_PATH", "./data/other.db")
+
+		cfg, err := NewDatabaseConfig()
+		require.NoError(t, err)
+		assert.Equal(t, "./data/other.db", cfg.Path)
+	})
+
+	t.Run("should require the URL of PostgreSQL", func(t *testing.T) {
+		t.Setenv("DATABASE_DRIVER", DriverPostgres)
+
+		_, err := NewDatabaseConfig()
+		assert.Error(t, err)
+	})
 }
 
 func TestValidateConfig(t *testing.T) {
@@ -49,6 +74,10 @@ func TestValidateConfig(t *testing.T) {
 					Level:    slog.LevelDebug,
 					FilePath: filepath.Join(tempDir, "logfile.log"),
 				},
+				DatabaseConfig: DatabaseConfig{
+					Driver: DriverSQLite,
+					Path:   filepath.Join(tempDir, "kladovkin.db"),
+				},
 				TelegramConfig: TelegramConfig{
 					BotToken: "dummy_token",
 					AdminID:  123456,
@@ -56,6 +85,56 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expectedErr: false,
 		},
+		{
+			name: "postgres configuration",
+			cfg: &Config{
+				Environment:  "development",
+				LoggerConfig: LoggerConfig{Level: slog.LevelDebug},
+				DatabaseConfig: DatabaseConfig{
+					Driver: DriverPostgres,
+					URL:    "postgres://bot@localhost:5432/kladovkin?sslmode=disable",
+				},
+			},
+			expectedErr: false,
+		},
+		{
+			name: "postgres without URL",
+			cfg: &Config{
+				Environment:    "development",
+				LoggerConfig:   LoggerConfig{Level: slog.LevelDebug},
+				DatabaseConfig: DatabaseConfig{Driver: DriverPostgres},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "invalid database driver",
+			cfg: &Config{
+				Environment:    "development",
+				LoggerConfig:   LoggerConfig{Level: slog.LevelDebug},
+				DatabaseConfig: DatabaseConfig{Driver: "mysql", Path: "./data/kladovkin.db"},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "email configuration",
+			cfg: &Config{
+				Environment:    "development",
+				LoggerConfig:   LoggerConfig{Level: slog.LevelDebug},
+				DatabaseConfig: DatabaseConfig{Driver: DriverMemory},
+				EmailConfig:    EmailConfig{SMTPHost: "smtp.example.com", SMTPPort: 587, From: "bot@example.com"},
+			},
+			expectedErr: false,
+		},
+		{
+			name: "email without sender",
+			cfg: &Config{
+				Environment:    "development",
+				LoggerConfig:   LoggerConfig{Level: slog.LevelDebug},
+				DatabaseConfig: DatabaseConfig{Driver: DriverMemory},
+				EmailConfig:    EmailConfig{SMTPHost: "smtp.example.com", SMTPPort: 587},
+			},
+			expectedErr: true,
+		},
 		{
 			name: "invalid environment",
 			cfg: &Config{
@@ -0,0 +1,171 @@
+// Package eventbus hands the unit events detected by the parser over to their handlers within the process.
+// The parser stores the events together with the units and publishes them after the commit. The bus delivers them
+// right away and deletes them once every handler succeeded. The events a stopped process left stored, or the bus gave
+// up on after repeated failures, are delivered again when the bus starts, so every event is handled at least once.
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+// replayBatchSize bounds the number of stored events loaded at once when the bus starts.
+const replayBatchSize = 500
+
+// Handler receives the unit events published on the bus.
+// An event may be handled more than once, e.g. when the process stopped before the event was deleted.
+type Handler interface {
+	HandleUnitEvents(ctx context.Context, events []m.UnitEvent) error
+}
+
+// Bus delivers the published unit events to the subscribed handlers.
+// The published batches are buffered up to the configured size, publishing blocks while the buffer is full.
+type Bus struct {
+	cfg      *config.EventBusConfig
+	repo     repository.UnitEventRepository
+	handlers []Handler
+	queue    chan []m.UnitEvent
+}
+
+// NewBus creates a new Bus instance deleting the delivered events from the repository.
+func NewBus(cfg *config.EventBusConfig, repo repository.UnitEventRepository) *Bus {
+	return &Bus{
+		cfg:   cfg,
+		repo:  repo,
+		queue: make(chan []m.UnitEvent, max(cfg.BufferSize, 1)),
+	}
+}
+
+// Subscribe registers a handler that receives every published batch of events.
+// Handlers must be subscribed before the bus is started.
+func (b *Bus) Subscribe(h Handler) {
+	b.handlers = append(b.handlers, h)
+}
+
+// HandleUnitEvents publishes the events, so the bus can be registered as an event handler of the parser.
+func (b *Bus) HandleUnitEvents(ctx context.Context, events []m.UnitEvent) error {
+	return b.Publish(ctx, events)
+}
+
+// Publish queues a batch of stored events for delivery. It blocks while the buffer is full, so the publisher
+// does not outrun the handlers, and gives up when the context is done. The events stay stored either way.
+func (b *Bus) Publish(ctx context.Context, events []m.UnitEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	select {
+	case b.queue <- events:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Start delivers the events left stored by a previous run, then the published ones until the context is done.
+func (b *Bus) Start(ctx context.Context) error {
+	slog.Info("Event bus started")
+	replayedID, err := b.replay(ctx)
+	if err != nil {
+		slog.Error("Failed to replay stored unit events", "error", err)
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Event bus shutting down")
+			return nil
+		case events := <-b.queue:
+			// The events published while replaying may have been replayed already
+			if events = skipReplayed(events, replayedID); len(events) > 0 {
+				b.deliver(ctx, events)
+			}
+		}
+	}
+}
+
+// replay delivers the stored events in batches, the oldest first, and returns the ID of the last one.
+func (b *Bus) replay(ctx context.Context) (int64, error) {
+	var afterID int64
+	for {
+		events, err := b.repo.GetUnitEvents(ctx, afterID, replayBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return afterID, nil
+			}
+			return afterID, fmt.Errorf("failed to load stored unit events: %w", err)
+		}
+		if len(events) == 0 {
+			return afterID, nil
+		}
+
+		slog.Info("Replaying stored unit events", "count", len(events))
+		b.deliver(ctx, events)
+		afterID = events[len(events)-1].ID
+		if len(events) < replayBatchSize {
+			return afterID, nil
+		}
+	}
+}
+
+// skipReplayed returns the events that were not stored yet when they were replayed.
+func skipReplayed(events []m.UnitEvent, replayedID int64) []m.UnitEvent {
+	fresh := make([]m.UnitEvent, 0, len(events))
+	for _, event := range events {
+		if event.ID == 0 || event.ID > replayedID {
+			fresh = append(fresh, event)
+		}
+	}
+	return fresh
+}
+
+// deliver hands the events to every handler and deletes them once all handlers succeeded.
+// A failing handler gets the events again after an exponential backoff, up to the configured number of attempts.
+// Then the bus moves on, so a handler that keeps failing does not hold back the later events. The events given up on,
+// like those left when the context is done first, stay stored and are delivered again at the next start.
+func (b *Bus) deliver(ctx context.Context, events []m.UnitEvent) {
+	pending := b.handlers
+	backoff := b.cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		var failed []Handler
+		for _, h := range pending {
+			if err := h.HandleUnitEvents(ctx, events); err != nil {
+				slog.Error("Failed to handle unit events", "count", len(events), "attempt", attempt, "error", err)
+				failed = append(failed, h)
+			}
+		}
+		if len(failed) == 0 {
+			break
+		}
+		if b.cfg.MaxAttempts > 0 && attempt >= b.cfg.MaxAttempts {
+			slog.Error("Giving up on unit events, they stay stored until the next start",
+				"count", len(events), "firstID", events[0].ID, "lastID", events[len(events)-1].ID, "attempts", attempt)
+			return
+		}
+
+		pending = failed
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, b.cfg.MaxRetryBackoff)
+	}
+
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		if event.ID != 0 {
+			ids = append(ids, event.ID)
+		}
+	}
+	if err := b.repo.DeleteUnitEvents(ctx, ids); err != nil {
+		slog.Error("Failed to delete handled unit events", "count", len(ids), "error", err)
+	}
+}
@@ -0,0 +1,219 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/memory"
+)
+
+// busConfig retries failed deliveries right away, so the tests do not wait, and gives up after the third attempt.
+var busConfig = &config.EventBusConfig{
+	BufferSize: 1, RetryBackoff: time.Millisecond, MaxRetryBackoff: time.Millisecond, MaxAttempts: 3,
+}
+
+// recordingHandler is a Handler keeping the events it receives, failing the first deliveries if asked to.
+type recordingHandler struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	events   []m.UnitEvent
+	received chan struct{}
+}
+
+func newRecordingHandler(failures int) *recordingHandler {
+	return &recordingHandler{failures: failures, received: make(chan struct{}, 10)}
+}
+
+func (h *recordingHandler) HandleUnitEvents(_ context.Context, events []m.UnitEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.calls++
+	if h.failures > 0 {
+		h.failures--
+		return errors.New("handler failed")
+	}
+	h.events = append(h.events, events...)
+	h.received <- struct{}{}
+	return nil
+}
+
+// wait waits for a successful delivery to the handler.
+func (h *recordingHandler) wait(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-h.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no events were delivered in time")
+	}
+}
+
+// storeEvents stores unit events of the given types like the parser does.
+func storeEvents(t *testing.T, repo repository.UnitEventRepository, types ...m.UnitEventType) []m.UnitEvent {
+	t.Helper()
+
+	events := make([]m.UnitEvent, len(types))
+	for i, eventType := range types {
+		events[i] = m.UnitEvent{Type: eventType, Unit: m.Unit{ID: int64(i + 1)}, DetectedAt: time.Now()}
+	}
+	require.NoError(t, repo.AddUnitEvents(context.Background(), events))
+	return events
+}
+
+// startBus runs the bus until the test finishes.
+func startBus(t *testing.T, bus *Bus) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitStored waits until the number of stored events drops to the expected one.
+func waitStored(t *testing.T, repo repository.UnitEventRepository, expected int) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		events, err := repo.GetUnitEvents(context.Background(), 0, 100)
+		return err == nil && len(events) == expected
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should deliver published events to every handler and delete them", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().UnitEvents
+		first, second := newRecordingHandler(0), newRecordingHandler(0)
+		bus := NewBus(busConfig, repo)
+		bus.Subscribe(first)
+		bus.Subscribe(second)
+		startBus(t, bus)
+		events := storeEvents(t, repo, m.UnitEventNew, m.UnitEventAvailable)
+
+		// Act
+		err := bus.Publish(ctx, events)
+
+		// Assert
+		require.NoError(t, err)
+		first.wait(t)
+		second.wait(t)
+		assert.Equal(t, events, first.events)
+		assert.Equal(t, events, second.events)
+		waitStored(t, repo, 0)
+	})
+
+	t.Run("should deliver the events left stored when it starts", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().UnitEvents
+		events := storeEvents(t, repo, m.UnitEventAvailable)
+		handler := newRecordingHandler(0)
+		bus := NewBus(busConfig, repo)
+		bus.Subscribe(handler)
+
+		// Act
+		startBus(t, bus)
+
+		// Assert
+		handler.wait(t)
+		assert.Equal(t, events, handler.events)
+		waitStored(t, repo, 0)
+	})
+
+	t.Run("should retry only the failing handler and keep the events until it succeeds", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().UnitEvents
+		working, failing := newRecordingHandler(0), newRecordingHandler(2)
+		bus := NewBus(busConfig, repo)
+		bus.Subscribe(working)
+		bus.Subscribe(failing)
+		events := storeEvents(t, repo, m.UnitEventAvailable)
+
+		// Act
+		bus.deliver(ctx, events)
+
+		// Assert
+		assert.Equal(t, 1, working.calls, "the handler that succeeded does not get the events again")
+		assert.Equal(t, 3, failing.calls)
+		assert.Equal(t, events, failing.events)
+		waitStored(t, repo, 0)
+	})
+
+	t.Run("should move on to the next events when a handler keeps failing", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().UnitEvents
+		handler := newRecordingHandler(busConfig.MaxAttempts)
+		bus := NewBus(busConfig, repo)
+		bus.Subscribe(handler)
+		poisoned := storeEvents(t, repo, m.UnitEventAvailable)
+		startBus(t, bus)
+		assert.Eventually(t, func() bool {
+			handler.mu.Lock()
+			defer handler.mu.Unlock()
+			return handler.calls == busConfig.MaxAttempts
+		}, 5*time.Second, time.Millisecond)
+		later := storeEvents(t, repo, m.UnitEventAvailable)
+
+		// Act
+		err := bus.Publish(ctx, later)
+
+		// Assert
+		require.NoError(t, err)
+		handler.wait(t)
+		assert.Equal(t, later, handler.events)
+		waitStored(t, repo, 1)
+		stored, err := repo.GetUnitEvents(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, poisoned[0].ID, stored[0].ID, "the events given up on stay stored for the next start")
+	})
+
+	t.Run("should keep the events stored when delivering is cancelled", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().UnitEvents
+		bus := NewBus(busConfig, repo)
+		bus.Subscribe(newRecordingHandler(1))
+		events := storeEvents(t, repo, m.UnitEventAvailable)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// Act
+		bus.deliver(cancelled, events)
+
+		// Assert
+		waitStored(t, repo, 1)
+	})
+
+	t.Run("should block publishing while the buffer is full", func(t *testing.T) {
+		// Arrange
+		bus := NewBus(busConfig, memory.NewStore().Repositories().UnitEvents)
+		event := m.UnitEvent{Type: m.UnitEventAvailable}
+		require.NoError(t, bus.Publish(ctx, []m.UnitEvent{event}))
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		// Act
+		err := bus.Publish(timeout, []m.UnitEvent{event})
+
+		// Assert
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
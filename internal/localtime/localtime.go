@@ -0,0 +1,122 @@
+// Package localtime works with the time of day of the users in their own time zones.
+// A time of day is stored as the minutes after midnight.
+package localtime
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// DefaultTimeZone is the time zone of the users who did not choose one.
+const DefaultTimeZone = "Europe/Moscow"
+
+// MinutesPerDay is the number of minutes in a day, the times of day are below it.
+const MinutesPerDay = 24 * 60
+
+// moscow stands in for the default time zone if the time zone database is not available.
+var moscow = time.FixedZone("MSK", 3*60*60)
+
+// Location returns the time zone with the IANA name, the default time zone for an empty or unknown name.
+func Location(name string) *time.Location {
+	if name == "" {
+		name = DefaultTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err == nil {
+		return loc
+	}
+
+	slog.Warn("Unknown time zone, using the default", "timeZone", name, "error", err)
+	if loc, err := time.LoadLocation(DefaultTimeZone); err == nil {
+		return loc
+	}
+	return moscow
+}
+
+// UserLocation returns the time zone of the user.
+func UserLocation(user *m.User) *time.Location {
+	return Location(user.TimeZone)
+}
+
+// QuietUntil returns the end of the quiet hours of the user if t falls inside them.
+// The quiet hours may span midnight, e.g. from 23:00 to 08:00. Equal start and end mean no quiet hours.
+func QuietUntil(user *m.User, t time.Time) (time.Time, bool) {
+	start, end := user.QuietHoursStart, user.QuietHoursEnd
+	if start == end {
+		return time.Time{}, false
+	}
+
+	local := t.In(UserLocation(user))
+	minute := local.Hour()*60 + local.Minute()
+	var quiet bool
+	if start < end {
+		quiet = minute >= start && minute < end
+	} else {
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+	return Next(local, end), true
+}
+
+// Next returns the first time after t at the time of day in the time zone of t.
+func Next(t time.Time, minutes int) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), minutes/60, minutes%60, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, minutes/60, minutes%60, 0, 0, t.Location())
+	}
+	return next
+}
+
+// NextWeekly returns the first time after t on the weekday at the time of day in the time zone of t.
+func NextWeekly(t time.Time, weekday time.Weekday, minutes int) time.Time {
+	days := (int(weekday) - int(t.Weekday()) + 7) % 7
+	next := time.Date(t.Year(), t.Month(), t.Day()+days, minutes/60, minutes%60, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+days+7, minutes/60, minutes%60, 0, 0, t.Location())
+	}
+	return next
+}
+
+// FormatClock formats a time of day as HH:MM.
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// ParseClock parses a time of day written as HH:MM or H:MM.
+func ParseClock(s string) (int, error) {
+	hours, mins, found := strings.Cut(strings.TrimSpace(s), ":")
+	if !found {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("invalid hour in %q", s)
+	}
+	mm, err := strconv.Atoi(mins)
+	if err != nil || len(mins) != 2 || mm < 0 || mm > 59 {
+		return 0, fmt.Errorf("invalid minute in %q", s)
+	}
+	return h*60 + mm, nil
+}
+
+// FormatOffset formats the UTC offset of the time zone at t, e.g. UTC+3 or UTC+5:30.
+func FormatOffset(loc *time.Location, t time.Time) string {
+	_, offset := t.In(loc).Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	hours, minutes := offset/3600, offset%3600/60
+	if minutes != 0 {
+		return fmt.Sprintf("UTC%s%d:%02d", sign, hours, minutes)
+	}
+	return fmt.Sprintf("UTC%s%d", sign, hours)
+}
@@ -0,0 +1,120 @@
+package localtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+func TestQuietUntil(t *testing.T) {
+	yekaterinburg, err := time.LoadLocation("Asia/Yekaterinburg")
+	require.NoError(t, err)
+	overnight := &m.User{TimeZone: "Asia/Yekaterinburg", QuietHoursStart: 23 * 60, QuietHoursEnd: 8 * 60}
+	daytime := &m.User{TimeZone: "Asia/Yekaterinburg", QuietHoursStart: 13 * 60, QuietHoursEnd: 14*60 + 30}
+
+	tests := []struct {
+		name          string
+		user          *m.User
+		at            time.Time
+		expectedQuiet bool
+		expectedUntil time.Time
+	}{
+		{"before overnight", overnight, time.Date(2024, 6, 1, 22, 59, 0, 0, yekaterinburg), false, time.Time{}},
+		{"overnight before midnight", overnight, time.Date(2024, 6, 1, 23, 0, 0, 0, yekaterinburg), true,
+			time.Date(2024, 6, 2, 8, 0, 0, 0, yekaterinburg)},
+		{"overnight after midnight", overnight, time.Date(2024, 6, 2, 3, 0, 0, 0, yekaterinburg), true,
+			time.Date(2024, 6, 2, 8, 0, 0, 0, yekaterinburg)},
+		{"overnight end", overnight, time.Date(2024, 6, 2, 8, 0, 0, 0, yekaterinburg), false, time.Time{}},
+		{"daytime", daytime, time.Date(2024, 6, 1, 14, 0, 0, 0, yekaterinburg), true,
+			time.Date(2024, 6, 1, 14, 30, 0, 0, yekaterinburg)},
+		{"other time zone", overnight, time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC), true,
+			time.Date(2024, 6, 2, 8, 0, 0, 0, yekaterinburg)},
+		{"no quiet hours", &m.User{}, time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC), false, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			until, quiet := QuietUntil(tt.user, tt.at)
+
+			assert.Equal(t, tt.expectedQuiet, quiet)
+			assert.True(t, tt.expectedUntil.Equal(until), "until %s, want %s", until, tt.expectedUntil)
+		})
+	}
+}
+
+func TestNextWeekly(t *testing.T) {
+	moscow := Location(DefaultTimeZone)
+	monday := time.Date(2024, 6, 3, 10, 0, 0, 0, moscow)
+
+	tests := []struct {
+		name     string
+		weekday  time.Weekday
+		minutes  int
+		expected time.Time
+	}{
+		{"later today", time.Monday, 18 * 60, time.Date(2024, 6, 3, 18, 0, 0, 0, moscow)},
+		{"earlier today", time.Monday, 9 * 60, time.Date(2024, 6, 10, 9, 0, 0, 0, moscow)},
+		{"now", time.Monday, 10 * 60, time.Date(2024, 6, 10, 10, 0, 0, 0, moscow)},
+		{"later this week", time.Friday, 9 * 60, time.Date(2024, 6, 7, 9, 0, 0, 0, moscow)},
+		{"end of the week", time.Sunday, 9 * 60, time.Date(2024, 6, 9, 9, 0, 0, 0, moscow)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := NextWeekly(monday, tt.weekday, tt.minutes)
+
+			assert.True(t, tt.expected.Equal(next), "next %s, want %s", next, tt.expected)
+		})
+	}
+}
+
+func TestLocation(t *testing.T) {
+	assert.Equal(t, DefaultTimeZone, Location("").String())
+	assert.Equal(t, DefaultTimeZone, Location("Mars/Olympus_Mons").String())
+	assert.Equal(t, "Asia/Vladivostok", Location("Asia/Vladivostok").String())
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+		hasError bool
+	}{
+		{"23:00", 23 * 60, false},
+		{"7:30", 7*60 + 30, false},
+		{"00:00", 0, false},
+		{"24:00", 0, true},
+		{"12:60", 0, true},
+		{"12:5", 0, true},
+		{"noon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			minutes, err := ParseClock(tt.input)
+			if tt.hasError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, minutes)
+			}
+		})
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	assert.Equal(t, "07:05", FormatClock(7*60+5))
+	assert.Equal(t, "23:00", FormatClock(23*60))
+}
+
+func TestFormatOffset(t *testing.T) {
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "UTC+3", FormatOffset(Location("Europe/Moscow"), at))
+	assert.Equal(t, "UTC+5:30", FormatOffset(Location("Asia/Kolkata"), at))
+	assert.Equal(t, "UTC-4", FormatOffset(Location("America/New_York"), at))
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ChatStep is a step of the subscription wizard a chat is in.
+type ChatStep string
+
+const (
+	// ChatStepCity means the chat is asked to select a city.
+	ChatStepCity ChatStep = "city"
+	// ChatStepStorage means the chat is asked to select a storage in the selected city.
+	ChatStepStorage ChatStep = "storage"
+	// ChatStepUnitSize means the chat is asked to select a unit size in the selected storage.
+	ChatStepUnitSize ChatStep = "unit_size"
+	// ChatStepConfirm means the chat is asked to confirm the subscription.
+	ChatStepConfirm ChatStep = "confirm"
+	// ChatStepMaxPrice means the chat is asked to send the maximum price of the subscription.
+	ChatStepMaxPrice ChatStep = "max_price"
+)
+
+// ChatState holds the progress of a chat through the subscription wizard.
+type ChatState struct {
+	ChatID         int64     `json:"chat_id"`
+	Step           ChatStep  `json:"step"`
+	City           string    `json:"city"`
+	Provider       string    `json:"provider"` // The provider of the selected storage
+	Storage        string    `json:"storage"`
+	UnitSize       string    `json:"unit_size"`
+	SubscriptionID int64     `json:"subscription_id"` // The subscription being edited, zero for a new one
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// ChannelType is a way of delivering notifications to a user.
+type ChannelType string
+
+const (
+	// ChannelTelegram delivers notifications to the Telegram chat of the user.
+	ChannelTelegram ChannelType = "telegram"
+	// ChannelEmail delivers notifications by email.
+	ChannelEmail ChannelType = "email"
+	// ChannelWebhook posts notifications to an HTTP endpoint of the user.
+	ChannelWebhook ChannelType = "webhook"
+)
+
+// ChannelTypes lists the channel types in the order they are shown to users.
+var ChannelTypes = []ChannelType{ChannelTelegram, ChannelEmail, ChannelWebhook}
+
+// DeliveryChannel is a channel a user has set up for receiving notifications.
+// A user without any delivery channel receives the notifications in Telegram.
+type DeliveryChannel struct {
+	UserID           int64       `json:"user_id"`
+	Type             ChannelType `json:"type"`
+	Address          string      `json:"address"` // The email address or the webhook URL, empty for Telegram
+	Enabled          bool        `json:"enabled"`
+	VerificationCode string      `json:"-"` // Sent back by the user to confirm the email address, empty once confirmed
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// Confirmed reports whether the user has confirmed the address of the channel.
+// Notifications are delivered only through confirmed channels.
+func (c *DeliveryChannel) Confirmed() bool {
+	return c.VerificationCode == ""
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DigestItem is a unit collected for the digest of a user, which is sent at DueAt.
+// The items of a user due at the same time are sent together as one digest.
+type DigestItem struct {
+	ID             int64     `json:"id"`
+	UserID         int64     `json:"user_id"`
+	SubscriptionID int64     `json:"subscription_id"`
+	Unit           Unit      `json:"unit"`           // The unit as it was when it matched
+	PreviousPrice  float64   `json:"previous_price"` // The price before a price drop, zero if the unit became available
+	DueAt          time.Time `json:"due_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// UnitEventType describes what changed about a unit between two parser runs.
+type UnitEventType string
+
+const (
+	// UnitEventNew is emitted for a unit that was not known before.
+	UnitEventNew UnitEventType = "new"
+	// UnitEventRemoved is emitted for a known unit that is no longer listed by its provider.
+	UnitEventRemoved UnitEventType = "removed"
+	// UnitEventAvailable is emitted when a known unit becomes available.
+	UnitEventAvailable UnitEventType = "available"
+	// UnitEventUnavailable is emitted when a known unit becomes unavailable.
+	UnitEventUnavailable UnitEventType = "unavailable"
+	// UnitEventPriceChanged is emitted when the price of a known unit changes.
+	UnitEventPriceChanged UnitEventType = "price_changed"
+)
+
+// UnitEvent represents a change of a unit detected by the parser.
+type UnitEvent struct {
+	ID         int64         `json:"-"` // Assigned when the event is stored for the hand-over to the notifier
+	Type       UnitEventType `json:"type"`
+	Unit       Unit          `json:"unit"`     // The current state, or the last known state for a removed unit
+	Previous   *Unit         `json:"previous"` // The state before the change, nil for a new unit
+	DetectedAt time.Time     `json:"detected_at"`
+}
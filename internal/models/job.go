@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// JobState is the run history of a scheduled job, kept across restarts.
+type JobState struct {
+	Name         string        `json:"name"`
+	LastRunAt    *time.Time    `json:"last_run_at"` // The start of the last finished run, nil before the first run
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error"` // Empty if the last run succeeded
+	NextRunAt    time.Time     `json:"next_run_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
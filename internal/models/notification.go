@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// NotificationStatus is the delivery status of a notification in the outbox.
+type NotificationStatus string
+
+const (
+	// NotificationStatusPending means the notification was not attempted yet.
+	NotificationStatusPending NotificationStatus = "pending"
+	// NotificationStatusSent means the notification was delivered.
+	NotificationStatusSent NotificationStatus = "sent"
+	// NotificationStatusFailed means the last attempt failed and the notification is retried at NextAttemptAt.
+	NotificationStatusFailed NotificationStatus = "failed"
+	// NotificationStatusDead means the notification is given up on after too many failed attempts.
+	NotificationStatusDead NotificationStatus = "dead"
+)
+
+// Notification is an alert waiting in the outbox to be delivered to a user through one channel.
+// The idempotency key identifies the alert across channels: the subscription, the unit and the event it is about.
+// An alert is stored once per channel, so enqueuing it again, e.g. after a restart, has no effect.
+type Notification struct {
+	ID             int64              `json:"id"`
+	IdempotencyKey string             `json:"idempotency_key"`
+	UserID         int64              `json:"user_id"`
+	SubscriptionID int64              `json:"subscription_id"`
+	UnitID         int64              `json:"unit_id"`
+	Channel        ChannelType        `json:"channel"`
+	Address        string             `json:"address"` // The address of the user in the channel, empty for Telegram
+	Subject        string             `json:"subject"`
+	Text           string             `json:"text"`
+	Unit           Unit               `json:"unit"` // The unit at the time of the alert
+	Status         NotificationStatus `json:"status"`
+	Attempts       int                `json:"attempts"`
+	LastError      string             `json:"last_error"`
+	NextAttemptAt  time.Time          `json:"next_attempt_at"`
+	SentAt         *time.Time         `json:"sent_at"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
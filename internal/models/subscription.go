@@ -2,14 +2,40 @@ package models
 
 import "time"
 
+// Subscription statuses.
+const (
+	SubscriptionStatusActive = "active"
+	SubscriptionStatusPaused = "paused"
+	// SubscriptionStatusSuspended is a subscription paused by the bot while its user cannot be reached.
+	// It is resumed when the user comes back, unlike a subscription the user paused.
+	SubscriptionStatusSuspended = "suspended"
+)
+
+// Delivery modes of a subscription.
+const (
+	DeliveryModeInstant = "instant" // Every matching unit is sent right away
+	DeliveryModeDaily   = "daily"   // The matching units are collected and sent once a day
+	DeliveryModeWeekly  = "weekly"  // The matching units are collected and sent once a week
+)
+
 // Subscription represents a user's subscription to a unit.
+// A digest is sent at DigestTime, minutes after midnight in the time zone of the user,
+// and a weekly digest on DigestWeekday.
+// MaxPrice and MinPriceDrop are the price rules: the units above the maximum price are not announced,
+// and a price falling to the maximum or by at least MinPriceDrop percent is announced. Zero turns a rule off.
 type Subscription struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	City      string    `json:"city"`
-	Storage   string    `json:"storage"`
-	UnitSize  string    `json:"unit_size"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int64        `json:"id"`
+	UserID        int64        `json:"user_id"`
+	Provider      string       `json:"provider"` // Empty provider matches units of any provider
+	City          string       `json:"city"`
+	Storage       string       `json:"storage"`
+	UnitSize      string       `json:"unit_size"`
+	Status        string       `json:"status"`
+	DeliveryMode  string       `json:"delivery_mode"` // Empty for subscriptions created before the modes, which are instant
+	DigestTime    int          `json:"digest_time"`
+	DigestWeekday time.Weekday `json:"digest_weekday"`
+	MaxPrice      float64      `json:"max_price"`      // Rubles per month
+	MinPriceDrop  int          `json:"min_price_drop"` // Percent
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
 }
@@ -3,15 +3,26 @@ package models
 import "time"
 
 // Unit represents a storage unit.
+// A unit is identified by its provider and the identifier the provider assigned to it.
 type Unit struct {
 	ID          int64     `json:"id"`
+	Provider    string    `json:"provider"`
+	ExternalID  string    `json:"external_id"`
 	Name        string    `json:"name"`
 	City        string    `json:"city"`
+	Storage     string    `json:"storage"`
 	Size        string    `json:"size"`
 	Dimension   string    `json:"dimension"`
 	Price       float64   `json:"price"`
 	Available   bool      `json:"available"`
 	Description string    `json:"description"`
+	URL         string    `json:"url"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// Storage is a storage of a provider, the units of a subscription are picked from.
+type Storage struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
+}
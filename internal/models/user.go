@@ -3,13 +3,19 @@ package models
 import "time"
 
 // User represents the user subscribing to notifications.
+// The quiet hours are minutes after midnight in the time zone of the user, notifications are held back
+// from their start until their end. Equal start and end turn them off.
 type User struct {
-	ID           int64     `json:"id"`
-	TelegramID   int64     `json:"telegram_id"`
-	UserName     string    `json:"username"`
-	FirstName    string    `json:"first_name"`
-	LastName     string    `json:"last_name"`
-	LastNotified time.Time `json:"last_notified"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID              int64     `json:"id"`
+	TelegramID      int64     `json:"telegram_id"`
+	UserName        string    `json:"username"`
+	FirstName       string    `json:"first_name"`
+	LastName        string    `json:"last_name"`
+	LastNotified    time.Time `json:"last_notified"`
+	Active          bool      `json:"active"`    // False once the user blocked the bot or deleted their account
+	TimeZone        string    `json:"time_zone"` // IANA name, empty for the default time zone
+	QuietHoursStart int       `json:"quiet_hours_start"`
+	QuietHoursEnd   int       `json:"quiet_hours_end"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
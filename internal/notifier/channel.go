@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// Notification is an alert about a unit, rendered for every delivery channel.
+type Notification struct {
+	Subject string `json:"subject"` // A short summary, e.g. the subject of an email
+	Text    string `json:"text"`
+	Unit    m.Unit `json:"unit"` // The unit the alert is about, empty for a digest
+}
+
+// Recipient is the user a notification is delivered to through a channel.
+type Recipient struct {
+	User    *m.User
+	Address string // The address of the user in the channel, empty for Telegram
+}
+
+// Channel delivers notifications through one way of reaching the users, e.g. Telegram or email.
+type Channel interface {
+	Type() m.ChannelType
+	Send(ctx context.Context, recipient Recipient, notification *Notification) error
+}
+
+// TelegramSender sends a text message to a Telegram chat. *telegram.Bot implements it.
+// An error resending cannot fix, e.g. for a user who blocked the bot, wraps ErrUndeliverable.
+type TelegramSender interface {
+	SendNotification(ctx context.Context, chatID int64, text string) error
+}
+
+// TelegramChannel delivers notifications to the Telegram chat of the user.
+type TelegramChannel struct {
+	sender TelegramSender
+}
+
+// NewTelegramChannel creates a new TelegramChannel sending the messages through the sender.
+func NewTelegramChannel(sender TelegramSender) *TelegramChannel {
+	return &TelegramChannel{sender: sender}
+}
+
+// Type returns the channel type.
+func (c *TelegramChannel) Type() m.ChannelType {
+	return m.ChannelTelegram
+}
+
+// Send sends the text of the notification to the chat of the user.
+func (c *TelegramChannel) Send(ctx context.Context, recipient Recipient, notification *Notification) error {
+	if err := c.sender.SendNotification(ctx, recipient.User.TelegramID, notification.Text); err != nil {
+		return fmt.Errorf("failed to send Telegram message to %d: %w", recipient.User.TelegramID, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/localtime"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+// isDigest reports whether the alerts of the subscription are collected into a digest instead of sent right away.
+func isDigest(subscription *m.Subscription) bool {
+	return subscription.DeliveryMode == m.DeliveryModeDaily || subscription.DeliveryMode == m.DeliveryModeWeekly
+}
+
+// digestDueAt returns the next send time of the digest of the subscription, in the time zone of the user.
+func digestDueAt(user *m.User, subscription *m.Subscription, now time.Time) time.Time {
+	local := now.In(localtime.UserLocation(user))
+	if subscription.DeliveryMode == m.DeliveryModeWeekly {
+		return localtime.NextWeekly(local, subscription.DigestWeekday, subscription.DigestTime)
+	}
+	return localtime.Next(local, subscription.DigestTime)
+}
+
+// collectForDigest adds the unit of the event to the next digest of the subscription.
+func (n *Notifier) collectForDigest(
+	ctx context.Context,
+	repos repository.Repositories,
+	user *m.User,
+	subscription *m.Subscription,
+	event *m.UnitEvent,
+) error {
+	item := &m.DigestItem{
+		UserID:         user.ID,
+		SubscriptionID: subscription.ID,
+		Unit:           event.Unit,
+		DueAt:          digestDueAt(user, subscription, n.now()),
+	}
+	if event.Type == m.UnitEventPriceChanged && event.Previous != nil {
+		item.PreviousPrice = event.Previous.Price
+	}
+	if err := repos.Digests.AddDigestItem(ctx, item); err != nil {
+		return fmt.Errorf("failed to add unit %d to the digest of subscription %d: %w", event.Unit.ID, subscription.ID, err)
+	}
+	return nil
+}
+
+// SendDigests puts the due digests into the outbox and delivers them. It is run by the scheduler.
+func (n *Notifier) SendDigests(ctx context.Context) error {
+	if err := n.sendDigests(ctx); err != nil {
+		return err
+	}
+	// What fails is retried by the outbox worker
+	if err := n.dispatch(ctx); err != nil {
+		slog.Error("Failed to dispatch notifications", "error", err)
+	}
+	return nil
+}
+
+// sendDigests puts the due digests into the outbox, one per user and send time.
+// The items are deleted in the transaction their digest is enqueued in.
+// A digest failing is logged and kept for the next run, the other users still get theirs.
+func (n *Notifier) sendDigests(ctx context.Context) error {
+	items, err := n.digestRepo.GetDueDigestItems(ctx, n.now())
+	if err != nil {
+		return fmt.Errorf("failed to load due digest items: %w", err)
+	}
+
+	// The items come ordered by user and due time, so every digest is a run of items
+	for start := 0; start < len(items); {
+		end := start + 1
+		for end < len(items) && items[end].UserID == items[start].UserID && items[end].DueAt.Equal(items[start].DueAt) {
+			end++
+		}
+		if err := n.sendDigest(ctx, items[start:end]); err != nil {
+			slog.Error("Failed to send digest", "userID", items[start].UserID, "error", err)
+		}
+		start = end
+	}
+	return nil
+}
+
+// sendDigest puts the digest of the items, all of one user and send time, into the outbox.
+// The items of a user who is gone or inactive are dropped.
+func (n *Notifier) sendDigest(ctx context.Context, items []*m.DigestItem) error {
+	userID, dueAt := items[0].UserID, items[0].DueAt
+	user, err := n.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user %d: %w", userID, err)
+	}
+
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return n.transactor.WithTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if user != nil && user.Active {
+			notification := newDigestNotification(uniqueUnits(items))
+			entry := m.Notification{
+				IdempotencyKey: fmt.Sprintf("digest:%d:%d", userID, dueAt.Unix()),
+				Subject:        notification.Subject,
+				Text:           notification.Text,
+			}
+			if _, err := n.enqueue(ctx, repos, user, entry); err != nil {
+				return fmt.Errorf("failed to enqueue digest of user %d: %w", userID, err)
+			}
+		}
+
+		if err := repos.Digests.DeleteDigestItems(ctx, ids); err != nil {
+			return fmt.Errorf("failed to delete digest items of user %d: %w", userID, err)
+		}
+		return nil
+	})
+}
+
+// uniqueUnits returns the items of distinct units, a unit matched by several subscriptions or changed several times
+// only once. The last item of a unit is kept, as it holds the latest state of the unit.
+func uniqueUnits(items []*m.DigestItem) []*m.DigestItem {
+	index := make(map[int64]int, len(items))
+	unique := make([]*m.DigestItem, 0, len(items))
+	for _, item := range items {
+		if i, seen := index[item.Unit.ID]; seen {
+			unique[i] = item
+			continue
+		}
+		index[item.Unit.ID] = len(unique)
+		unique = append(unique, item)
+	}
+	return unique
+}
@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// EmailChannel delivers notifications by email through an SMTP server.
+// The connection is upgraded with STARTTLS whenever the server offers it.
+type EmailChannel struct {
+	cfg *config.EmailConfig
+}
+
+// NewEmailChannel creates a new EmailChannel sending through the configured SMTP server.
+func NewEmailChannel(cfg *config.EmailConfig) *EmailChannel {
+	return &EmailChannel{cfg: cfg}
+}
+
+// Type returns the channel type.
+func (c *EmailChannel) Type() m.ChannelType {
+	return m.ChannelEmail
+}
+
+// Send emails the notification to the address of the recipient.
+func (c *EmailChannel) Send(ctx context.Context, recipient Recipient, notification *Notification) error {
+	if recipient.Address == "" {
+		return errors.New("no email address")
+	}
+
+	message, err := c.buildMessage(recipient.Address, notification)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(c.cfg.SMTPHost, strconv.Itoa(c.cfg.SMTPPort))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the SMTP server: %w", err)
+	}
+	defer conn.Close()
+
+	// net/smtp does not take a context, the deadline bounds the whole conversation instead
+	deadline := time.Now().Add(c.cfg.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("failed to set the SMTP deadline: %w", err)
+	}
+
+	client, err := smtp.NewClient(conn, c.cfg.SMTPHost)
+	if err != nil {
+		return fmt.Errorf("failed to greet the SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if err := c.send(client, recipient.Address, message); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", recipient.Address, err)
+	}
+	return nil
+}
+
+// SendVerificationCode emails the code confirming the address to it.
+func (c *EmailChannel) SendVerificationCode(ctx context.Context, address, code string) error {
+	notification := &Notification{
+		Subject: "Confirm your email address",
+		Text: fmt.Sprintf("Send /verify %s to the bot to receive the storage unit notifications at this address.\n\n"+
+			"If you did not ask for them, ignore this email.", code),
+	}
+	return c.Send(ctx, Recipient{Address: address}, notification)
+}
+
+// send runs the SMTP conversation delivering the message.
+func (c *EmailChannel) send(client *smtp.Client, to string, message []byte) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.cfg.SMTPHost, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if c.cfg.SMTPUsername != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.cfg.SMTPUsername, c.cfg.SMTPPassword, c.cfg.SMTPHost)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(c.cfg.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage renders the notification as a plain text email.
+func (c *EmailChannel) buildMessage(to string, notification *Notification) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", c.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", notification.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	b.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	if _, err := w.Write([]byte(notification.Text)); err != nil {
+		return nil, fmt.Errorf("failed to encode the email body: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode the email body: %w", err)
+	}
+	return b.Bytes(), nil
+}
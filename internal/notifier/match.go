@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// matchKey holds the unit attributes a subscription is matched on.
+type matchKey struct {
+	city    string
+	storage string
+	size    string
+}
+
+// Matcher finds the subscriptions a unit matches.
+// Subscriptions are indexed by city, storage and unit size, so a lookup does not scan all of them.
+type Matcher struct {
+	index map[matchKey][]*m.Subscription
+}
+
+// NewMatcher creates a Matcher for the given subscriptions.
+func NewMatcher(subscriptions []*m.Subscription) *Matcher {
+	index := make(map[matchKey][]*m.Subscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		key := matchKey{city: subscription.City, storage: subscription.Storage, size: subscription.UnitSize}
+		index[key] = append(index[key], subscription)
+	}
+	return &Matcher{index: index}
+}
+
+// Match returns the subscriptions whose city, storage, unit size and provider match the unit.
+func (mt *Matcher) Match(unit *m.Unit) []*m.Subscription {
+	var matched []*m.Subscription
+	for _, subscription := range mt.index[matchKey{city: unit.City, storage: unit.Storage, size: unit.Size}] {
+		if subscription.Provider == "" || subscription.Provider == unit.Provider {
+			matched = append(matched, subscription)
+		}
+	}
+	return matched
+}
+
+// becameAvailable reports whether the event announces a unit that can be rented now.
+func becameAvailable(event *m.UnitEvent) bool {
+	switch event.Type {
+	case m.UnitEventAvailable:
+		return true
+	case m.UnitEventNew:
+		return event.Unit.Available
+	default:
+		return false
+	}
+}
+
+// withinMaxPrice reports whether the unit costs at most the maximum price of the subscription, if it has one.
+func withinMaxPrice(subscription *m.Subscription, unit *m.Unit) bool {
+	return subscription.MaxPrice <= 0 || unit.Price <= subscription.MaxPrice
+}
+
+// priceDropped reports whether the event is a price drop of an available unit announced by the price rules
+// of the subscription: the price fell to the maximum price, or by at least the minimum drop.
+func priceDropped(subscription *m.Subscription, event *m.UnitEvent) bool {
+	if event.Type != m.UnitEventPriceChanged || event.Previous == nil || !event.Unit.Available {
+		return false
+	}
+	previous, current := event.Previous.Price, event.Unit.Price
+	if current >= previous || !withinMaxPrice(subscription, &event.Unit) {
+		return false
+	}
+
+	crossedMaxPrice := subscription.MaxPrice > 0 && previous > subscription.MaxPrice
+	minDrop := float64(subscription.MinPriceDrop)
+	droppedEnough := minDrop > 0 && priceDropPercent(previous, current) >= minDrop
+	return crossedMaxPrice || droppedEnough
+}
+
+// priceDropPercent returns how many percent the price fell from previous to current.
+func priceDropPercent(previous, current float64) float64 {
+	if previous <= 0 {
+		return 0
+	}
+	return (previous - current) / previous * 100
+}
@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+const (
+	// maxMessageLength is the longest text Telegram accepts in a message, in UTF-16 code units.
+	maxMessageLength = 4096
+	// maxOmittedLineLength bounds the length of the line counting the units left out of a digest.
+	maxOmittedLineLength = 32
+)
+
+// newAvailableNotification builds the notification about a unit that became available.
+func newAvailableNotification(unit *m.Unit) *Notification {
+	return &Notification{
+		Subject: fmt.Sprintf("Unit available: %s, %s", unit.Storage, unit.City),
+		Text:    formatAvailableMessage(unit),
+		Unit:    *unit,
+	}
+}
+
+// newPriceDropNotification builds the notification about the price drop of an available unit.
+func newPriceDropNotification(unit *m.Unit, previousPrice float64) *Notification {
+	return &Notification{
+		Subject: fmt.Sprintf("Price drop: %s, %s", unit.Storage, unit.City),
+		Text:    formatPriceDropMessage(unit, previousPrice),
+		Unit:    *unit,
+	}
+}
+
+// newEventNotification builds the notification about the event, a price drop or a unit that became available.
+func newEventNotification(event *m.UnitEvent) *Notification {
+	if event.Type == m.UnitEventPriceChanged && event.Previous != nil {
+		return newPriceDropNotification(&event.Unit, event.Previous.Price)
+	}
+	return newAvailableNotification(&event.Unit)
+}
+
+// formatAvailableMessage builds the alert about a unit that became available.
+func formatAvailableMessage(unit *m.Unit) string {
+	return formatUnitMessage("A unit matching your subscription is available!", unit,
+		fmt.Sprintf("%s ₽/month", FormatPrice(unit.Price)))
+}
+
+// formatPriceDropMessage builds the alert about the price drop of an available unit.
+func formatPriceDropMessage(unit *m.Unit, previousPrice float64) string {
+	return formatUnitMessage("The price of a unit matching your subscription dropped!", unit,
+		formatPriceChange(previousPrice, unit.Price))
+}
+
+// formatUnitMessage builds an alert about a unit with the headline and the formatted price.
+func formatUnitMessage(headline string, unit *m.Unit, price string) string {
+	var b strings.Builder
+	b.WriteString(headline + "\n\n")
+	fmt.Fprintf(&b, "%s, %s\n", unit.Storage, unit.City)
+	fmt.Fprintf(&b, "Unit: %s\n", unit.Name)
+	if unit.Dimension != "" {
+		fmt.Fprintf(&b, "Size: %s (%s)\n", unit.Size, unit.Dimension)
+	} else {
+		fmt.Fprintf(&b, "Size: %s\n", unit.Size)
+	}
+	fmt.Fprintf(&b, "Price: %s\n", price)
+	if unit.URL != "" {
+		fmt.Fprintf(&b, "\n%s", unit.URL)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// newDigestNotification builds the digest of the units that became available or got cheaper since the previous one.
+func newDigestNotification(items []*m.DigestItem) *Notification {
+	return &Notification{
+		Subject: fmt.Sprintf("Digest: %d %s available", len(items), pluralUnits(len(items))),
+		Text:    formatDigestMessage(items),
+	}
+}
+
+// formatDigestMessage builds the digest listing the units, with the old and new price of the price drops.
+// The units beyond the length limit of a message are left out and only counted at the end.
+func formatDigestMessage(items []*m.DigestItem) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s matching your subscriptions:\n", len(items), pluralUnits(len(items)))
+	length := messageLength(b.String())
+	for i, item := range items {
+		entry := formatDigestEntry(i+1, item)
+		if length+messageLength(entry)+maxOmittedLineLength > maxMessageLength {
+			omitted := len(items) - i
+			fmt.Fprintf(&b, "\n…and %d more %s", omitted, pluralUnits(omitted))
+			break
+		}
+		b.WriteString(entry)
+		length += messageLength(entry)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// formatDigestEntry builds the numbered entry of a unit in a digest.
+func formatDigestEntry(number int, item *m.DigestItem) string {
+	unit := &item.Unit
+	price := fmt.Sprintf("%s ₽/month", FormatPrice(unit.Price))
+	if item.PreviousPrice > 0 {
+		price = formatPriceChange(item.PreviousPrice, unit.Price)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%d. %s, %s\n", number, unit.Storage, unit.City)
+	fmt.Fprintf(&b, "Unit: %s, %s, %s\n", unit.Name, unit.Size, price)
+	if unit.URL != "" {
+		fmt.Fprintf(&b, "%s\n", unit.URL)
+	}
+	return b.String()
+}
+
+// messageLength returns the length of the text as Telegram counts it, in UTF-16 code units.
+func messageLength(text string) int {
+	length := 0
+	for _, r := range text {
+		length++
+		if r > 0xFFFF {
+			length++
+		}
+	}
+	return length
+}
+
+// pluralUnits returns the noun for the number of units.
+func pluralUnits(count int) string {
+	if count == 1 {
+		return "unit"
+	}
+	return "units"
+}
+
+// formatPriceChange formats the old and new price, e.g. "5000 → 4000 ₽/month (−20%)".
+func formatPriceChange(previous, current float64) string {
+	return fmt.Sprintf("%s → %s ₽/month (−%.0f%%)",
+		FormatPrice(previous), FormatPrice(current), priceDropPercent(previous, current))
+}
+
+// FormatPrice formats a price in rubles without a fractional part unless it has kopecks, e.g. "3500" or "4999.90".
+func FormatPrice(price float64) string {
+	if price == float64(int64(price)) {
+		return fmt.Sprintf("%d", int64(price))
+	}
+	return fmt.Sprintf("%.2f", price)
+}
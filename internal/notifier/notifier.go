@@ -2,93 +2,227 @@ package notifier
 
 import (
 	"context"
-	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+
 	"github.com/movax01h/kladovkin-telegram-bot/config"
 	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
-	"github.com/movax01h/kladovkin-telegram-bot/internal/telegram"
 )
 
 // Notifier handles the logic for sending notifications.
+// Every alert is put into the outbox once per delivery channel the user has enabled,
+// and delivered from there, so a failed delivery is retried instead of being lost.
+// The alerts of a subscription in a digest delivery mode are collected and sent as one digest at its send time.
+// A unit is announced to a subscription once when it becomes available, and again only after it was observed
+// unavailable in between.
 type Notifier struct {
 	cfg              *config.NotifierConfig
 	userRepo         repository.UserRepository
 	subscriptionRepo repository.SubscriptionRepository
-	telegramBot      *telegram.Bot
+	notificationRepo repository.NotificationRepository
+	digestRepo       repository.DigestRepository
+	alertRepo        repository.UnitAlertRepository
+	transactor       repository.Transactor
+	channels         map[m.ChannelType]Channel
+	now              func() time.Time
+
+	dispatchMu sync.Mutex // Serializes the deliveries of the event handler and the outbox worker
 }
 
-// NewNotifier creates a new Notifier instance.
-func NewNotifier(cfg *config.NotifierConfig, userRepo repository.UserRepository, subscriptionRepo repository.SubscriptionRepository, telegramBot *telegram.Bot) *Notifier {
+// NewNotifier creates a new Notifier instance delivering through the given channels.
+// A channel a user enabled but that is not given here, e.g. email without an SMTP server, is skipped.
+// The alerts are claimed and put into the outbox in the transactions of the transactor.
+func NewNotifier(
+	cfg *config.NotifierConfig,
+	userRepo repository.UserRepository,
+	subscriptionRepo repository.SubscriptionRepository,
+	notificationRepo repository.NotificationRepository,
+	digestRepo repository.DigestRepository,
+	alertRepo repository.UnitAlertRepository,
+	transactor repository.Transactor,
+	channels ...Channel,
+) *Notifier {
+	byType := make(map[m.ChannelType]Channel, len(channels))
+	for _, channel := range channels {
+		byType[channel.Type()] = channel
+	}
 	return &Notifier{
 		cfg:              cfg,
 		userRepo:         userRepo,
 		subscriptionRepo: subscriptionRepo,
-		telegramBot:      telegramBot,
+		notificationRepo: notificationRepo,
+		digestRepo:       digestRepo,
+		alertRepo:        alertRepo,
+		transactor:       transactor,
+		channels:         byType,
+		now:              time.Now,
 	}
 }
 
-// Start begins the notification process.
+// HandleUnitEvents alerts the subscribers about the unit events detected by the parser right away.
+// It fails if an alert cannot be claimed, enqueued or collected, so the events are handed over again.
+// Handling them again is safe, the claimed alerts are skipped and the outbox and the digests drop duplicates.
+func (n *Notifier) HandleUnitEvents(ctx context.Context, events []m.UnitEvent) error {
+	return n.sendUnitNotifications(ctx, events)
+}
+
+// Start delivers the due notifications of the outbox periodically until the context is done.
 func (n *Notifier) Start(ctx context.Context) error {
 	slog.Info("Notifier started")
-	ticker := time.NewTicker(24 * time.Hour) // Set the ticker to send notifications once a day
-	defer ticker.Stop()
+	outboxTicker := time.NewTicker(n.cfg.OutboxInterval)
+	defer outboxTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Info("Notifier shutting down")
 			return nil
-		case <-ticker.C:
-			slog.Info("Sending daily notifications")
-			if err := n.sendDailyNotifications(); err != nil {
-				slog.Error("Failed to send daily notifications", "error", err)
-				return err
+		case <-outboxTicker.C:
+			if err := n.dispatch(ctx); err != nil {
+				slog.Error("Failed to dispatch notifications", "error", err)
 			}
 		}
 	}
 }
 
-// sendDailyNotifications sends notifications to users who meet the criteria.
-func (n *Notifier) sendDailyNotifications() error {
+// sendUnitNotifications alerts the subscribers of the units that became available,
+// and of the price drops their price rules ask for. The alerts failing do not hold back the others,
+// their errors are returned together.
+func (n *Notifier) sendUnitNotifications(ctx context.Context, events []m.UnitEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
 	// Fetch active subscriptions
-	activeSubscriptions, err := n.subscriptionRepo.GetActiveSubscriptions()
+	activeSubscriptions, err := n.subscriptionRepo.GetActiveSubscriptions(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to retrieve active subscriptions: %w", err)
 	}
+	matcher := NewMatcher(activeSubscriptions)
 
-	// Iterate over active subscriptions and send notifications
-	for _, subscription := range activeSubscriptions {
-		// Check if the user meets the criteria for a notification
-		user, err := n.userRepo.GetUserByID(subscription.UserID)
-		if err != nil {
-			slog.Error("Failed to retrieve user", "userID", subscription.UserID, "error", err)
-			continue
+	// A unit that became available is announced at its new price, its price change is not announced again
+	available := make(map[int64]bool)
+	for i := range events {
+		if becameAvailable(&events[i]) {
+			available[events[i].Unit.ID] = true
 		}
+	}
 
-		// Avoid spamming by checking last notification timestamp
-		if shouldNotify(user) {
-			message := "Your subscription is active. Don't forget to check our updates!"
-			err := n.telegramBot.SendNotification(user.TelegramID, message)
-			if err != nil {
-				slog.Error("Failed to send notification", "userID", user.ID, "error", err)
-				continue
+	var errs []error
+	for i := range events {
+		event := &events[i]
+		switch {
+		case event.Type == m.UnitEventUnavailable || event.Type == m.UnitEventRemoved:
+			// The unit is announced again when it comes back
+			if err := n.alertRepo.ClearUnitAlerts(ctx, event.Unit.ID); err != nil {
+				errs = append(errs, fmt.Errorf("failed to clear alerts of unit %d: %w", event.Unit.ID, err))
+			}
+		case becameAvailable(event):
+			for _, subscription := range matcher.Match(&event.Unit) {
+				if withinMaxPrice(subscription, &event.Unit) {
+					errs = append(errs, n.notifySubscriber(ctx, subscription, event))
+				}
+			}
+		case event.Type == m.UnitEventPriceChanged && !available[event.Unit.ID]:
+			for _, subscription := range matcher.Match(&event.Unit) {
+				if priceDropped(subscription, event) {
+					errs = append(errs, n.notifySubscriber(ctx, subscription, event))
+				}
 			}
-			// Update last notification time
-			user.LastNotified = time.Now()
-			err = n.userRepo.UpdateUser(user)
+		}
+	}
+
+	// Deliver right away, what fails is retried by the outbox worker
+	if err := n.dispatch(ctx); err != nil {
+		slog.Error("Failed to dispatch notifications", "error", err)
+	}
+	return errors.Join(errs...)
+}
+
+// notifySubscriber puts the alert about the event into the outbox of the owner of the subscription,
+// or into their next digest. The alert is claimed in the same transaction, so it stays unclaimed if that fails.
+func (n *Notifier) notifySubscriber(ctx context.Context, subscription *m.Subscription, event *m.UnitEvent) error {
+	user, err := n.userRepo.GetUserByID(ctx, subscription.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user %d: %w", subscription.UserID, err)
+	}
+	if user == nil {
+		slog.Warn("Subscription owner not found", "subscriptionID", subscription.ID, "userID", subscription.UserID)
+		return nil
+	}
+	if !user.Active {
+		return nil
+	}
+
+	enqueued := 0
+	err = n.transactor.WithTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if becameAvailable(event) {
+			claimed, err := repos.UnitAlerts.ClaimUnitAlert(ctx, subscription.ID, event.Unit.ID, n.now())
 			if err != nil {
-				slog.Error("Failed to update user's last notification time", "userID", user.ID, "error", err)
+				return fmt.Errorf("failed to claim alert of unit %d: %w", event.Unit.ID, err)
+			}
+			if !claimed {
+				// Announced before and not observed unavailable since
+				return nil
 			}
 		}
+
+		if isDigest(subscription) {
+			return n.collectForDigest(ctx, repos, user, subscription, event)
+		}
+
+		notification := newEventNotification(event)
+		var err error
+		enqueued, err = n.enqueue(ctx, repos, user, m.Notification{
+			IdempotencyKey: idempotencyKey(subscription, event),
+			SubscriptionID: subscription.ID,
+			UnitID:         event.Unit.ID,
+			Subject:        notification.Subject,
+			Text:           notification.Text,
+			Unit:           notification.Unit,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to enqueue notification of unit %d for user %d: %w", event.Unit.ID, user.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to notify subscription %d: %w", subscription.ID, err)
+	}
+	if enqueued == 0 {
+		return nil
 	}
 
+	// Update last notification time
+	user.LastNotified = n.now()
+	if err := n.userRepo.UpdateUser(ctx, user); err != nil {
+		slog.Error("Failed to update user's last notification time", "userID", user.ID, "error", err)
+	}
 	return nil
 }
 
-// shouldNotify checks if the user should receive a notification based on the last notified timestamp.
-func shouldNotify(user *m.User) bool {
-	// Example logic: Notify if more than 24 hours have passed since the last notification
-	return time.Since(user.LastNotified) > 24*time.Hour
+// deliveryTargets returns the enabled delivery channels of a user.
+// Telegram is enabled unless the user turned it off, the other channels have to be set up by the user
+// and an email address has to be confirmed first.
+func deliveryTargets(channels []*m.DeliveryChannel) []*m.DeliveryChannel {
+	telegram := &m.DeliveryChannel{Type: m.ChannelTelegram, Enabled: true}
+	targets := make([]*m.DeliveryChannel, 0, len(channels)+1)
+	for _, channel := range channels {
+		if channel.Type == m.ChannelTelegram {
+			telegram = channel
+			continue
+		}
+		if channel.Enabled && channel.Confirmed() {
+			targets = append(targets, channel)
+		}
+	}
+	if telegram.Enabled {
+		targets = append([]*m.DeliveryChannel{telegram}, targets...)
+	}
+	return targets
 }
@@ -1 +1,1029 @@
 package notifier
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/memory"
+)
+
+// recordingChannel records the notifications sent through it and fails for the addresses in fail.
+type recordingChannel struct {
+	channelType m.ChannelType
+	fail        map[string]bool
+
+	mu   sync.Mutex
+	sent []Recipient
+}
+
+func (c *recordingChannel) Type() m.ChannelType {
+	return c.channelType
+}
+
+func (c *recordingChannel) Send(_ context.Context, recipient Recipient, _ *Notification) error {
+	if c.fail[recipient.Address] {
+		return errors.New("delivery failed")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, recipient)
+	return nil
+}
+
+// smtpServer is a minimal SMTP server standing in for a mail relay. It accepts every message.
+type smtpServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	from     string
+	rcpt     []string
+	messages []string
+}
+
+// newSMTPServer starts an SMTP server on a local port, which is closed when the test finishes.
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpServer{listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// config returns the email configuration pointing at the server.
+func (s *smtpServer) config() *config.EmailConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &config.EmailConfig{SMTPHost: addr.IP.String(), SMTPPort: addr.Port, From: "bot@example.com", Timeout: 5 * time.Second}
+}
+
+// serve speaks just enough SMTP for net/smtp to deliver a message.
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>")
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			s.mu.Lock()
+			s.rcpt = append(s.rcpt, strings.Trim(strings.TrimSpace(line)[len("RCPT TO:"):], "<>"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	// Arrange
+	anyProvider := &m.Subscription{ID: 1, City: "Москва", Storage: "Кладовкин на Ленинском", UnitSize: "2 м²"}
+	sameProvider := &m.Subscription{ID: 2, Provider: "kladovkin", City: "Москва", Storage: "Кладовкин на Ленинском", UnitSize: "2 м²"}
+	otherProvider := &m.Subscription{ID: 3, Provider: "other", City: "Москва", Storage: "Кладовкин на Ленинском", UnitSize: "2 м²"}
+	otherSize := &m.Subscription{ID: 4, City: "Москва", Storage: "Кладовкин на Ленинском", UnitSize: "4 м²"}
+	matcher := NewMatcher([]*m.Subscription{anyProvider, sameProvider, otherProvider, otherSize})
+
+	unit := &m.Unit{Provider: "kladovkin", City: "Москва", Storage: "Кладовкин на Ленинском", Size: "2 м²"}
+
+	// Act
+	matched := matcher.Match(unit)
+
+	// Assert
+	assert.Equal(t, []*m.Subscription{anyProvider, sameProvider}, matched)
+	assert.Empty(t, matcher.Match(&m.Unit{Provider: "kladovkin", City: "Санкт-Петербург", Storage: "Кладовкин на Ленинском", Size: "2 м²"}))
+}
+
+func TestBecameAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    m.UnitEvent
+		expected bool
+	}{
+		{"available", m.UnitEvent{Type: m.UnitEventAvailable, Unit: m.Unit{Available: true}}, true},
+		{"new and available", m.UnitEvent{Type: m.UnitEventNew, Unit: m.Unit{Available: true}}, true},
+		{"new and occupied", m.UnitEvent{Type: m.UnitEventNew, Unit: m.Unit{Available: false}}, false},
+		{"unavailable", m.UnitEvent{Type: m.UnitEventUnavailable}, false},
+		{"price changed", m.UnitEvent{Type: m.UnitEventPriceChanged, Unit: m.Unit{Available: true}}, false},
+		{"removed", m.UnitEvent{Type: m.UnitEventRemoved}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, becameAvailable(&tt.event))
+		})
+	}
+}
+
+func TestPriceDropped(t *testing.T) {
+	maxPrice := &m.Subscription{MaxPrice: 5000}
+	minDrop := &m.Subscription{MinPriceDrop: 10}
+	both := &m.Subscription{MaxPrice: 4000, MinPriceDrop: 10}
+	priceChange := func(previous, current float64, available bool) *m.UnitEvent {
+		return &m.UnitEvent{
+			Type:     m.UnitEventPriceChanged,
+			Unit:     m.Unit{Price: current, Available: available},
+			Previous: &m.Unit{Price: previous, Available: available},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		subscription *m.Subscription
+		event        *m.UnitEvent
+		expected     bool
+	}{
+		{"no rules", &m.Subscription{}, priceChange(6000, 3000, true), false},
+		{"falls to the maximum price", maxPrice, priceChange(5500, 5000, true), true},
+		{"stays above the maximum price", maxPrice, priceChange(6000, 5500, true), false},
+		{"already below the maximum price", maxPrice, priceChange(4800, 4500, true), false},
+		{"drops by the minimum", minDrop, priceChange(5000, 4500, true), true},
+		{"drops by less than the minimum", minDrop, priceChange(5000, 4600, true), false},
+		{"rises", minDrop, priceChange(4500, 5000, true), false},
+		{"occupied unit", minDrop, priceChange(5000, 4000, false), false},
+		{"drops but stays above the maximum price", both, priceChange(6000, 4500, true), false},
+		{"drops below the maximum price", both, priceChange(4200, 3700, true), true},
+		{"availability change", minDrop, &m.UnitEvent{Type: m.UnitEventAvailable, Unit: m.Unit{Available: true}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, priceDropped(tt.subscription, tt.event))
+		})
+	}
+}
+
+func TestFormatPriceDropMessage(t *testing.T) {
+	// Arrange
+	unit := &m.Unit{Name: "Бокс B-10", City: "Москва", Storage: "Кладовкин на Ленинском", Size: "4,5 м²", Price: 4000}
+
+	// Act
+	message := formatPriceDropMessage(unit, 5000)
+
+	// Assert
+	assert.Equal(t, "The price of a unit matching your subscription dropped!\n\n"+
+		"Кладовкин на Ленинском, Москва\n"+
+		"Unit: Бокс B-10\n"+
+		"Size: 4,5 м²\n"+
+		"Price: 5000 → 4000 ₽/month (−20%)", message)
+}
+
+func TestFormatDigestMessage_Limit(t *testing.T) {
+	// Arrange
+	items := make([]*m.DigestItem, 200)
+	for i := range items {
+		items[i] = &m.DigestItem{Unit: m.Unit{
+			ID: int64(i + 1), Name: "A-" + strconv.Itoa(i+1), City: "Москва", Storage: "Ленинский", Size: "2 м²",
+			Price: 3500, URL: "https://kladovkin.ru/units/" + strconv.Itoa(i+1),
+		}}
+	}
+
+	// Act
+	notification := newDigestNotification(items)
+
+	// Assert
+	assert.Equal(t, "Digest: 200 units available", notification.Subject)
+	assert.LessOrEqual(t, messageLength(notification.Text), maxMessageLength)
+	listed := strings.Count(notification.Text, "\nUnit: ")
+	assert.Greater(t, listed, 0)
+	assert.True(t, strings.HasSuffix(notification.Text, "\n…and "+strconv.Itoa(200-listed)+" more units"),
+		"the units left out are counted")
+}
+
+func TestFormatAvailableMessage(t *testing.T) {
+	// Arrange
+	unit := &m.Unit{
+		Name:      "Бокс B-10",
+		City:      "Москва",
+		Storage:   "Кладовкин на Ленинском",
+		Size:      "4,5 м²",
+		Dimension: "1,5 × 3 × 2,5 м",
+		Price:     6750.5,
+		URL:       "https://kladovkin.ru/moskva/leninskiy/1203",
+	}
+
+	// Act
+	message := formatAvailableMessage(unit)
+
+	// Assert
+	assert.Equal(t, "A unit matching your subscription is available!\n\n"+
+		"Кладовкин на Ленинском, Москва\n"+
+		"Unit: Бокс B-10\n"+
+		"Size: 4,5 м² (1,5 × 3 × 2,5 м)\n"+
+		"Price: 6750.50 ₽/month\n"+
+		"\n"+
+		"https://kladovkin.ru/moskva/leninskiy/1203", message)
+}
+
+func TestSendUnitNotifications(t *testing.T) {
+	ctx := context.Background()
+	event := m.UnitEvent{
+		Type: m.UnitEventAvailable,
+		Unit: m.Unit{ID: 1, Provider: "kladovkin", City: "Москва", Storage: "Ленинский", Size: "2 м²", Available: true},
+	}
+
+	t.Run("should fail when the subscriptions cannot be loaded", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore()
+		repos := store.Repositories()
+		n := NewNotifier(
+			&config.NotifierConfig{}, repos.Users, repos.Subscriptions, repos.Notifications,
+			repos.Digests, repos.UnitAlerts, store,
+		)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// Act
+		err := n.HandleUnitEvents(cancelled, []m.UnitEvent{event})
+
+		// Assert
+		assert.ErrorIs(t, err, context.Canceled, "the events are handed over again")
+	})
+
+	t.Run("should skip paused subscriptions", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore()
+		repos := store.Repositories()
+		require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, &m.Subscription{
+			UserID: 1, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusPaused,
+		}))
+		channel := &recordingChannel{channelType: m.ChannelTelegram}
+		n := NewNotifier(
+			&config.NotifierConfig{}, repos.Users, repos.Subscriptions, repos.Notifications,
+			repos.Digests, repos.UnitAlerts, store, channel,
+		)
+
+		// Act
+		err := n.HandleUnitEvents(ctx, []m.UnitEvent{event})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, channel.sent)
+	})
+
+	t.Run("should announce a unit again only after it was unavailable", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore()
+		repos := store.Repositories()
+		user := &m.User{TelegramID: 100, Active: true}
+		require.NoError(t, repos.Users.CreateUser(ctx, user))
+		for range 2 {
+			require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, &m.Subscription{
+				UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+			}))
+		}
+		channel := &recordingChannel{channelType: m.ChannelTelegram}
+		n := NewNotifier(
+			outboxConfig, repos.Users, repos.Subscriptions, repos.Notifications,
+			repos.Digests, repos.UnitAlerts, store, channel,
+		)
+		at := func(event m.UnitEvent, detectedAt time.Time) m.UnitEvent {
+			event.DetectedAt = detectedAt
+			return event
+		}
+		unavailable := m.UnitEvent{Type: m.UnitEventUnavailable, Unit: event.Unit}
+		start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		n.now = func() time.Time { return start }
+
+		// Act
+		require.NoError(t, n.HandleUnitEvents(ctx, []m.UnitEvent{at(event, start)}))
+		first := len(channel.sent)
+		require.NoError(t, n.HandleUnitEvents(ctx, []m.UnitEvent{at(event, start.Add(time.Hour))}))
+		repeated := len(channel.sent)
+		require.NoError(t, n.HandleUnitEvents(ctx, []m.UnitEvent{
+			at(unavailable, start.Add(2*time.Hour)), at(event, start.Add(3*time.Hour)),
+		}))
+		cycled := len(channel.sent)
+
+		// Assert
+		assert.Equal(t, 2, first, "every subscription is alerted")
+		assert.Equal(t, 2, repeated, "the unit is not announced again while it stays available")
+		assert.Equal(t, 4, cycled, "the unit is announced again after it was unavailable")
+		notified, err := repos.Users.GetUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.True(t, notified.LastNotified.Equal(start), "the notification time comes from the clock of the notifier")
+	})
+
+	t.Run("should leave the alert unclaimed when it cannot be enqueued", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore()
+		repos := store.Repositories()
+		user := &m.User{TelegramID: 100, Active: true}
+		require.NoError(t, repos.Users.CreateUser(ctx, user))
+		require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, &m.Subscription{
+			UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+		}))
+		channel := &recordingChannel{channelType: m.ChannelTelegram}
+		newNotifier := func(transactor repository.Transactor) *Notifier {
+			return NewNotifier(
+				outboxConfig, repos.Users, repos.Subscriptions, repos.Notifications,
+				repos.Digests, repos.UnitAlerts, transactor, channel,
+			)
+		}
+
+		// Act
+		failed := newNotifier(failingEnqueues{Transactor: store}).HandleUnitEvents(ctx, []m.UnitEvent{event})
+		retried := newNotifier(store).HandleUnitEvents(ctx, []m.UnitEvent{event})
+
+		// Assert
+		assert.Error(t, failed, "the events are handed over again")
+		require.NoError(t, retried)
+		assert.Len(t, channel.sent, 1, "the alert is sent when the events are handed over again")
+	})
+}
+
+// failingEnqueues is a Transactor whose transactions fail to enqueue notifications.
+type failingEnqueues struct {
+	repository.Transactor
+}
+
+func (t failingEnqueues) WithTx(
+	ctx context.Context,
+	fn func(ctx context.Context, repos repository.Repositories) error,
+) error {
+	return t.Transactor.WithTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		repos.Notifications = failingNotifications{repos.Notifications}
+		return fn(ctx, repos)
+	})
+}
+
+// failingNotifications is a NotificationRepository failing to enqueue.
+type failingNotifications struct {
+	repository.NotificationRepository
+}
+
+func (failingNotifications) EnqueueNotification(context.Context, *m.Notification) (bool, error) {
+	return false, errors.New("database is locked")
+}
+
+func TestSendUnitNotifications_PriceRules(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100, Active: true}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, &m.Subscription{
+		UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+		MaxPrice: 4000,
+	}))
+	n := NewNotifier(
+		outboxConfig, repos.Users, repos.Subscriptions, repos.Notifications,
+		repos.Digests, repos.UnitAlerts, store, &recordingChannel{channelType: m.ChannelTelegram},
+	)
+	newUnit := func(id int64, price float64) m.Unit {
+		return m.Unit{ID: id, City: "Москва", Storage: "Ленинский", Size: "2 м²", Price: price, Available: true}
+	}
+	expensive, cheaper := newUnit(1, 4500), newUnit(2, 3800)
+
+	// Act
+	err := n.HandleUnitEvents(ctx, []m.UnitEvent{
+		{Type: m.UnitEventAvailable, Unit: expensive},
+		{Type: m.UnitEventPriceChanged, Unit: cheaper, Previous: &m.Unit{ID: 2, Price: 4200, Available: true}},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	sent, err := repos.Notifications.GetNotificationByID(ctx, 1)
+	require.NoError(t, err)
+	require.NotNil(t, sent, "the price drop is announced")
+	assert.Equal(t, int64(2), sent.UnitID)
+	assert.Equal(t, "Price drop: Ленинский, Москва", sent.Subject)
+	assert.Contains(t, sent.Text, "Price: 4200 → 3800 ₽/month (−10%)")
+	other, err := repos.Notifications.GetNotificationByID(ctx, 2)
+	require.NoError(t, err)
+	assert.Nil(t, other, "the unit above the maximum price is not announced")
+}
+
+func TestDeliveryTargets(t *testing.T) {
+	email := &m.DeliveryChannel{Type: m.ChannelEmail, Address: "user@example.com", Enabled: true}
+	disabledEmail := &m.DeliveryChannel{Type: m.ChannelEmail, Address: "user@example.com", Enabled: false}
+	unconfirmedEmail := &m.DeliveryChannel{
+		Type: m.ChannelEmail, Address: "user@example.com", Enabled: true, VerificationCode: "ABC123",
+	}
+	webhook := &m.DeliveryChannel{Type: m.ChannelWebhook, Address: "https://example.com/hook", Enabled: true}
+	telegramOff := &m.DeliveryChannel{Type: m.ChannelTelegram, Enabled: false}
+
+	tests := []struct {
+		name     string
+		channels []*m.DeliveryChannel
+		expected []m.ChannelType
+	}{
+		{"telegram by default", nil, []m.ChannelType{m.ChannelTelegram}},
+		{"telegram and email", []*m.DeliveryChannel{email}, []m.ChannelType{m.ChannelTelegram, m.ChannelEmail}},
+		{"disabled email", []*m.DeliveryChannel{disabledEmail}, []m.ChannelType{m.ChannelTelegram}},
+		{"unconfirmed email", []*m.DeliveryChannel{unconfirmedEmail}, []m.ChannelType{m.ChannelTelegram}},
+		{"telegram turned off", []*m.DeliveryChannel{telegramOff, webhook}, []m.ChannelType{m.ChannelWebhook}},
+		{"everything off", []*m.DeliveryChannel{telegramOff, disabledEmail}, []m.ChannelType{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			types := []m.ChannelType{}
+			for _, target := range deliveryTargets(tt.channels) {
+				types = append(types, target.Type)
+			}
+			assert.Equal(t, tt.expected, types)
+		})
+	}
+}
+
+// outboxConfig retries a failed notification after a minute, twice at most, and leases the claimed ones for 10 minutes.
+var outboxConfig = &config.NotifierConfig{
+	MaxAttempts: 2, RetryBackoff: time.Minute, MaxRetryBackoff: time.Hour, Lease: 10 * time.Minute,
+}
+
+func TestNotifier_Enqueue(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	for _, channel := range []*m.DeliveryChannel{
+		{UserID: user.ID, Type: m.ChannelEmail, Address: "user@example.com", Enabled: true},
+		{UserID: user.ID, Type: m.ChannelWebhook, Address: "https://example.com/hook", Enabled: true},
+	} {
+		require.NoError(t, repos.DeliveryChannels.SaveDeliveryChannel(ctx, channel))
+	}
+	telegram := &recordingChannel{channelType: m.ChannelTelegram}
+	email := &recordingChannel{channelType: m.ChannelEmail}
+	n := NewNotifier(
+		outboxConfig, repos.Users, repos.Subscriptions, repos.Notifications,
+		repos.Digests, repos.UnitAlerts, store, telegram, email,
+	)
+	subscription := &m.Subscription{ID: 7, UserID: user.ID}
+	event := &m.UnitEvent{Type: m.UnitEventAvailable, Unit: m.Unit{ID: 1, City: "Москва", Storage: "Ленинский"}}
+
+	// Act
+	notification := newAvailableNotification(&event.Unit)
+	entry := m.Notification{
+		IdempotencyKey: idempotencyKey(subscription, event),
+		SubscriptionID: subscription.ID,
+		UnitID:         event.Unit.ID,
+		Subject:        notification.Subject,
+		Text:           notification.Text,
+	}
+	enqueued, err := n.enqueue(ctx, repos, user, entry)
+	require.NoError(t, err)
+	again, err := n.enqueue(ctx, repos, user, entry)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 2, enqueued, "the webhook channel is not configured")
+	assert.Zero(t, again)
+	due, err := repos.Notifications.GetDueNotifications(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	for _, notification := range due {
+		assert.Equal(t, "7:1:available:"+strconv.FormatInt(event.DetectedAt.Unix(), 10), notification.IdempotencyKey)
+		assert.Equal(t, m.NotificationStatusPending, notification.Status)
+		assert.Equal(t, "Unit available: Ленинский, Москва", notification.Subject)
+	}
+	assert.Empty(t, telegram.sent)
+}
+
+func TestNotifier_Dispatch(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// setup stores a user with a pending notification for each channel and returns the notifier delivering them.
+	setup := func(t *testing.T, channels ...Channel) (*Notifier, repository.NotificationRepository) {
+		t.Helper()
+
+		store := memory.NewStore()
+		repos := store.Repositories()
+		user := &m.User{TelegramID: 100}
+		require.NoError(t, repos.Users.CreateUser(ctx, user))
+		for _, channel := range channels {
+			_, err := repos.Notifications.EnqueueNotification(ctx, &m.Notification{
+				IdempotencyKey: "1:1:available:0",
+				UserID:         user.ID,
+				Channel:        channel.Type(),
+				Address:        "user@example.com",
+				Text:           "A unit is available",
+				Status:         m.NotificationStatusPending,
+				NextAttemptAt:  now,
+			})
+			require.NoError(t, err)
+		}
+		n := NewNotifier(
+			outboxConfig, repos.Users, repos.Subscriptions, repos.Notifications,
+			repos.Digests, repos.UnitAlerts, store, channels...,
+		)
+		n.now = func() time.Time { return now }
+		return n, repos.Notifications
+	}
+
+	t.Run("should mark a delivered notification as sent", func(t *testing.T) {
+		// Arrange
+		email := &recordingChannel{channelType: m.ChannelEmail}
+		n, notifications := setup(t, email)
+
+		// Act
+		err := n.dispatch(ctx)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, email.sent, 1)
+		assert.Equal(t, "user@example.com", email.sent[0].Address)
+		assert.Equal(t, int64(100), email.sent[0].User.TelegramID)
+		stored, err := notifications.GetNotificationByID(ctx, 1)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, m.NotificationStatusSent, stored.Status)
+		assert.Equal(t, 1, stored.Attempts)
+		require.NotNil(t, stored.SentAt)
+	})
+
+	t.Run("should retry a failed notification with backoff until it runs out of attempts", func(t *testing.T) {
+		// Arrange
+		email := &recordingChannel{channelType: m.ChannelEmail, fail: map[string]bool{"user@example.com": true}}
+		n, notifications := setup(t, email)
+
+		// Act
+		require.NoError(t, n.dispatch(ctx))
+		failed, err := notifications.GetNotificationByID(ctx, 1)
+		require.NoError(t, err)
+		require.NoError(t, n.dispatch(ctx))
+		n.now = func() time.Time { return now.Add(time.Minute) }
+		require.NoError(t, n.dispatch(ctx))
+		dead, err := notifications.GetNotificationByID(ctx, 1)
+		require.NoError(t, err)
+
+		// Assert
+		require.NotNil(t, failed)
+		assert.Equal(t, m.NotificationStatusFailed, failed.Status)
+		assert.Equal(t, 1, failed.Attempts)
+		assert.Equal(t, "delivery failed", failed.LastError)
+		assert.True(t, now.Add(time.Minute).Equal(failed.NextAttemptAt))
+		require.NotNil(t, dead)
+		assert.Equal(t, m.NotificationStatusDead, dead.Status)
+		assert.Equal(t, 2, dead.Attempts)
+	})
+
+	t.Run("should hold back a notification until the quiet hours of the user end", func(t *testing.T) {
+		// Arrange
+		email := &recordingChannel{channelType: m.ChannelEmail}
+		n, notifications := setup(t, email)
+		user, err := n.userRepo.GetUserByID(ctx, 1)
+		require.NoError(t, err)
+		user.TimeZone, user.QuietHoursStart, user.QuietHoursEnd = "UTC", 11*60, 13*60
+		require.NoError(t, n.userRepo.UpdateUser(ctx, user))
+
+		// Act
+		err = n.dispatch(ctx)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, email.sent)
+		stored, err := notifications.GetNotificationByID(ctx, 1)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, m.NotificationStatusPending, stored.Status)
+		assert.Zero(t, stored.Attempts)
+		assert.True(t, now.Add(time.Hour).Equal(stored.NextAttemptAt), "next attempt at %s", stored.NextAttemptAt)
+	})
+
+	t.Run("should give up on a notification of a channel that is not configured", func(t *testing.T) {
+		// Arrange
+		n, notifications := setup(t, &recordingChannel{channelType: m.ChannelWebhook})
+		delete(n.channels, m.ChannelWebhook)
+
+		// Act
+		err := n.dispatch(ctx)
+
+		// Assert
+		require.NoError(t, err)
+		stored, err := notifications.GetNotificationByID(ctx, 1)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, m.NotificationStatusDead, stored.Status)
+		assert.Equal(t, 1, stored.Attempts)
+	})
+}
+
+func TestNotifier_Digest(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) // A Saturday
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100, Active: true, TimeZone: "UTC"}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	newSubscription := func(mode string, weekday time.Weekday) *m.Subscription {
+		subscription := &m.Subscription{
+			UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+			DeliveryMode: mode, DigestTime: 9 * 60, DigestWeekday: weekday,
+		}
+		require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, subscription))
+		return subscription
+	}
+	daily, otherDaily := newSubscription(m.DeliveryModeDaily, 0), newSubscription(m.DeliveryModeDaily, 0)
+	weekly := newSubscription(m.DeliveryModeWeekly, time.Monday)
+	telegram := &recordingChannel{channelType: m.ChannelTelegram}
+	n := NewNotifier(
+		outboxConfig, repos.Users, repos.Subscriptions, repos.Notifications,
+		repos.Digests, repos.UnitAlerts, store, telegram,
+	)
+	n.now = func() time.Time { return now }
+	first := &m.UnitEvent{Type: m.UnitEventAvailable, Unit: m.Unit{
+		ID: 1, Name: "A-1", City: "Москва", Storage: "Ленинский", Size: "2 м²", Price: 3500, URL: "https://kladovkin.ru/1",
+	}}
+	second := &m.UnitEvent{Type: m.UnitEventAvailable, Unit: m.Unit{
+		ID: 2, Name: "A-2", City: "Москва", Storage: "Ленинский", Size: "2 м²", Price: 4000,
+	}}
+
+	// Act
+	require.NoError(t, n.notifySubscriber(ctx, daily, first))
+	require.NoError(t, n.notifySubscriber(ctx, otherDaily, first))
+	require.NoError(t, n.notifySubscriber(ctx, daily, second))
+	require.NoError(t, n.notifySubscriber(ctx, weekly, second))
+	require.NoError(t, n.sendDigests(ctx))
+	early, err := repos.Notifications.GetDueNotifications(ctx, now.Add(24*time.Hour), 10)
+	require.NoError(t, err)
+	n.now = func() time.Time { return time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC) }
+	require.NoError(t, n.sendDigests(ctx))
+
+	// Assert
+	assert.Empty(t, early, "the digest is not due before 09:00")
+	due, err := repos.Notifications.GetDueNotifications(ctx, n.now(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "digest:1:"+strconv.FormatInt(n.now().Unix(), 10), due[0].IdempotencyKey)
+	assert.Equal(t, "Digest: 2 units available", due[0].Subject)
+	assert.Equal(t, "2 units matching your subscriptions:\n"+
+		"\n"+
+		"1. Ленинский, Москва\n"+
+		"Unit: A-1, 2 м², 3500 ₽/month\n"+
+		"https://kladovkin.ru/1\n"+
+		"\n"+
+		"2. Ленинский, Москва\n"+
+		"Unit: A-2, 2 м², 4000 ₽/month", due[0].Text)
+
+	pending, err := repos.Digests.GetDueDigestItems(ctx, time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "the weekly digest is sent on Monday")
+	assert.Equal(t, weekly.ID, pending[0].SubscriptionID)
+	assert.Empty(t, telegram.sent)
+}
+
+// failingUsers is a UserRepository failing to retrieve one of the users.
+type failingUsers struct {
+	repository.UserRepository
+	failID int64
+}
+
+func (r failingUsers) GetUserByID(ctx context.Context, id int64) (*m.User, error) {
+	if id == r.failID {
+		return nil, errors.New("database is locked")
+	}
+	return r.UserRepository.GetUserByID(ctx, id)
+}
+
+func TestNotifier_Digest_Failure(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := memory.NewStore()
+	repos := store.Repositories()
+	failing, other := &m.User{TelegramID: 100, Active: true}, &m.User{TelegramID: 200, Active: true}
+	require.NoError(t, repos.Users.CreateUser(ctx, failing))
+	require.NoError(t, repos.Users.CreateUser(ctx, other))
+	for _, user := range []*m.User{failing, other} {
+		require.NoError(t, repos.Digests.AddDigestItem(ctx, &m.DigestItem{
+			UserID: user.ID, SubscriptionID: user.ID, DueAt: now,
+			Unit: m.Unit{ID: 1, Name: "A-1", City: "Москва", Storage: "Ленинский"},
+		}))
+	}
+	n := NewNotifier(
+		outboxConfig, failingUsers{UserRepository: repos.Users, failID: failing.ID}, repos.Subscriptions,
+		repos.Notifications, repos.Digests, repos.UnitAlerts, store,
+		&recordingChannel{channelType: m.ChannelTelegram},
+	)
+	n.now = func() time.Time { return now }
+
+	// Act
+	err := n.sendDigests(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	due, err := repos.Notifications.GetDueNotifications(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1, "the other user still gets their digest")
+	assert.Equal(t, other.ID, due[0].UserID)
+	pending, err := repos.Digests.GetDueDigestItems(ctx, now)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "the failed digest is kept for the next run")
+	assert.Equal(t, failing.ID, pending[0].UserID)
+}
+
+func TestUniqueUnits(t *testing.T) {
+	// Arrange
+	items := []*m.DigestItem{
+		{ID: 1, Unit: m.Unit{ID: 10, Price: 4000}},
+		{ID: 2, Unit: m.Unit{ID: 20, Price: 5000}},
+		{ID: 3, Unit: m.Unit{ID: 10, Price: 3500}},
+	}
+
+	// Act
+	unique := uniqueUnits(items)
+
+	// Assert
+	require.Len(t, unique, 2)
+	assert.Equal(t, int64(3), unique[0].ID, "the last item of a unit holds its latest state")
+	assert.Equal(t, int64(2), unique[1].ID)
+}
+
+// slowChannel is a recordingChannel taking a while to send, so concurrent dispatchers overlap.
+type slowChannel struct {
+	*recordingChannel
+}
+
+func (c slowChannel) Send(ctx context.Context, recipient Recipient, notification *Notification) error {
+	time.Sleep(time.Millisecond)
+	return c.recordingChannel.Send(ctx, recipient, notification)
+}
+
+func TestNotifier_Dispatch_Concurrent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	for i := range 20 {
+		_, err := repos.Notifications.EnqueueNotification(ctx, &m.Notification{
+			IdempotencyKey: "1:" + strconv.Itoa(i) + ":available:0",
+			UserID:         user.ID,
+			Channel:        m.ChannelTelegram,
+			Text:           "A unit is available",
+			Status:         m.NotificationStatusPending,
+			NextAttemptAt:  time.Now(),
+		})
+		require.NoError(t, err)
+	}
+	telegram := slowChannel{&recordingChannel{channelType: m.ChannelTelegram}}
+	newDispatcher := func() *Notifier {
+		return NewNotifier(
+			outboxConfig, repos.Users, repos.Subscriptions, repos.Notifications,
+			repos.Digests, repos.UnitAlerts, store, telegram,
+		)
+	}
+	first, second := newDispatcher(), newDispatcher()
+
+	// Act
+	var wg sync.WaitGroup
+	for _, n := range []*Notifier{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, n.dispatch(ctx))
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	assert.Len(t, telegram.sent, 20, "every notification is sent once")
+	due, err := repos.Notifications.GetDueNotifications(ctx, time.Now().Add(time.Hour), 100)
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := &config.NotifierConfig{RetryBackoff: time.Minute, MaxRetryBackoff: 10 * time.Minute}
+	n := NewNotifier(cfg, nil, nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			assert.Equal(t, tt.expected, n.retryBackoff(tt.attempts))
+		})
+	}
+}
+
+func TestEmailChannel(t *testing.T) {
+	// Arrange
+	server := newSMTPServer(t)
+	channel := NewEmailChannel(server.config())
+	notification := newAvailableNotification(&m.Unit{
+		Name: "Бокс B-10", City: "Москва", Storage: "Ленинский", Size: "2 м²", Price: 3500,
+	})
+
+	// Act
+	err := channel.Send(context.Background(), Recipient{Address: "user@example.com"}, notification)
+
+	// Assert
+	require.NoError(t, err)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, "bot@example.com", server.from)
+	assert.Equal(t, []string{"user@example.com"}, server.rcpt)
+	require.Len(t, server.messages, 1)
+
+	header, body, found := strings.Cut(server.messages[0], "\r\n\r\n")
+	require.True(t, found)
+	var subject string
+	for _, line := range strings.Split(header, "\r\n") {
+		if value, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject, err = new(mime.WordDecoder).DecodeHeader(value)
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, "Unit available: Ленинский, Москва", subject)
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll(notification.Text, "\n", "\r\n"), strings.TrimRight(string(decoded), "\r\n"))
+}
+
+func TestEmailChannel_SendVerificationCode(t *testing.T) {
+	// Arrange
+	server := newSMTPServer(t)
+	channel := NewEmailChannel(server.config())
+
+	// Act
+	err := channel.SendVerificationCode(context.Background(), "user@example.com", "ABC123")
+
+	// Assert
+	require.NoError(t, err)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, []string{"user@example.com"}, server.rcpt)
+	require.Len(t, server.messages, 1)
+	assert.Contains(t, server.messages[0], "/verify ABC123")
+}
+
+func TestEmailChannel_Errors(t *testing.T) {
+	t.Run("should fail without an address", func(t *testing.T) {
+		channel := NewEmailChannel(newSMTPServer(t).config())
+
+		err := channel.Send(context.Background(), Recipient{}, newAvailableNotification(&m.Unit{}))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should fail when the server is unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		port := listener.Addr().(*net.TCPAddr).Port
+		require.NoError(t, listener.Close())
+		channel := NewEmailChannel(&config.EmailConfig{
+			SMTPHost: "127.0.0.1", SMTPPort: port, From: "bot@example.com", Timeout: time.Second,
+		})
+
+		err = channel.Send(context.Background(), Recipient{Address: "user@example.com"}, newAvailableNotification(&m.Unit{}))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestWebhookChannel(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		expectedErr bool
+	}{
+		{"accepted", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"rejected", http.StatusBadRequest, true},
+		{"failing", http.StatusInternalServerError, true},
+		{"redirecting", http.StatusFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			var received Notification
+			var contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				_ = json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			channel := NewWebhookChannel(&config.WebhookConfig{Timeout: 5 * time.Second})
+			// The test server listens on the loopback interface, which the guarded transport refuses
+			channel.client.Transport = server.Client().Transport
+			notification := newAvailableNotification(&m.Unit{ID: 7, City: "Москва", Storage: "Ленинский", Price: 3500})
+
+			// Act
+			err := channel.Send(context.Background(), Recipient{Address: server.URL + "/hook"}, notification)
+
+			// Assert
+			if tt.expectedErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), strconv.Itoa(tt.status))
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, "application/json", contentType)
+			assert.Equal(t, *notification, received)
+		})
+	}
+}
+
+func TestNotifier_Cleanup(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	n := NewNotifier(
+		&config.NotifierConfig{Retention: time.Hour}, repos.Users, repos.Subscriptions, repos.Notifications,
+		repos.Digests, repos.UnitAlerts, store,
+	)
+	newNotification := func(key string) *m.Notification {
+		notification := &m.Notification{IdempotencyKey: key, UserID: 1, Channel: m.ChannelTelegram, Status: m.NotificationStatusPending}
+		_, err := repos.Notifications.EnqueueNotification(ctx, notification)
+		require.NoError(t, err)
+		return notification
+	}
+	sent, pending := newNotification("sent"), newNotification("pending")
+	sent.Status = m.NotificationStatusSent
+	require.NoError(t, repos.Notifications.UpdateNotification(ctx, sent))
+	n.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	// Act
+	err := n.Cleanup(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	stored, err := repos.Notifications.GetNotificationByID(ctx, sent.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored, "the sent notification is past the retention period")
+	stored, err = repos.Notifications.GetNotificationByID(ctx, pending.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored, "the pending notification is still to be delivered")
+}
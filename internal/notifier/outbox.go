@@ -0,0 +1,178 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/localtime"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+// dispatchBatchSize bounds the number of notifications loaded from the outbox at once.
+const dispatchBatchSize = 100
+
+// ErrUndeliverable is wrapped by the delivery errors retrying cannot fix, the notification is given up on right away.
+var ErrUndeliverable = errors.New("undeliverable")
+
+// enqueue puts the alert into the outbox once per enabled delivery channel of the user.
+// The entry carries the idempotency key and the content of the alert, the channel and delivery fields are filled in.
+// It returns the number of notifications enqueued, an alert enqueued before is not enqueued again.
+// The repositories are those of the transaction the alert is enqueued in.
+func (n *Notifier) enqueue(
+	ctx context.Context,
+	repos repository.Repositories,
+	user *m.User,
+	entry m.Notification,
+) (int, error) {
+	channels, err := repos.DeliveryChannels.GetDeliveryChannels(ctx, user.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retrieve delivery channels: %w", err)
+	}
+
+	enqueued := 0
+	for _, target := range deliveryTargets(channels) {
+		if _, ok := n.channels[target.Type]; !ok {
+			slog.Warn("Delivery channel is not configured", "userID", user.ID, "channel", target.Type)
+			continue
+		}
+
+		notification := entry
+		notification.UserID = user.ID
+		notification.Channel = target.Type
+		notification.Address = target.Address
+		notification.Status = m.NotificationStatusPending
+		notification.NextAttemptAt = n.now()
+		stored, err := repos.Notifications.EnqueueNotification(ctx, &notification)
+		if err != nil {
+			return enqueued, err
+		}
+		if stored {
+			enqueued++
+		}
+	}
+	return enqueued, nil
+}
+
+// idempotencyKey identifies the alert about the event for the subscription.
+func idempotencyKey(subscription *m.Subscription, event *m.UnitEvent) string {
+	return fmt.Sprintf("%d:%d:%s:%d", subscription.ID, event.Unit.ID, event.Type, event.DetectedAt.Unix())
+}
+
+// Cleanup deletes the sent and given up notifications older than the retention period. It is run by the scheduler.
+func (n *Notifier) Cleanup(ctx context.Context) error {
+	deleted, err := n.notificationRepo.DeleteNotificationsBefore(ctx, n.now().Add(-n.cfg.Retention))
+	if err != nil {
+		return fmt.Errorf("failed to clean up notifications: %w", err)
+	}
+	slog.Info("Cleaned up notifications", "deleted", deleted)
+	return nil
+}
+
+// dispatch delivers the due notifications of the outbox.
+// The notifications are claimed for the lease period before they are sent, so the dispatchers of several instances
+// do not send the same notification. A claimed notification left unsent, e.g. by a shutdown, is due again
+// once the lease runs out.
+func (n *Notifier) dispatch(ctx context.Context) error {
+	n.dispatchMu.Lock()
+	defer n.dispatchMu.Unlock()
+
+	for {
+		now := n.now()
+		due, err := n.notificationRepo.ClaimDueNotifications(ctx, now, now.Add(n.cfg.Lease), dispatchBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to claim due notifications: %w", err)
+		}
+
+		for _, notification := range due {
+			if err := n.deliverNotification(ctx, notification); err != nil {
+				return err
+			}
+		}
+		if len(due) < dispatchBatchSize {
+			return nil
+		}
+	}
+}
+
+// deliverNotification attempts to deliver a notification and records the outcome in the outbox.
+// A failed notification is retried with an exponential backoff until it runs out of attempts.
+// A notification due in the quiet hours of the user is held back until they end.
+func (n *Notifier) deliverNotification(ctx context.Context, notification *m.Notification) error {
+	user, err := n.userRepo.GetUserByID(ctx, notification.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve user %d: %w", notification.UserID, err)
+	}
+	if user != nil {
+		if until, quiet := localtime.QuietUntil(user, n.now()); quiet {
+			notification.NextAttemptAt = until
+			if err := n.notificationRepo.UpdateNotification(ctx, notification); err != nil {
+				return fmt.Errorf("failed to update notification %d: %w", notification.ID, err)
+			}
+			return nil
+		}
+	}
+
+	sendErr := n.send(ctx, user, notification)
+	if ctx.Err() != nil {
+		// Shutting down, the attempt does not count
+		return ctx.Err()
+	}
+
+	now := n.now()
+	notification.Attempts++
+	switch {
+	case sendErr == nil:
+		notification.Status = m.NotificationStatusSent
+		notification.LastError = ""
+		notification.SentAt = &now
+	case errors.Is(sendErr, ErrUndeliverable) || notification.Attempts >= n.cfg.MaxAttempts:
+		notification.Status = m.NotificationStatusDead
+		notification.LastError = sendErr.Error()
+		slog.Error("Giving up on notification", "notificationID", notification.ID, "userID", notification.UserID,
+			"channel", notification.Channel, "attempts", notification.Attempts, "error", sendErr)
+	default:
+		notification.Status = m.NotificationStatusFailed
+		notification.LastError = sendErr.Error()
+		notification.NextAttemptAt = now.Add(n.retryBackoff(notification.Attempts))
+		slog.Warn("Failed to send notification", "notificationID", notification.ID, "userID", notification.UserID,
+			"channel", notification.Channel, "attempts", notification.Attempts, "retryAt", notification.NextAttemptAt,
+			"error", sendErr)
+	}
+
+	if err := n.notificationRepo.UpdateNotification(ctx, notification); err != nil {
+		return fmt.Errorf("failed to update notification %d: %w", notification.ID, err)
+	}
+	return nil
+}
+
+// send delivers a notification to the user through its channel.
+func (n *Notifier) send(ctx context.Context, user *m.User, notification *m.Notification) error {
+	channel, ok := n.channels[notification.Channel]
+	if !ok {
+		return fmt.Errorf("%w: channel %s is not configured", ErrUndeliverable, notification.Channel)
+	}
+	if user == nil {
+		return fmt.Errorf("%w: user %d not found", ErrUndeliverable, notification.UserID)
+	}
+
+	recipient := Recipient{User: user, Address: notification.Address}
+	return channel.Send(ctx, recipient, &Notification{
+		Subject: notification.Subject,
+		Text:    notification.Text,
+		Unit:    notification.Unit,
+	})
+}
+
+// retryBackoff returns the delay before the next attempt after the given number of failed attempts.
+// The delay doubles with every attempt, starting at the configured backoff, up to the configured maximum.
+func (n *Notifier) retryBackoff(attempts int) time.Duration {
+	backoff := n.cfg.RetryBackoff
+	for i := 1; i < attempts && backoff < n.cfg.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, n.cfg.MaxRetryBackoff)
+}
@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/netguard"
+)
+
+// WebhookChannel delivers notifications by posting them as JSON to the URL of the user.
+// The URLs are set by the users, so only public addresses are called and redirects are not followed.
+type WebhookChannel struct {
+	client *http.Client
+}
+
+// NewWebhookChannel creates a new WebhookChannel.
+func NewWebhookChannel(cfg *config.WebhookConfig) *WebhookChannel {
+	return &WebhookChannel{client: netguard.NewClient(cfg.Timeout)}
+}
+
+// Type returns the channel type.
+func (c *WebhookChannel) Type() m.ChannelType {
+	return m.ChannelWebhook
+}
+
+// Send posts the notification to the webhook URL of the recipient.
+// Any response status other than 2xx is an error.
+func (c *WebhookChannel) Send(ctx context.Context, recipient Recipient, notification *Notification) error {
+	if recipient.Address == "" {
+		return errors.New("no webhook URL")
+	}
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode the webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, recipient.Address, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create the webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kladovkin-telegram-bot")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call the webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	// Drain the body, so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
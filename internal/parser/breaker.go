@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen refuses the calls until the cooldown has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial call through, which decides whether the breaker closes or opens again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a circuit breaker pausing the calls to a failing dependency.
+// It opens after the threshold of failures in a row and lets a trial call through once the cooldown has passed.
+// Every allowed call is to be followed by Success, Failure or, if its outcome is unknown, Release.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(ctx context.Context, from, to BreakerState)
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool // The trial call of the half-open breaker is in flight
+}
+
+// NewBreaker creates a new closed Breaker, which never opens if the threshold is zero.
+// The state changes are reported to onChange, which may be nil, with the context of the call causing them.
+func NewBreaker(
+	threshold int, cooldown time.Duration, onChange func(ctx context.Context, from, to BreakerState),
+) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		onChange:  onChange,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Allow reports whether a call may be made.
+// An open breaker turns half-open once the cooldown has passed and lets the call through as the trial.
+// The other calls are refused while the trial is in flight.
+func (b *Breaker) Allow(ctx context.Context) bool {
+	b.mu.Lock()
+	from := b.state
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = BreakerHalfOpen
+	}
+	allowed := b.state == BreakerClosed || (b.state == BreakerHalfOpen && !b.probing)
+	if b.state == BreakerHalfOpen && allowed {
+		b.probing = true
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.report(ctx, from, to)
+	return allowed
+}
+
+// Success records a successful call, which closes the breaker.
+func (b *Breaker) Success(ctx context.Context) {
+	b.mu.Lock()
+	from := b.state
+	b.failures = 0
+	b.state = BreakerClosed
+	b.probing = false
+	b.mu.Unlock()
+
+	b.report(ctx, from, BreakerClosed)
+}
+
+// Failure records a failed call.
+// The breaker opens when the failures in a row reach the threshold or the trial call fails.
+func (b *Breaker) Failure(ctx context.Context) {
+	b.mu.Lock()
+	from := b.state
+	b.failures++
+	if b.threshold > 0 && (b.state == BreakerHalfOpen || b.failures >= b.threshold) {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+	b.probing = false
+	to := b.state
+	b.mu.Unlock()
+
+	b.report(ctx, from, to)
+}
+
+// Release gives up a call whose outcome is unknown, e.g. one cancelled by the shutdown,
+// so the half-open breaker lets the next trial call through.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// report hands a state change to the handler, outside the lock so the handler may take its time.
+func (b *Breaker) report(ctx context.Context, from, to BreakerState) {
+	if from != to && b.onChange != nil {
+		b.onChange(ctx, from, to)
+	}
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// unitKey identifies a unit across parser runs.
+type unitKey struct {
+	provider   string
+	externalID string
+}
+
+// keyOf returns the key of the unit.
+func keyOf(unit *m.Unit) unitKey {
+	return unitKey{provider: unit.Provider, externalID: unit.ExternalID}
+}
+
+// diffUnits compares the stored units of a provider with the freshly extracted ones and returns the changes.
+// A unit can produce several events at once, e.g. when it becomes available at a new price.
+// The events of the fresh units follow their order, removed units are reported last in the stored order.
+func diffUnits(stored []*m.Unit, fresh []m.Unit, now time.Time) []m.UnitEvent {
+	known := make(map[unitKey]*m.Unit, len(stored))
+	for _, unit := range stored {
+		known[keyOf(unit)] = unit
+	}
+
+	var events []m.UnitEvent
+	seen := make(map[unitKey]bool, len(fresh))
+	for i := range fresh {
+		unit := fresh[i]
+		key := keyOf(&unit)
+		seen[key] = true
+
+		previous, exists := known[key]
+		if !exists {
+			events = append(events, m.UnitEvent{Type: m.UnitEventNew, Unit: unit, DetectedAt: now})
+			continue
+		}
+
+		if unit.Available != previous.Available {
+			eventType := m.UnitEventUnavailable
+			if unit.Available {
+				eventType = m.UnitEventAvailable
+			}
+			events = append(events, m.UnitEvent{Type: eventType, Unit: unit, Previous: previous, DetectedAt: now})
+		}
+		if unit.Price != previous.Price {
+			events = append(events, m.UnitEvent{Type: m.UnitEventPriceChanged, Unit: unit, Previous: previous, DetectedAt: now})
+		}
+	}
+
+	for _, unit := range stored {
+		if !seen[keyOf(unit)] {
+			events = append(events, m.UnitEvent{Type: m.UnitEventRemoved, Unit: *unit, Previous: unit, DetectedAt: now})
+		}
+	}
+
+	return events
+}
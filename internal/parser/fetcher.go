@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+)
+
+// StatusError is returned for a response with an unexpected status code.
+type StatusError struct {
+	Code       int
+	RetryAfter time.Duration // Asked by the Retry-After header, zero if absent
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
+// Temporary reports whether the request may succeed when retried.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= http.StatusInternalServerError
+}
+
+// Fetcher downloads pages over HTTP.
+// A request failing with a network error, a rate limit or a server error is retried with an exponential backoff.
+type Fetcher struct {
+	cfg    *config.ParserConfig
+	client *http.Client
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewFetcher creates a new Fetcher with the timeout and the retry policy of the parser configuration.
+func NewFetcher(cfg *config.ParserConfig) *Fetcher {
+	return &Fetcher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		sleep:  sleep,
+	}
+}
+
+// Get downloads the page at the URL.
+func (f *Fetcher) Get(ctx context.Context, url string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		data, err := f.get(ctx, url)
+		if err == nil {
+			return data, nil
+		}
+		if attempt >= f.cfg.Retries || !retryable(ctx, err) {
+			return nil, err
+		}
+
+		wait := f.backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			if statusErr.RetryAfter > f.cfg.MaxRetryBackoff {
+				return nil, fmt.Errorf("%w, retry asked after %s", err, statusErr.RetryAfter)
+			}
+			wait = statusErr.RetryAfter
+		}
+
+		slog.Warn("Failed to fetch page, retrying", "url", url, "attempt", attempt+1, "backoff", wait, "error", err)
+		if err := f.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// get makes a single request.
+func (f *Fetcher) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// backoff returns the wait before the retry following the attempt.
+// The wait doubles with every attempt up to the maximum and is spread over its upper half,
+// so the retries of several instances do not hit the server together.
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	wait := f.cfg.RetryBackoff
+	for range attempt {
+		if wait >= f.cfg.MaxRetryBackoff {
+			break
+		}
+		wait *= 2
+	}
+	wait = min(wait, f.cfg.MaxRetryBackoff)
+	if half := wait / 2; half > 0 {
+		wait = half + rand.N(half+1)
+	}
+	return wait
+}
+
+// retryable reports whether a failed request may succeed when retried.
+// Only the status errors tell, every other error comes from the network unless the context is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	return true
+}
+
+// parseRetryAfter parses the Retry-After header, which holds either seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// sleep waits for the duration unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
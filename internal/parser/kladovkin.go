@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// KladovkinSourceName is the provider name of the kladovkin.ru source.
+const KladovkinSourceName = "kladovkin"
+
+// CSS classes and attributes used by the kladovkin.ru catalogue markup.
+const (
+	classCity           = "city"
+	classStorage        = "storage"
+	classStorageName    = "storage__name"
+	classStorageAddress = "storage__address"
+	classUnit           = "unit"
+	classUnitAvailable  = "unit--available"
+	classUnitName       = "unit__name"
+	classUnitSize       = "unit__size"
+	classUnitDimension  = "unit__dimension"
+	classUnitPrice      = "unit__price"
+
+	attrCity   = "data-city"
+	attrUnitID = "data-unit-id"
+)
+
+// ErrNoUnits is returned when a page does not contain any units.
+// It usually means that the markup of the page has changed.
+var ErrNoUnits = errors.New("no units found on the page")
+
+// KladovkinSource scrapes units from the kladovkin.ru catalogue page.
+type KladovkinSource struct {
+	url     *url.URL
+	fetcher *Fetcher
+}
+
+// NewKladovkinSource creates a new KladovkinSource for the configured catalogue URL.
+func NewKladovkinSource(cfg *config.ParserConfig) (Source, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("kladovkin catalogue URL is not configured")
+	}
+	catalogueURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kladovkin catalogue URL: %w", err)
+	}
+
+	return &KladovkinSource{
+		url:     catalogueURL,
+		fetcher: NewFetcher(cfg),
+	}, nil
+}
+
+// Name returns the provider name of the source.
+func (s *KladovkinSource) Name() string {
+	return KladovkinSourceName
+}
+
+// Fetch downloads the catalogue page, retrying the transient failures.
+func (s *KladovkinSource) Fetch(ctx context.Context) ([]byte, error) {
+	return s.fetcher.Get(ctx, s.url.String())
+}
+
+// Extract parses the catalogue page and extracts the units from it.
+func (s *KladovkinSource) Extract(data []byte) ([]m.Unit, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return extractData(doc, s.url)
+}
+
+// extractData extracts the unit information from the parsed HTML document.
+//
+// The catalogue page is expected to consist of city sections, each holding
+// storage articles with a table of units:
+//
+//	section.city[data-city]
+//	  article.storage
+//	    .storage__name, .storage__address
+//	    tr.unit[data-unit-id] (.unit--available when the unit is free)
+//	      .unit__name (with a link to the unit page), .unit__size, .unit__dimension, .unit__price
+//
+// Relative unit links are resolved against base.
+func extractData(doc *html.Node, base *url.URL) ([]m.Unit, error) {
+	var units []m.Unit
+
+	for _, cityNode := range findAll(doc, classCity) {
+		city := attr(cityNode, attrCity)
+		if city == "" {
+			slog.Warn("Skipping city section without a name")
+			continue
+		}
+
+		for _, storageNode := range findAll(cityNode, classStorage) {
+			storage := textOf(findFirst(storageNode, classStorageName))
+			if storage == "" {
+				slog.Warn("Skipping storage without a name", "city", city)
+				continue
+			}
+			address := textOf(findFirst(storageNode, classStorageAddress))
+
+			for _, unitNode := range findAll(storageNode, classUnit) {
+				unit, err := extractUnit(unitNode, base)
+				if err != nil {
+					slog.Debug("Skipping unit row", "city", city, "storage", storage, "error", err)
+					continue
+				}
+				unit.City = city
+				unit.Storage = storage
+				unit.Description = address
+				units = append(units, unit)
+			}
+		}
+	}
+
+	if len(units) == 0 {
+		return nil, ErrNoUnits
+	}
+
+	return units, nil
+}
+
+// extractUnit extracts a single unit from a unit row.
+// City, storage and description are filled in by the caller.
+func extractUnit(n *html.Node, base *url.URL) (m.Unit, error) {
+	id := attr(n, attrUnitID)
+	if id == "" {
+		return m.Unit{}, errors.New("unit row has no identifier")
+	}
+
+	nameNode := findFirst(n, classUnitName)
+	name := textOf(nameNode)
+	if name == "" {
+		return m.Unit{}, fmt.Errorf("unit %s has no name", id)
+	}
+
+	size := textOf(findFirst(n, classUnitSize))
+	if size == "" {
+		return m.Unit{}, fmt.Errorf("unit %s has no size", id)
+	}
+
+	price, err := parsePrice(textOf(findFirst(n, classUnitPrice)))
+	if err != nil {
+		return m.Unit{}, fmt.Errorf("unit %s: %w", id, err)
+	}
+
+	return m.Unit{
+		ExternalID: id,
+		Name:       name,
+		Size:       size,
+		Dimension:  textOf(findFirst(n, classUnitDimension)),
+		Price:      price,
+		Available:  hasClass(n, classUnitAvailable),
+		URL:        unitLink(nameNode, base),
+	}, nil
+}
+
+// unitLink returns the absolute URL of the first link inside n, or an empty string if there is none.
+func unitLink(n *html.Node, base *url.URL) string {
+	link := findElement(n, "a")
+	if link == nil {
+		return ""
+	}
+
+	ref, err := url.Parse(attr(link, "href"))
+	if err != nil || ref.String() == "" {
+		return ""
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// parsePrice converts a price such as "6 750,50 ₽/мес" into a number.
+// Group separators and the currency suffix are ignored, a comma is treated as the decimal separator.
+func parsePrice(s string) (float64, error) {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == ',' || r == '.':
+			b.WriteRune('.')
+		case unicode.IsSpace(r):
+			// Group separator, e.g. a regular or a non-breaking space.
+		default:
+			// The currency sign and the period suffix end the number.
+			if b.Len() > 0 {
+				return parseNumber(b.String(), s)
+			}
+		}
+	}
+	return parseNumber(b.String(), s)
+}
+
+// parseNumber parses the cleaned-up price number, raw is used for error messages only.
+func parseNumber(number, raw string) (float64, error) {
+	if number == "" {
+		return 0, fmt.Errorf("invalid price %q", raw)
+	}
+	price, err := strconv.ParseFloat(strings.TrimSuffix(number, "."), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price %q: %w", raw, err)
+	}
+	return price, nil
+}
+
+// findAll returns all descendants of n that have the given class, in document order.
+// Matching nodes are not searched for nested matches.
+func findAll(n *html.Node, class string) []*html.Node {
+	var nodes []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && hasClass(c, class) {
+			nodes = append(nodes, c)
+			continue
+		}
+		nodes = append(nodes, findAll(c, class)...)
+	}
+	return nodes
+}
+
+// findFirst returns the first descendant of n that has the given class, or nil.
+func findFirst(n *html.Node, class string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && hasClass(c, class) {
+			return c
+		}
+		if found := findFirst(c, class); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findElement returns the first descendant of n with the given tag name, or nil.
+func findElement(n *html.Node, tag string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == tag {
+			return c
+		}
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// hasClass reports whether the element has the given class.
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// attr returns the value of the attribute with the given key, or an empty string.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// textOf returns the text content of n with whitespace collapsed. A nil node yields an empty string.
+func textOf(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
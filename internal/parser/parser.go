@@ -2,125 +2,237 @@ package parser
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
+	"sort"
 	"time"
 
 	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
 
 	"github.com/movax01h/kladovkin-telegram-bot/config"
 	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
-	"golang.org/x/net/html"
 )
 
-// Parser handles the logic for parsing HTML data.
+// EventHandler receives the unit events detected by the parser.
+type EventHandler interface {
+	HandleUnitEvents(ctx context.Context, events []m.UnitEvent) error
+}
+
+// Alerter notifies the admin about the sources the parser stopped scraping.
+type Alerter interface {
+	SendErrorNotification(ctx context.Context, text string) error
+}
+
+// ErrSourcePaused is returned for a source that is not scraped after repeated failures.
+var ErrSourcePaused = errors.New("scraping is paused after repeated failures")
+
+// Parser handles the logic for scraping units from the enabled sources.
 type Parser struct {
-	cfg              *config.ParserConfig
-	userRepo         repository.UserRepository
-	unitRepo         repository.UnitRepository
-	subscriptionRepo repository.SubscriptionRepository
-	client           *http.Client
+	cfg        *config.ParserConfig
+	sources    []Source
+	breakers   map[string]*Breaker
+	transactor repository.Transactor
+	handlers   []EventHandler
+	alerter    Alerter
 }
 
 // NewParser creates a new Parser instance.
-func NewParser(cfg *config.ParserConfig, userRepo repository.UserRepository, unitRepo repository.UnitRepository, subscriptionRepo repository.SubscriptionRepository) *Parser {
-	return &Parser{
-		cfg:              cfg,
-		userRepo:         userRepo,
-		unitRepo:         unitRepo,
-		subscriptionRepo: subscriptionRepo,
-		client:           &http.Client{Timeout: 10 * time.Second},
+// The parser stores the units of every run through a unit of work of the transactor.
+// Every source has a circuit breaker pausing its scraping after the configured number of failed runs in a row.
+func NewParser(cfg *config.ParserConfig, sources []Source, transactor repository.Transactor) *Parser {
+	p := &Parser{
+		cfg:        cfg,
+		sources:    sources,
+		breakers:   make(map[string]*Breaker, len(sources)),
+		transactor: transactor,
+	}
+	for _, source := range sources {
+		name := source.Name()
+		p.breakers[name] = NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown, func(ctx context.Context, from, to BreakerState) {
+			p.breakerChanged(ctx, name, from, to)
+		})
 	}
+	return p
 }
 
-// Start initiates the parsing process and runs it in a loop.
-func (p *Parser) Start(ctx context.Context) error {
-	slog.Info("Parser started")
-	ticker := time.NewTicker(time.Duration(p.cfg.Interval))
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("Parser shutting down")
-			return nil
-		case <-ticker.C:
-			slog.Info("Running parser")
-			if err := p.parseAndStoreData(); err != nil {
-				slog.Error("Failed to parse and store data", "error", err)
-				return err
-			}
-		}
+// AddEventHandler registers a handler that receives the unit events of every parser run.
+// Handlers must be added before the parser is started.
+func (p *Parser) AddEventHandler(h EventHandler) {
+	p.handlers = append(p.handlers, h)
+}
+
+// SetAlerter makes the parser notify the admin when the scraping of a source is paused or resumed.
+// The alerter must be set before the parser is started.
+func (p *Parser) SetAlerter(a Alerter) {
+	p.alerter = a
+}
+
+// Run scrapes the enabled sources once, stores the units and publishes the detected changes.
+// It is run by the scheduler.
+func (p *Parser) Run(ctx context.Context) error {
+	if err := p.parseAndStoreData(ctx); err != nil {
+		return fmt.Errorf("failed to parse and store data: %w", err)
 	}
+	return nil
 }
 
-// parseAndStoreData fetches the HTML data, parses it, and stores the relevant information.
-func (p *Parser) parseAndStoreData() error {
-	resp, err := p.client.Get(p.cfg.URL)
+// parseAndStoreData scrapes every enabled source, stores the extracted units and publishes the detected changes.
+// A failing source does not prevent the units of the other sources from being stored.
+// The units of all sources are stored in a single transaction, so readers never see a half-updated catalogue.
+// The changes are stored in the same transaction, so they are handed over even if publishing them is interrupted.
+func (p *Parser) parseAndStoreData(ctx context.Context) error {
+	// Fetch before opening the transaction, the sources may take a while to answer
+	var errs []error
+	fresh := make(map[string][]m.Unit, len(p.sources))
+	for _, source := range p.sources {
+		units, err := p.parseSource(ctx, source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %s: %w", source.Name(), err))
+			continue
+		}
+		slog.Info("Extracted units", "source", source.Name(), "count", len(units))
+		fresh[source.Name()] = units
+	}
+	if len(fresh) == 0 {
+		return errors.Join(errs...)
+	}
+
+	var events []m.UnitEvent
+	err := p.transactor.WithTx(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		var err error
+		events, err = storeData(ctx, repos.Units, fresh)
+		if err != nil {
+			return err
+		}
+		if err := repos.UnitEvents.AddUnitEvents(ctx, events); err != nil {
+			return fmt.Errorf("failed to store unit events: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return errors.Join(append(errs, err)...)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	if len(events) > 0 {
+		p.publish(ctx, events)
 	}
 
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		return err
+	return errors.Join(errs...)
+}
+
+// parseSource fetches the catalogue of the source and extracts the units tagged with the provider name.
+// A failed fetch counts towards the breaker of the source, a paused source is not fetched at all.
+func (p *Parser) parseSource(ctx context.Context, source Source) ([]m.Unit, error) {
+	breaker := p.breakers[source.Name()]
+	if breaker != nil && !breaker.Allow(ctx) {
+		return nil, ErrSourcePaused
 	}
 
-	// Extract data from the parsed HTML
-	users, units, subscriptions := p.extractData(doc)
+	data, err := source.Fetch(ctx)
+	if breaker != nil {
+		switch {
+		case ctx.Err() != nil:
+			breaker.Release()
+		case err != nil:
+			breaker.Failure(ctx)
+		default:
+			breaker.Success(ctx)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch: %w", err)
+	}
 
-	// Store the extracted data in the database
-	if err := p.storeData(users, units, subscriptions); err != nil {
-		return err
+	units, err := source.Extract(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract units: %w", err)
 	}
 
-	return nil
+	for i := range units {
+		units[i].Provider = source.Name()
+	}
+	return units, nil
 }
 
-// extractData extracts the user, unit, and subscription information from the parsed HTML document.
-func (p *Parser) extractData(doc *html.Node) ([]m.User, []m.Unit, []m.Subscription) {
-	var users []m.User
-	var units []m.Unit
-	var subscriptions []m.Subscription
+// breakerChanged logs a state change of the breaker of a source.
+// The admin is alerted only when the scraping is paused or resumed, the trial fetches in between are just logged,
+// so a long outage is reported once.
+func (p *Parser) breakerChanged(ctx context.Context, source string, from, to BreakerState) {
+	slog.Warn("Source breaker changed state", "source", source, "from", from, "to", to)
+
+	var text string
+	switch {
+	case from == BreakerClosed && to == BreakerOpen:
+		text = fmt.Sprintf("Scraping of %s is paused for %s after %d failed fetches in a row",
+			source, p.cfg.BreakerCooldown, p.cfg.BreakerThreshold)
+	case from != BreakerClosed && to == BreakerClosed:
+		text = fmt.Sprintf("Scraping of %s has resumed", source)
+	default:
+		return
+	}
+	if p.alerter == nil {
+		return
+	}
+	if err := p.alerter.SendErrorNotification(ctx, text); err != nil {
+		slog.Error("Failed to notify the admin", "error", err)
+	}
+}
 
-	// TODO: Implement the HTML parsing logic here to extract relevant data
-	// You would navigate through the HTML nodes, looking for tables, rows, and columns,
-	// and then populate the users, units, and subscriptions slices with the extracted data.
+// storeData saves the extracted units of every provider, deletes the units their provider no longer lists
+// and returns the changes compared to the previously stored units.
+func storeData(ctx context.Context, unitRepo repository.UnitRepository, fresh map[string][]m.Unit) ([]m.UnitEvent, error) {
+	stored, err := unitRepo.GetAllUnits(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stored units: %w", err)
+	}
+	storedByProvider := make(map[string][]*m.Unit)
+	for _, unit := range stored {
+		storedByProvider[unit.Provider] = append(storedByProvider[unit.Provider], unit)
+	}
 
-	return users, units, subscriptions
-}
+	providers := make([]string, 0, len(fresh))
+	for provider := range fresh {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	now := time.Now()
+	var events []m.UnitEvent
+	for _, provider := range providers {
+		units := fresh[provider]
+		for i := range units {
+			units[i].CreatedAt = now
+			units[i].UpdatedAt = now
+		}
 
-// storeData saves the extracted data into the database using the repositories.
-func (p *Parser) storeData(users []m.User, units []m.Unit, subscriptions []m.Subscription) error {
-	// Store users
-	for _, user := range users {
-		if err := p.userRepo.CreateUser(&user); err != nil {
-			slog.Error("Failed to save user", "userID", user.ID, "error", err)
-			continue
+		// The IDs assigned by the database are written back into the units
+		if err := unitRepo.UpsertUnits(ctx, units); err != nil {
+			return nil, fmt.Errorf("source %s: %w", provider, err)
 		}
-	}
 
-	// Store units
-	for _, unit := range units {
-		if err := p.unitRepo.CreateUnit(&unit); err != nil {
-			slog.Error("Failed to save unit", "unitID", unit.ID, "error", err)
-			continue
+		// Compare with the previous run only after storing, so new units carry their IDs
+		providerEvents := diffUnits(storedByProvider[provider], units, now)
+		for _, event := range providerEvents {
+			if event.Type != m.UnitEventRemoved {
+				continue
+			}
+			if err := unitRepo.DeleteUnit(ctx, event.Unit.ID); err != nil {
+				return nil, fmt.Errorf("source %s: %w", provider, err)
+			}
 		}
+		slog.Info("Detected unit changes", "source", provider, "count", len(providerEvents))
+		events = append(events, providerEvents...)
 	}
 
-	// Store subscriptions
-	for _, subscription := range subscriptions {
-		if err := p.subscriptionRepo.CreateSubscription(&subscription); err != nil {
-			slog.Error("Failed to save subscription", "subscriptionID", subscription.ID, "error", err)
-			continue
+	return events, nil
+}
+
+// publish hands the events over to the registered handlers.
+func (p *Parser) publish(ctx context.Context, events []m.UnitEvent) {
+	for _, h := range p.handlers {
+		if err := h.HandleUnitEvents(ctx, events); err != nil {
+			slog.Error("Failed to handle unit events", "error", err)
 		}
 	}
-
-	return nil
 }
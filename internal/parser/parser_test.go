@@ -1 +1,584 @@
 package parser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/memory"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// fixtureBaseURL is the URL the relative links of the fixtures are resolved against.
+var fixtureBaseURL = &url.URL{Scheme: "https", Host: "kladovkin.ru"}
+
+// parseFixture parses an HTML fixture from the testdata directory.
+func parseFixture(t *testing.T, name string) *html.Node {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	doc, err := html.Parse(f)
+	require.NoError(t, err)
+	return doc
+}
+
+// TestExtractData tests the extractData function against golden files.
+// A change in the kladovkin.ru markup should show up as a difference to the golden file.
+// Run the tests with -update to regenerate the golden files after a reviewed markup change.
+func TestExtractData(t *testing.T) {
+	// Arrange
+	doc := parseFixture(t, "catalogue.html")
+
+	// Act
+	units, err := extractData(doc, fixtureBaseURL)
+	require.NoError(t, err)
+
+	// Assert
+	got, err := json.MarshalIndent(units, "", "  ")
+	require.NoError(t, err)
+
+	golden := filepath.Join("testdata", "catalogue.golden.json")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, append(got, '\n'), 0o600))
+	}
+
+	want, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+}
+
+// TestExtractData_NoUnits tests that a page without units is reported as an error
+// instead of silently emptying the catalogue.
+func TestExtractData_NoUnits(t *testing.T) {
+	// Arrange
+	doc := parseFixture(t, "empty.html")
+
+	// Act
+	units, err := extractData(doc, fixtureBaseURL)
+
+	// Assert
+	require.ErrorIs(t, err, ErrNoUnits)
+	assert.Empty(t, units)
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+		hasError bool
+	}{
+		{"1 990 ₽/мес", 1990, false},
+		{"1 990 ₽/мес", 1990, false},
+		{"6 750,50 ₽/мес", 6750.5, false},
+		{"3900", 3900, false},
+		{"от 2 100 ₽", 2100, false},
+		{"по запросу", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			price, err := parsePrice(tt.input)
+			if tt.hasError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.InDelta(t, tt.expected, price, 0.001)
+			}
+		})
+	}
+}
+
+// fakeSource is a Source returning predefined units.
+type fakeSource struct {
+	name  string
+	units []m.Unit
+	err   error
+}
+
+func (s *fakeSource) Name() string { return s.name }
+
+func (s *fakeSource) Fetch(_ context.Context) ([]byte, error) { return nil, s.err }
+
+func (s *fakeSource) Extract(_ []byte) ([]m.Unit, error) {
+	units := make([]m.Unit, len(s.units))
+	copy(units, s.units)
+	return units, nil
+}
+
+func TestRegistry(t *testing.T) {
+	newFake := func(name string) SourceFactory {
+		return func(_ *config.ParserConfig) (Source, error) { return &fakeSource{name: name}, nil }
+	}
+
+	t.Run("should build enabled sources in the configured order", func(t *testing.T) {
+		// Arrange
+		r := NewRegistry()
+		require.NoError(t, r.Register("b", newFake("b")))
+		require.NoError(t, r.Register("a", newFake("a")))
+
+		// Act
+		sources, err := r.Build(&config.ParserConfig{Sources: []string{"b", "a"}})
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, sources, 2)
+		assert.Equal(t, "b", sources[0].Name())
+		assert.Equal(t, "a", sources[1].Name())
+		assert.Equal(t, []string{"a", "b"}, r.Names())
+	})
+
+	t.Run("should reject duplicate registration", func(t *testing.T) {
+		r := NewRegistry()
+		require.NoError(t, r.Register("a", newFake("a")))
+		assert.Error(t, r.Register("a", newFake("a")))
+	})
+
+	t.Run("should reject unknown source", func(t *testing.T) {
+		_, err := NewRegistry().Build(&config.ParserConfig{Sources: []string{"unknown"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("default registry should contain kladovkin", func(t *testing.T) {
+		assert.Contains(t, DefaultRegistry().Names(), KladovkinSourceName)
+	})
+}
+
+// TestKladovkinSource tests fetching and extracting the catalogue from a local server.
+func TestKladovkinSource(t *testing.T) {
+	// Arrange
+	page, err := os.ReadFile(filepath.Join("testdata", "catalogue.html"))
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(page)
+	}))
+	defer server.Close()
+
+	source, err := NewKladovkinSource(&config.ParserConfig{URL: server.URL})
+	require.NoError(t, err)
+
+	// Act
+	units, err := NewParser(&config.ParserConfig{}, nil, nil).parseSource(context.Background(), source)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, units, 5)
+	for _, unit := range units {
+		assert.Equal(t, KladovkinSourceName, unit.Provider)
+		assert.NotEmpty(t, unit.ExternalID)
+	}
+}
+
+func TestParseSource_FetchError(t *testing.T) {
+	// Arrange
+	source := &fakeSource{name: "broken", err: errors.New("connection refused")}
+
+	// Act
+	_, err := NewParser(&config.ParserConfig{}, nil, nil).parseSource(context.Background(), source)
+
+	// Assert
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestDiffUnits(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	unit := func(id string, available bool, price float64) m.Unit {
+		return m.Unit{Provider: KladovkinSourceName, ExternalID: id, Available: available, Price: price}
+	}
+	stored := func(units ...m.Unit) []*m.Unit {
+		result := make([]*m.Unit, len(units))
+		for i := range units {
+			result[i] = &units[i]
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		stored   []*m.Unit
+		fresh    []m.Unit
+		expected []m.UnitEventType
+	}{
+		{
+			name:     "unchanged units produce no events",
+			stored:   stored(unit("1", true, 100)),
+			fresh:    []m.Unit{unit("1", true, 100)},
+			expected: nil,
+		},
+		{
+			name:     "unknown unit is new",
+			stored:   nil,
+			fresh:    []m.Unit{unit("1", true, 100)},
+			expected: []m.UnitEventType{m.UnitEventNew},
+		},
+		{
+			name:     "missing unit is removed",
+			stored:   stored(unit("1", true, 100)),
+			fresh:    nil,
+			expected: []m.UnitEventType{m.UnitEventRemoved},
+		},
+		{
+			name:     "unit becomes available",
+			stored:   stored(unit("1", false, 100)),
+			fresh:    []m.Unit{unit("1", true, 100)},
+			expected: []m.UnitEventType{m.UnitEventAvailable},
+		},
+		{
+			name:     "unit becomes unavailable",
+			stored:   stored(unit("1", true, 100)),
+			fresh:    []m.Unit{unit("1", false, 100)},
+			expected: []m.UnitEventType{m.UnitEventUnavailable},
+		},
+		{
+			name:     "unit price changes",
+			stored:   stored(unit("1", true, 100)),
+			fresh:    []m.Unit{unit("1", true, 90)},
+			expected: []m.UnitEventType{m.UnitEventPriceChanged},
+		},
+		{
+			name:     "unit becomes available at a new price",
+			stored:   stored(unit("1", false, 100), unit("2", true, 100)),
+			fresh:    []m.Unit{unit("1", true, 90), unit("3", false, 100)},
+			expected: []m.UnitEventType{m.UnitEventAvailable, m.UnitEventPriceChanged, m.UnitEventNew, m.UnitEventRemoved},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			events := diffUnits(tt.stored, tt.fresh, now)
+
+			// Assert
+			var types []m.UnitEventType
+			for _, event := range events {
+				types = append(types, event.Type)
+				assert.Equal(t, now, event.DetectedAt)
+				if event.Type == m.UnitEventNew {
+					assert.Nil(t, event.Previous)
+				} else {
+					assert.NotNil(t, event.Previous)
+				}
+			}
+			assert.Equal(t, tt.expected, types)
+		})
+	}
+}
+
+// recordingHandler is an EventHandler keeping the events it receives.
+type recordingHandler struct {
+	events []m.UnitEvent
+}
+
+func (h *recordingHandler) HandleUnitEvents(_ context.Context, events []m.UnitEvent) error {
+	h.events = append(h.events, events...)
+	return nil
+}
+
+func TestParseAndStoreData(t *testing.T) {
+	ctx := context.Background()
+	unit := func(id string, available bool) m.Unit {
+		return m.Unit{ExternalID: id, Name: "S-" + id, City: "Москва", Storage: "Ленинский", Size: "2 м²", Available: available}
+	}
+
+	t.Run("should store the units and publish the changes", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore()
+		source := &fakeSource{name: KladovkinSourceName, units: []m.Unit{unit("1", false), unit("2", true)}}
+		handler := &recordingHandler{}
+		p := NewParser(&config.ParserConfig{}, []Source{source}, store)
+		p.AddEventHandler(handler)
+		require.NoError(t, p.parseAndStoreData(ctx))
+		handler.events = nil
+
+		// Act
+		source.units = []m.Unit{unit("1", true)}
+		err := p.parseAndStoreData(ctx)
+
+		// Assert
+		require.NoError(t, err)
+		var types []m.UnitEventType
+		for _, event := range handler.events {
+			types = append(types, event.Type)
+			assert.NotZero(t, event.Unit.ID)
+			assert.NotZero(t, event.ID, "the event is stored before it is published")
+		}
+		assert.Equal(t, []m.UnitEventType{m.UnitEventAvailable, m.UnitEventRemoved}, types)
+
+		stored, err := store.Repositories().UnitEvents.GetUnitEvents(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, stored, 4, "the events of both runs are kept until they are handled")
+
+		units, err := store.Repositories().Units.GetAllUnits(ctx)
+		require.NoError(t, err)
+		require.Len(t, units, 1)
+		assert.Equal(t, KladovkinSourceName, units[0].Provider)
+		assert.True(t, units[0].Available)
+	})
+
+	t.Run("should keep storing the other sources when a source fails", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore()
+		broken := &fakeSource{name: "broken", err: errors.New("connection refused")}
+		working := &fakeSource{name: KladovkinSourceName, units: []m.Unit{unit("1", true)}}
+		p := NewParser(&config.ParserConfig{}, []Source{broken, working}, store)
+
+		// Act
+		err := p.parseAndStoreData(ctx)
+
+		// Assert
+		assert.ErrorContains(t, err, "connection refused")
+		units, err := store.Repositories().Units.GetAllUnits(ctx)
+		require.NoError(t, err)
+		assert.Len(t, units, 1)
+	})
+
+	t.Run("should store nothing when the transaction fails", func(t *testing.T) {
+		// Arrange
+		store := memory.NewStore()
+		handler := &recordingHandler{}
+		source := &fakeSource{name: KladovkinSourceName, units: []m.Unit{unit("1", true)}}
+		p := NewParser(&config.ParserConfig{}, []Source{source}, store)
+		p.AddEventHandler(handler)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// Act
+		err := p.parseAndStoreData(cancelled)
+
+		// Assert
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, handler.events)
+		units, err := store.Repositories().Units.GetAllUnits(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, units)
+	})
+}
+
+// newTestFetcher creates a Fetcher recording its waits instead of sleeping.
+func newTestFetcher(cfg *config.ParserConfig) (*Fetcher, *[]time.Duration) {
+	var waits []time.Duration
+	f := NewFetcher(cfg)
+	f.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return f, &waits
+}
+
+func TestFetcher(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.ParserConfig{Retries: 3, RetryBackoff: time.Second, MaxRetryBackoff: 10 * time.Second}
+
+	t.Run("should retry server errors with a growing backoff", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			if calls < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write([]byte("page"))
+		}))
+		defer server.Close()
+		f, waits := newTestFetcher(cfg)
+
+		// Act
+		data, err := f.Get(ctx, server.URL)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "page", string(data))
+		assert.Equal(t, 3, calls)
+		require.Len(t, *waits, 2)
+		assert.GreaterOrEqual(t, (*waits)[0], 500*time.Millisecond)
+		assert.LessOrEqual(t, (*waits)[0], time.Second)
+		assert.GreaterOrEqual(t, (*waits)[1], time.Second)
+		assert.LessOrEqual(t, (*waits)[1], 2*time.Second)
+	})
+
+	t.Run("should wait as long as Retry-After asks", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "7")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte("page"))
+		}))
+		defer server.Close()
+		f, waits := newTestFetcher(cfg)
+
+		// Act
+		_, err := f.Get(ctx, server.URL)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{7 * time.Second}, *waits)
+	})
+
+	t.Run("should give up when Retry-After exceeds the maximum backoff", func(t *testing.T) {
+		// Arrange
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		f, waits := newTestFetcher(cfg)
+
+		// Act
+		_, err := f.Get(ctx, server.URL)
+
+		// Assert
+		var statusErr *StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.Code)
+		assert.Empty(t, *waits)
+	})
+
+	t.Run("should not retry client errors", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+		f, _ := newTestFetcher(cfg)
+
+		// Act
+		_, err := f.Get(ctx, server.URL)
+
+		// Assert
+		assert.EqualError(t, err, "unexpected status code: 404")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("should stop after the configured retries", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+		f, waits := newTestFetcher(cfg)
+
+		// Act
+		_, err := f.Get(ctx, server.URL)
+
+		// Assert
+		assert.Error(t, err)
+		assert.Equal(t, 4, calls)
+		assert.Len(t, *waits, 3)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5"))
+	assert.Zero(t, parseRetryAfter(""))
+	assert.Zero(t, parseRetryAfter("soon"))
+	assert.Zero(t, parseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT"), "a date in the past asks for no wait")
+	at := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	assert.InDelta(t, float64(time.Minute), float64(parseRetryAfter(at)), float64(2*time.Second))
+}
+
+func TestBreaker(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	now := time.Date(2024, 6, 7, 10, 0, 0, 0, time.UTC)
+	var changes []string
+	b := NewBreaker(2, time.Minute, func(_ context.Context, from, to BreakerState) {
+		changes = append(changes, from.String()+" -> "+to.String())
+	})
+	b.now = func() time.Time { return now }
+
+	// Act & Assert
+	b.Failure(ctx)
+	assert.True(t, b.Allow(ctx), "a single failure keeps the breaker closed")
+	b.Failure(ctx)
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.False(t, b.Allow(ctx), "an open breaker refuses the calls")
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.Allow(ctx), "the trial call is let through after the cooldown")
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	b.Failure(ctx)
+	assert.Equal(t, BreakerOpen, b.State(), "a failed trial opens the breaker again")
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.Allow(ctx))
+	assert.False(t, b.Allow(ctx), "a single trial call is let through at a time")
+	b.Release()
+	assert.True(t, b.Allow(ctx), "a released trial lets the next one through")
+	b.Success(ctx)
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.True(t, b.Allow(ctx), "a closed breaker lets every call through")
+	assert.True(t, b.Allow(ctx))
+
+	assert.Equal(t, []string{
+		"closed -> open", "open -> half-open", "half-open -> open", "open -> half-open", "half-open -> closed",
+	}, changes)
+}
+
+// recordingAlerter records the alerts sent to the admin.
+type recordingAlerter struct {
+	alerts []string
+}
+
+func (a *recordingAlerter) SendErrorNotification(_ context.Context, text string) error {
+	a.alerts = append(a.alerts, text)
+	return nil
+}
+
+func TestParseSource_Breaker(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	source := &fakeSource{name: KladovkinSourceName, err: errors.New("bad gateway")}
+	p := NewParser(&config.ParserConfig{BreakerThreshold: 2, BreakerCooldown: time.Minute}, []Source{source}, nil)
+	alerter := &recordingAlerter{}
+	p.SetAlerter(alerter)
+	now := time.Now()
+	p.breakers[KladovkinSourceName].now = func() time.Time { return now }
+
+	// Act
+	for range 3 {
+		_, _ = p.parseSource(ctx, source)
+	}
+	_, err := p.parseSource(ctx, source)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrSourcePaused)
+	require.Len(t, alerter.alerts, 1, "the admin is alerted once, not on every failure")
+	assert.Equal(t, "Scraping of kladovkin is paused for 1m0s after 2 failed fetches in a row", alerter.alerts[0])
+
+	now = now.Add(time.Minute)
+	source.err = nil
+	_, err = p.parseSource(ctx, source)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Scraping of kladovkin is paused for 1m0s after 2 failed fetches in a row",
+		"Scraping of kladovkin has resumed",
+	}, alerter.alerts)
+}
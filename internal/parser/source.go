@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// Source is a storage provider the parser can scrape units from.
+type Source interface {
+	// Name returns the unique provider name the units of the source are tagged with.
+	Name() string
+	// Fetch downloads the raw catalogue of the provider.
+	Fetch(ctx context.Context) ([]byte, error)
+	// Extract converts the raw catalogue into units.
+	Extract(data []byte) ([]m.Unit, error)
+}
+
+// SourceFactory creates a Source from the parser configuration.
+type SourceFactory func(cfg *config.ParserConfig) (Source, error)
+
+// Registry holds the source factories by provider name.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]SourceFactory
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]SourceFactory)}
+}
+
+// DefaultRegistry creates a Registry with all the built-in sources registered.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.MustRegister(KladovkinSourceName, NewKladovkinSource)
+	return r
+}
+
+// Register adds a source factory under the given name.
+// It returns an error if a factory with the same name is already registered.
+func (r *Registry) Register(name string, factory SourceFactory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.factories[name]; exists {
+		return fmt.Errorf("source %q is already registered", name)
+	}
+	r.factories[name] = factory
+	return nil
+}
+
+// MustRegister is like Register but panics on error.
+func (r *Registry) MustRegister(name string, factory SourceFactory) {
+	if err := r.Register(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// Names returns the names of all registered sources in alphabetical order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.namesLocked()
+}
+
+// Build creates the sources enabled in the parser configuration.
+// It returns an error if one of the enabled sources is not registered.
+func (r *Registry) Build(cfg *config.ParserConfig) ([]Source, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sources := make([]Source, 0, len(cfg.Sources))
+	for _, name := range cfg.Sources {
+		factory, exists := r.factories[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown source %q, registered sources are: %v", name, r.namesLocked())
+		}
+
+		source, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create source %q: %w", name, err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// namesLocked returns the sorted source names, the caller must hold the lock.
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
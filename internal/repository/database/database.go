@@ -0,0 +1,150 @@
+// Package database opens the repository backend selected by the configuration.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/memory"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/postgres"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/sqlite"
+	"github.com/movax01h/kladovkin-telegram-bot/pkg/tools"
+)
+
+// Backup files are named after the time they were made, so they sort by age.
+const (
+	backupPrefix = "backup-"
+	backupSuffix = ".db"
+)
+
+// ErrBackupUnsupported is returned by Backup for a driver that cannot be backed up by the bot.
+var ErrBackupUnsupported = errors.New("backups are not supported by the database driver")
+
+// Database is an open database together with the repositories of its driver.
+// DB is nil for the in-memory driver.
+type Database struct {
+	DB           *sql.DB
+	Repositories repository.Repositories
+	Transactor   repository.Transactor
+
+	migrate  func(db *sql.DB) error
+	statuses func(db *sql.DB) ([]repository.MigrationStatus, error)
+	backup   func(ctx context.Context, db *sql.DB, path string) error // Nil if the driver has no backups
+}
+
+// Open connects to the database of the configured driver.
+// The schema is not migrated, see Migrate.
+func Open(cfg *config.DatabaseConfig) (*Database, error) {
+	switch cfg.Driver {
+	case config.DriverSQLite:
+		// Ensure the data directory exists
+		if err := tools.EnsureParentDir(cfg.Path); err != nil {
+			return nil, err
+		}
+
+		db, err := sqlite.NewSQLiteDB(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &Database{
+			DB:           db,
+			Repositories: sqlite.NewRepositories(db),
+			Transactor:   sqlite.NewSQLiteTransactor(db),
+			migrate:      sqlite.Migrate,
+			statuses:     sqlite.MigrationStatuses,
+			backup:       sqlite.Backup,
+		}, nil
+	case config.DriverPostgres:
+		db, err := postgres.NewPostgresDB(cfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		return &Database{
+			DB:           db,
+			Repositories: postgres.NewRepositories(db),
+			Transactor:   postgres.NewPostgresTransactor(db),
+			migrate:      postgres.Migrate,
+			statuses:     postgres.MigrationStatuses,
+		}, nil
+	case config.DriverMemory:
+		store := memory.NewStore()
+		return &Database{
+			Repositories: store.Repositories(),
+			Transactor:   store,
+			migrate:      func(*sql.DB) error { return nil },
+			statuses:     func(*sql.DB) ([]repository.MigrationStatus, error) { return nil, nil },
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+}
+
+// Migrate applies the pending schema migrations of the driver.
+func (d *Database) Migrate() error {
+	return d.migrate(d.DB)
+}
+
+// MigrationStatuses returns the migrations of the driver together with the time they were applied, if they were.
+func (d *Database) MigrationStatuses() ([]repository.MigrationStatus, error) {
+	return d.statuses(d.DB)
+}
+
+// CanBackup reports whether the database of the driver can be backed up.
+func (d *Database) CanBackup() bool {
+	return d.backup != nil
+}
+
+// Backup writes a copy of the database into the directory and deletes the oldest backups beyond keep,
+// all backups are kept if keep is not positive. It returns the path of the new backup.
+func (d *Database) Backup(ctx context.Context, dir string, keep int) (string, error) {
+	if d.backup == nil {
+		return "", ErrBackupUnsupported
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format("20060102-150405.000")+backupSuffix)
+	if err := d.backup(ctx, d.DB, path); err != nil {
+		return "", err
+	}
+	if keep > 0 {
+		if err := pruneBackups(dir, keep); err != nil {
+			return path, err
+		}
+	}
+	return path, nil
+}
+
+// pruneBackups deletes the oldest backups in the directory beyond keep.
+func pruneBackups(dir string, keep int) error {
+	paths, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*"+backupSuffix))
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+	sort.Strings(paths)
+
+	for len(paths) > keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return fmt.Errorf("failed to delete old backup: %w", err)
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// Close closes the database.
+func (d *Database) Close() error {
+	if d.DB == nil {
+		return nil
+	}
+	return d.DB.Close()
+}
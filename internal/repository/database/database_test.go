@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/repositorytest"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/sqlite"
+)
+
+func TestDatabase_Backup(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should back up SQLite and keep the newest backups", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		db, err := Open(&config.DatabaseConfig{Driver: config.DriverSQLite, Path: filepath.Join(dir, "bot.db")})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+		require.NoError(t, db.Migrate())
+		require.NoError(t, db.Repositories.Users.CreateUser(ctx, repositorytest.NewUser(100)))
+		backups := filepath.Join(dir, "backups")
+
+		// Act
+		var paths []string
+		for range 3 {
+			path, err := db.Backup(ctx, backups, 2)
+			require.NoError(t, err)
+			paths = append(paths, path)
+		}
+
+		// Assert
+		entries, err := os.ReadDir(backups)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+		assert.NoFileExists(t, paths[0], "the oldest backup is deleted")
+
+		backup, err := sqlite.NewSQLiteDB(paths[2])
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = backup.Close() })
+		user, err := sqlite.NewRepositories(backup).Users.GetByTelegramID(ctx, 100)
+		require.NoError(t, err)
+		assert.NotNil(t, user, "the backup holds the data")
+	})
+
+	t.Run("should refuse to back up the memory driver", func(t *testing.T) {
+		// Arrange
+		db, err := Open(&config.DatabaseConfig{Driver: config.DriverMemory})
+		require.NoError(t, err)
+
+		// Act
+		_, err = db.Backup(ctx, t.TempDir(), 1)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrBackupUnsupported)
+		assert.False(t, db.CanBackup())
+	})
+}
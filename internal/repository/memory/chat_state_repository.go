@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.ChatStateRepository = (*ChatStateRepository)(nil)
+
+// ChatStateRepository implements the ChatStateRepository interface in memory.
+type ChatStateRepository struct {
+	store *Store
+}
+
+// NewChatStateRepository creates a new instance of ChatStateRepository over the store.
+func NewChatStateRepository(store *Store) *ChatStateRepository {
+	return &ChatStateRepository{store: store}
+}
+
+// GetChatState retrieves the state of a chat.
+func (r *ChatStateRepository) GetChatState(ctx context.Context, chatID int64) (*m.ChatState, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	state, exists := r.store.data.chatStates[chatID]
+	if !exists {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// SaveChatState inserts or updates the state of a chat.
+func (r *ChatStateRepository) SaveChatState(ctx context.Context, state *m.ChatState) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	state.UpdatedAt = time.Now()
+	r.store.data.chatStates[state.ChatID] = *state
+	return nil
+}
+
+// DeleteChatState deletes the state of a chat.
+func (r *ChatStateRepository) DeleteChatState(ctx context.Context, chatID int64) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	delete(r.store.data.chatStates, chatID)
+	return nil
+}
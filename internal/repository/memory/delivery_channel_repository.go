@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.DeliveryChannelRepository = (*DeliveryChannelRepository)(nil)
+
+// channelKey identifies a delivery channel of a user.
+type channelKey struct {
+	userID      int64
+	channelType m.ChannelType
+}
+
+// DeliveryChannelRepository implements the DeliveryChannelRepository interface in memory.
+type DeliveryChannelRepository struct {
+	store *Store
+}
+
+// NewDeliveryChannelRepository creates a new instance of DeliveryChannelRepository over the store.
+func NewDeliveryChannelRepository(store *Store) *DeliveryChannelRepository {
+	return &DeliveryChannelRepository{store: store}
+}
+
+// GetDeliveryChannels retrieves the delivery channels of a user ordered by their type.
+func (r *DeliveryChannelRepository) GetDeliveryChannels(ctx context.Context, userID int64) ([]*m.DeliveryChannel, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	var channels []*m.DeliveryChannel
+	for key, channel := range r.store.data.deliveryChannels {
+		if key.userID == userID {
+			channel := channel
+			channels = append(channels, &channel)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Type < channels[j].Type })
+	return channels, nil
+}
+
+// SaveDeliveryChannel inserts or updates a delivery channel of a user.
+func (r *DeliveryChannelRepository) SaveDeliveryChannel(ctx context.Context, channel *m.DeliveryChannel) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	key := channelKey{userID: channel.UserID, channelType: channel.Type}
+	channel.UpdatedAt = time.Now()
+	if stored, exists := r.store.data.deliveryChannels[key]; exists {
+		channel.CreatedAt = stored.CreatedAt
+	} else if channel.CreatedAt.IsZero() {
+		channel.CreatedAt = channel.UpdatedAt
+	}
+	r.store.data.deliveryChannels[key] = *channel
+	return nil
+}
+
+// DeleteDeliveryChannel deletes a delivery channel of a user.
+func (r *DeliveryChannelRepository) DeleteDeliveryChannel(ctx context.Context, userID int64, channelType m.ChannelType) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	delete(r.store.data.deliveryChannels, channelKey{userID: userID, channelType: channelType})
+	return nil
+}
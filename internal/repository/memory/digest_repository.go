@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.DigestRepository = (*DigestRepository)(nil)
+
+// DigestRepository implements the DigestRepository interface in memory.
+type DigestRepository struct {
+	store *Store
+}
+
+// NewDigestRepository creates a new instance of DigestRepository over the store.
+func NewDigestRepository(store *Store) *DigestRepository {
+	return &DigestRepository{store: store}
+}
+
+// AddDigestItem stores an item or updates the unit and previous price of the stored item of the same subscription, unit and due time.
+func (r *DigestRepository) AddDigestItem(ctx context.Context, item *m.DigestItem) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	for id, stored := range r.store.data.digestItems {
+		if stored.SubscriptionID == item.SubscriptionID && stored.Unit.ID == item.Unit.ID && stored.DueAt.Equal(item.DueAt) {
+			stored.Unit = item.Unit
+			stored.PreviousPrice = item.PreviousPrice
+			r.store.data.digestItems[id] = stored
+			item.ID = stored.ID
+			item.CreatedAt = stored.CreatedAt
+			return nil
+		}
+	}
+
+	r.store.data.nextDigestItemID++
+	item.ID = r.store.data.nextDigestItemID
+	item.CreatedAt = time.Now()
+	r.store.data.digestItems[item.ID] = *item
+	return nil
+}
+
+// GetDueDigestItems retrieves the items due at now, ordered by user and due time.
+func (r *DigestRepository) GetDueDigestItems(ctx context.Context, now time.Time) ([]*m.DigestItem, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	var due []*m.DigestItem
+	for _, id := range sortedKeys(r.store.data.digestItems) {
+		item := r.store.data.digestItems[id]
+		if !item.DueAt.After(now) {
+			due = append(due, &item)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if due[i].UserID != due[j].UserID {
+			return due[i].UserID < due[j].UserID
+		}
+		return due[i].DueAt.Before(due[j].DueAt)
+	})
+	return due, nil
+}
+
+// DeleteDigestItems deletes the items with the IDs.
+func (r *DigestRepository) DeleteDigestItems(ctx context.Context, ids []int64) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.store.data.digestItems, id)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.JobStateRepository = (*JobStateRepository)(nil)
+
+// JobStateRepository implements the JobStateRepository interface in memory.
+type JobStateRepository struct {
+	store *Store
+}
+
+// NewJobStateRepository creates a new instance of JobStateRepository over the store.
+func NewJobStateRepository(store *Store) *JobStateRepository {
+	return &JobStateRepository{store: store}
+}
+
+// GetJobStates retrieves the states of all jobs, ordered by name.
+func (r *JobStateRepository) GetJobStates(ctx context.Context) ([]*m.JobState, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	states := make([]*m.JobState, 0, len(r.store.data.jobStates))
+	for _, state := range r.store.data.jobStates {
+		copied := copyJobState(&state)
+		states = append(states, &copied)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states, nil
+}
+
+// GetJobState retrieves the state of the job with the name.
+func (r *JobStateRepository) GetJobState(ctx context.Context, name string) (*m.JobState, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	state, exists := r.store.data.jobStates[name]
+	if !exists {
+		return nil, nil
+	}
+	copied := copyJobState(&state)
+	return &copied, nil
+}
+
+// SaveJobState inserts or replaces the state of the job.
+func (r *JobStateRepository) SaveJobState(ctx context.Context, state *m.JobState) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	state.UpdatedAt = time.Now()
+	r.store.data.jobStates[state.Name] = copyJobState(state)
+	return nil
+}
+
+// copyJobState returns a copy of the state that shares no pointers with it.
+func copyJobState(state *m.JobState) m.JobState {
+	copied := *state
+	if state.LastRunAt != nil {
+		lastRunAt := *state.LastRunAt
+		copied.LastRunAt = &lastRunAt
+	}
+	return copied
+}
@@ -0,0 +1,136 @@
+// Package memory implements the repositories in memory, for tests and local development.
+// The repositories behave like the SQLite ones: saving upserts, looking up a missing record returns nil
+// without an error, and records are copied in and out so callers never share them with the store.
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.Transactor = (*Store)(nil)
+
+// Store holds the data of the in-memory repositories. It is safe for concurrent use.
+type Store struct {
+	mu   sync.Mutex
+	data data
+}
+
+// data is the content of a store.
+type data struct {
+	users              map[int64]m.User
+	units              map[int64]m.Unit
+	subscriptions      map[int64]m.Subscription
+	chatStates         map[int64]m.ChatState
+	deliveryChannels   map[channelKey]m.DeliveryChannel
+	notifications      map[int64]m.Notification
+	digestItems        map[int64]m.DigestItem
+	unitAlerts         map[unitAlertKey]time.Time
+	unitEvents         map[int64]m.UnitEvent
+	jobStates          map[string]m.JobState
+	nextUserID         int64
+	nextUnitID         int64
+	nextSubscriptionID int64
+	nextNotificationID int64
+	nextDigestItemID   int64
+	nextUnitEventID    int64
+}
+
+// NewStore creates an empty store.
+func NewStore() *Store {
+	return &Store{data: data{
+		users:            make(map[int64]m.User),
+		units:            make(map[int64]m.Unit),
+		subscriptions:    make(map[int64]m.Subscription),
+		chatStates:       make(map[int64]m.ChatState),
+		deliveryChannels: make(map[channelKey]m.DeliveryChannel),
+		notifications:    make(map[int64]m.Notification),
+		digestItems:      make(map[int64]m.DigestItem),
+		unitAlerts:       make(map[unitAlertKey]time.Time),
+		unitEvents:       make(map[int64]m.UnitEvent),
+		jobStates:        make(map[string]m.JobState),
+	}}
+}
+
+// Repositories returns the repositories over the store.
+func (s *Store) Repositories() repository.Repositories {
+	return repository.Repositories{
+		Users:            &UserRepository{store: s},
+		Units:            &UnitRepository{store: s},
+		Subscriptions:    &SubscriptionRepository{store: s},
+		ChatStates:       &ChatStateRepository{store: s},
+		DeliveryChannels: &DeliveryChannelRepository{store: s},
+		Notifications:    &NotificationRepository{store: s},
+		Digests:          &DigestRepository{store: s},
+		UnitAlerts:       &UnitAlertRepository{store: s},
+		UnitEvents:       &UnitEventRepository{store: s},
+		JobStates:        &JobStateRepository{store: s},
+	}
+}
+
+// WithTx runs fn with repositories over a copy of the data, which replaces the data of the store if fn returns nil.
+// The store is locked while fn runs, so transactions are serialized like on the single SQLite connection.
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context, repos repository.Repositories) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Store{data: s.data.clone()}
+	if err := fn(ctx, tx.Repositories()); err != nil {
+		return err
+	}
+
+	s.data = tx.data
+	return nil
+}
+
+// lock locks the store unless the context is done.
+func (s *Store) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	return nil
+}
+
+// clone returns a deep copy of the data.
+func (d *data) clone() data {
+	c := *d
+	c.users = cloneMap(d.users)
+	c.units = cloneMap(d.units)
+	c.subscriptions = cloneMap(d.subscriptions)
+	c.chatStates = cloneMap(d.chatStates)
+	c.deliveryChannels = cloneMap(d.deliveryChannels)
+	c.notifications = cloneMap(d.notifications)
+	c.digestItems = cloneMap(d.digestItems)
+	c.unitAlerts = cloneMap(d.unitAlerts)
+	c.unitEvents = cloneMap(d.unitEvents)
+	c.jobStates = cloneMap(d.jobStates)
+	return c
+}
+
+func cloneMap[K comparable, V any](src map[K]V) map[K]V {
+	dst := make(map[K]V, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// sortedKeys returns the keys of the map in ascending order, the order the SQL backends return rows in.
+func sortedKeys[V any](src map[int64]V) []int64 {
+	keys := make([]int64, 0, len(src))
+	for k := range src {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
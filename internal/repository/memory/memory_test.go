@@ -0,0 +1,70 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/repositorytest"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) (repository.Repositories, repository.Transactor) {
+		store := NewStore()
+		return store.Repositories(), store
+	})
+}
+
+func TestStore_CopiesRecords(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewUserRepository(NewStore())
+	user := repositorytest.NewUser(1)
+	require.NoError(t, repo.CreateUser(ctx, user))
+
+	// Act
+	user.FirstName = "Changed"
+	stored, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	stored.LastName = "Changed"
+
+	// Assert
+	again, err := repo.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "First", again.FirstName)
+	assert.Equal(t, "Last", again.LastName)
+}
+
+func TestStore_Concurrency(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := NewStore()
+	repos := store.Repositories()
+
+	// Act
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = repos.Users.CreateUser(ctx, repositorytest.NewUser(int64(i)))
+			_ = store.WithTx(ctx, func(ctx context.Context, tx repository.Repositories) error {
+				return tx.Units.UpsertUnits(ctx, []m.Unit{repositorytest.NewUnit("1", "Москва", "Ленинский", "2 м²")})
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert
+	users, err := repos.Users.GetAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, users, 20)
+	units, err := repos.Units.GetAllUnits(ctx)
+	require.NoError(t, err)
+	assert.Len(t, units, 1)
+}
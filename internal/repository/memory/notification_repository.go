@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.NotificationRepository = (*NotificationRepository)(nil)
+
+// NotificationRepository implements the NotificationRepository interface in memory.
+type NotificationRepository struct {
+	store *Store
+}
+
+// NewNotificationRepository creates a new instance of NotificationRepository over the store.
+func NewNotificationRepository(store *Store) *NotificationRepository {
+	return &NotificationRepository{store: store}
+}
+
+// EnqueueNotification stores a notification unless one with the same idempotency key and channel exists.
+func (r *NotificationRepository) EnqueueNotification(ctx context.Context, notification *m.Notification) (bool, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.store.mu.Unlock()
+
+	for _, stored := range r.store.data.notifications {
+		if stored.IdempotencyKey == notification.IdempotencyKey && stored.Channel == notification.Channel {
+			return false, nil
+		}
+	}
+
+	r.store.data.nextNotificationID++
+	notification.ID = r.store.data.nextNotificationID
+	notification.CreatedAt = time.Now()
+	notification.UpdatedAt = notification.CreatedAt
+	r.store.data.notifications[notification.ID] = copyNotification(notification)
+	return true, nil
+}
+
+// GetDueNotifications retrieves up to limit pending or failed notifications due at now, the oldest first.
+func (r *NotificationRepository) GetDueNotifications(ctx context.Context, now time.Time, limit int) ([]*m.Notification, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	return r.dueNotifications(now, limit), nil
+}
+
+// ClaimDueNotifications takes up to limit pending or failed notifications due at now and leases them until leaseUntil.
+func (r *NotificationRepository) ClaimDueNotifications(
+	ctx context.Context,
+	now, leaseUntil time.Time,
+	limit int,
+) ([]*m.Notification, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	due := r.dueNotifications(now, limit)
+	for _, notification := range due {
+		notification.NextAttemptAt = leaseUntil
+		r.store.data.notifications[notification.ID] = copyNotification(notification)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due, nil
+}
+
+// dueNotifications returns copies of up to limit notifications due at now, the oldest first. The mutex must be held.
+func (r *NotificationRepository) dueNotifications(now time.Time, limit int) []*m.Notification {
+	var due []*m.Notification
+	for _, id := range sortedKeys(r.store.data.notifications) {
+		notification := r.store.data.notifications[id]
+		isRetried := notification.Status == m.NotificationStatusPending || notification.Status == m.NotificationStatusFailed
+		if isRetried && !notification.NextAttemptAt.After(now) {
+			copied := copyNotification(&notification)
+			due = append(due, &copied)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due
+}
+
+// GetNotificationByID retrieves a notification by ID.
+func (r *NotificationRepository) GetNotificationByID(ctx context.Context, id int64) (*m.Notification, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	notification, exists := r.store.data.notifications[id]
+	if !exists {
+		return nil, nil
+	}
+	copied := copyNotification(&notification)
+	return &copied, nil
+}
+
+// UpdateNotification updates the delivery status of a notification.
+func (r *NotificationRepository) UpdateNotification(ctx context.Context, notification *m.Notification) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	stored, exists := r.store.data.notifications[notification.ID]
+	if !exists {
+		return nil
+	}
+	stored.Status = notification.Status
+	stored.Attempts = notification.Attempts
+	stored.LastError = notification.LastError
+	stored.NextAttemptAt = notification.NextAttemptAt
+	stored.SentAt = notification.SentAt
+	stored.UpdatedAt = time.Now()
+	notification.UpdatedAt = stored.UpdatedAt
+	r.store.data.notifications[notification.ID] = copyNotification(&stored)
+	return nil
+}
+
+// DeleteNotificationsBefore deletes the sent and given up notifications last updated before the time.
+func (r *NotificationRepository) DeleteNotificationsBefore(ctx context.Context, before time.Time) (int64, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for id, notification := range r.store.data.notifications {
+		done := notification.Status == m.NotificationStatusSent || notification.Status == m.NotificationStatusDead
+		if done && notification.UpdatedAt.Before(before) {
+			delete(r.store.data.notifications, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// copyNotification returns a copy of the notification that shares no pointers with it.
+func copyNotification(notification *m.Notification) m.Notification {
+	copied := *notification
+	if notification.SentAt != nil {
+		sentAt := *notification.SentAt
+		copied.SentAt = &sentAt
+	}
+	return copied
+}
@@ -0,0 +1,127 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.SubscriptionRepository = (*SubscriptionRepository)(nil)
+
+// SubscriptionRepository implements the SubscriptionRepository interface in memory.
+type SubscriptionRepository struct {
+	store *Store
+}
+
+// NewSubscriptionRepository creates a new instance of SubscriptionRepository over the store.
+func NewSubscriptionRepository(store *Store) *SubscriptionRepository {
+	return &SubscriptionRepository{store: store}
+}
+
+// CreateSubscription inserts a subscription, the ID of the stored subscription is written back into subscription.
+func (r *SubscriptionRepository) CreateSubscription(ctx context.Context, subscription *m.Subscription) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	d := &r.store.data
+	d.nextSubscriptionID++
+	subscription.ID = d.nextSubscriptionID
+
+	stored := *subscription
+	stored.CreatedAt = time.Now()
+	stored.UpdatedAt = stored.CreatedAt
+	d.subscriptions[stored.ID] = stored
+	return nil
+}
+
+// GetSubscriptionByID retrieves a subscription by ID.
+func (r *SubscriptionRepository) GetSubscriptionByID(ctx context.Context, id int64) (*m.Subscription, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	subscription, exists := r.store.data.subscriptions[id]
+	if !exists {
+		return nil, nil
+	}
+	return &subscription, nil
+}
+
+// GetSubscriptionsByUserID retrieves the subscriptions of a user.
+func (r *SubscriptionRepository) GetSubscriptionsByUserID(ctx context.Context, userID int64) ([]*m.Subscription, error) {
+	return r.filter(ctx, func(subscription *m.Subscription) bool { return subscription.UserID == userID })
+}
+
+// GetAllSubscriptions retrieves all subscriptions.
+func (r *SubscriptionRepository) GetAllSubscriptions(ctx context.Context) ([]*m.Subscription, error) {
+	return r.filter(ctx, func(*m.Subscription) bool { return true })
+}
+
+// GetActiveSubscriptions retrieves the active subscriptions.
+func (r *SubscriptionRepository) GetActiveSubscriptions(ctx context.Context) ([]*m.Subscription, error) {
+	return r.filter(ctx, func(subscription *m.Subscription) bool {
+		return subscription.Status == m.SubscriptionStatusActive
+	})
+}
+
+// filter returns the subscriptions matching the predicate.
+func (r *SubscriptionRepository) filter(ctx context.Context, match func(subscription *m.Subscription) bool) ([]*m.Subscription, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	subscriptions := r.store.data.subscriptions
+	var result []*m.Subscription
+	for _, key := range sortedKeys(subscriptions) {
+		subscription := subscriptions[key]
+		if match(&subscription) {
+			result = append(result, &subscription)
+		}
+	}
+	return result, nil
+}
+
+// UpdateSubscription updates a stored subscription, an unknown subscription is ignored.
+func (r *SubscriptionRepository) UpdateSubscription(ctx context.Context, subscription *m.Subscription) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	stored, exists := r.store.data.subscriptions[subscription.ID]
+	if !exists {
+		return nil
+	}
+	updated := *subscription
+	updated.CreatedAt = stored.CreatedAt
+	updated.UpdatedAt = time.Now()
+	r.store.data.subscriptions[subscription.ID] = updated
+	return nil
+}
+
+// DeleteSubscription deletes a subscription together with its digest items and alerts, as the foreign keys do.
+func (r *SubscriptionRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	delete(r.store.data.subscriptions, id)
+	for itemID, item := range r.store.data.digestItems {
+		if item.SubscriptionID == id {
+			delete(r.store.data.digestItems, itemID)
+		}
+	}
+	for key := range r.store.data.unitAlerts {
+		if key.subscriptionID == id {
+			delete(r.store.data.unitAlerts, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UnitAlertRepository = (*UnitAlertRepository)(nil)
+
+// unitAlertKey identifies the announcement of a unit to a subscription.
+type unitAlertKey struct {
+	subscriptionID int64
+	unitID         int64
+}
+
+// UnitAlertRepository implements the UnitAlertRepository interface in memory.
+type UnitAlertRepository struct {
+	store *Store
+}
+
+// NewUnitAlertRepository creates a new instance of UnitAlertRepository over the store.
+func NewUnitAlertRepository(store *Store) *UnitAlertRepository {
+	return &UnitAlertRepository{store: store}
+}
+
+// ClaimUnitAlert records the announcement of the unit to the subscription unless it is recorded already.
+func (r *UnitAlertRepository) ClaimUnitAlert(ctx context.Context, subscriptionID, unitID int64, at time.Time) (bool, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return false, err
+	}
+	defer r.store.mu.Unlock()
+
+	key := unitAlertKey{subscriptionID: subscriptionID, unitID: unitID}
+	if _, exists := r.store.data.unitAlerts[key]; exists {
+		return false, nil
+	}
+	r.store.data.unitAlerts[key] = at
+	return true, nil
+}
+
+// ClearUnitAlerts forgets the announcements of the unit.
+func (r *UnitAlertRepository) ClearUnitAlerts(ctx context.Context, unitID int64) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	for key := range r.store.data.unitAlerts {
+		if key.unitID == unitID {
+			delete(r.store.data.unitAlerts, key)
+		}
+	}
+	return nil
+}
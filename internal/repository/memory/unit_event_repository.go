@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UnitEventRepository = (*UnitEventRepository)(nil)
+
+// UnitEventRepository implements the UnitEventRepository interface in memory.
+type UnitEventRepository struct {
+	store *Store
+}
+
+// NewUnitEventRepository creates a new instance of UnitEventRepository over the store.
+func NewUnitEventRepository(store *Store) *UnitEventRepository {
+	return &UnitEventRepository{store: store}
+}
+
+// AddUnitEvents stores the events and writes their IDs back into them.
+func (r *UnitEventRepository) AddUnitEvents(ctx context.Context, events []m.UnitEvent) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	for i := range events {
+		r.store.data.nextUnitEventID++
+		events[i].ID = r.store.data.nextUnitEventID
+		r.store.data.unitEvents[events[i].ID] = cloneUnitEvent(events[i])
+	}
+	return nil
+}
+
+// GetUnitEvents retrieves up to limit events with an ID greater than afterID, the oldest first.
+func (r *UnitEventRepository) GetUnitEvents(ctx context.Context, afterID int64, limit int) ([]m.UnitEvent, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	var events []m.UnitEvent
+	for _, id := range sortedKeys(r.store.data.unitEvents) {
+		if id <= afterID {
+			continue
+		}
+		if len(events) == limit {
+			break
+		}
+		events = append(events, cloneUnitEvent(r.store.data.unitEvents[id]))
+	}
+	return events, nil
+}
+
+// DeleteUnitEvents deletes the events with the IDs.
+func (r *UnitEventRepository) DeleteUnitEvents(ctx context.Context, ids []int64) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.store.data.unitEvents, id)
+	}
+	return nil
+}
+
+// cloneUnitEvent copies the event, so the previous state of the unit is not shared with the store.
+func cloneUnitEvent(event m.UnitEvent) m.UnitEvent {
+	if event.Previous != nil {
+		previous := *event.Previous
+		event.Previous = &previous
+	}
+	return event
+}
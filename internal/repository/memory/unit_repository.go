@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UnitRepository = (*UnitRepository)(nil)
+
+// UnitRepository implements the UnitRepository interface in memory.
+type UnitRepository struct {
+	store *Store
+}
+
+// NewUnitRepository creates a new instance of UnitRepository over the store.
+func NewUnitRepository(store *Store) *UnitRepository {
+	return &UnitRepository{store: store}
+}
+
+// CreateUnit inserts or updates a unit.
+// Units are matched by their provider and external identifier, the ID of the stored unit is written back into unit.
+func (r *UnitRepository) CreateUnit(ctx context.Context, unit *m.Unit) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	r.store.data.upsertUnit(unit)
+	return nil
+}
+
+// UpsertUnits inserts or updates the units, the IDs of the stored units are written back into units.
+func (r *UnitRepository) UpsertUnits(ctx context.Context, units []m.Unit) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	for i := range units {
+		r.store.data.upsertUnit(&units[i])
+	}
+	return nil
+}
+
+// upsertUnit stores the unit in place of the unit with the same provider and external identifier.
+func (d *data) upsertUnit(unit *m.Unit) {
+	for id, stored := range d.units {
+		if stored.Provider == unit.Provider && stored.ExternalID == unit.ExternalID {
+			unit.ID = id
+			updated := *unit
+			updated.CreatedAt = stored.CreatedAt
+			d.units[id] = updated
+			return
+		}
+	}
+
+	d.nextUnitID++
+	unit.ID = d.nextUnitID
+	d.units[unit.ID] = *unit
+}
+
+// GetUnitByID retrieves a unit by ID.
+func (r *UnitRepository) GetUnitByID(ctx context.Context, id int64) (*m.Unit, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	unit, exists := r.store.data.units[id]
+	if !exists {
+		return nil, nil
+	}
+	return &unit, nil
+}
+
+// GetAllUnits retrieves all units.
+func (r *UnitRepository) GetAllUnits(ctx context.Context) ([]*m.Unit, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	units := r.store.data.units
+	result := make([]*m.Unit, 0, len(units))
+	for _, key := range sortedKeys(units) {
+		unit := units[key]
+		result = append(result, &unit)
+	}
+	return result, nil
+}
+
+// GetCities retrieves the distinct cities of the units.
+func (r *UnitRepository) GetCities(ctx context.Context) ([]string, error) {
+	return r.distinct(ctx, func(unit *m.Unit) (string, bool) { return unit.City, true })
+}
+
+// GetStoragesByCity retrieves the distinct storages of every provider in the city, ordered by name and provider.
+func (r *UnitRepository) GetStoragesByCity(ctx context.Context, city string) ([]m.Storage, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	seen := make(map[m.Storage]bool)
+	var storages []m.Storage
+	for _, unit := range r.store.data.units {
+		storage := m.Storage{Provider: unit.Provider, Name: unit.Storage}
+		if unit.City != city || seen[storage] {
+			continue
+		}
+		seen[storage] = true
+		storages = append(storages, storage)
+	}
+	sort.Slice(storages, func(i, j int) bool {
+		if storages[i].Name != storages[j].Name {
+			return storages[i].Name < storages[j].Name
+		}
+		return storages[i].Provider < storages[j].Provider
+	})
+	return storages, nil
+}
+
+// GetUnitSizesByStorage retrieves the distinct sizes of the units in the storage of a provider in the city.
+func (r *UnitRepository) GetUnitSizesByStorage(ctx context.Context, storage m.Storage, city string) ([]string, error) {
+	return r.distinct(ctx, func(unit *m.Unit) (string, bool) {
+		return unit.Size, unit.Provider == storage.Provider && unit.Storage == storage.Name && unit.City == city
+	})
+}
+
+// distinct returns the distinct values selected from the units, in the order of the units.
+func (r *UnitRepository) distinct(ctx context.Context, selectValue func(unit *m.Unit) (string, bool)) ([]string, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	units := r.store.data.units
+	seen := make(map[string]bool)
+	var values []string
+	for _, key := range sortedKeys(units) {
+		unit := units[key]
+		value, ok := selectValue(&unit)
+		if !ok || seen[value] {
+			continue
+		}
+		seen[value] = true
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// UpdateUnit updates a stored unit, an unknown unit is ignored.
+func (r *UnitRepository) UpdateUnit(ctx context.Context, unit *m.Unit) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	stored, exists := r.store.data.units[unit.ID]
+	if !exists {
+		return nil
+	}
+	updated := *unit
+	updated.CreatedAt = stored.CreatedAt
+	updated.UpdatedAt = time.Now()
+	r.store.data.units[unit.ID] = updated
+	return nil
+}
+
+// DeleteUnit deletes a unit.
+func (r *UnitRepository) DeleteUnit(ctx context.Context, id int64) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	delete(r.store.data.units, id)
+	return nil
+}
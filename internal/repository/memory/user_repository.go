@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UserRepository = (*UserRepository)(nil)
+
+// UserRepository implements the UserRepository interface in memory.
+type UserRepository struct {
+	store *Store
+}
+
+// NewUserRepository creates a new instance of UserRepository over the store.
+func NewUserRepository(store *Store) *UserRepository {
+	return &UserRepository{store: store}
+}
+
+// CreateUser inserts or updates a user.
+// A user without an ID is inserted, the ID of the stored user is written back into user.
+func (r *UserRepository) CreateUser(ctx context.Context, user *m.User) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	d := &r.store.data
+	if stored, exists := d.users[user.ID]; exists {
+		updated := *user
+		updated.CreatedAt = stored.CreatedAt
+		d.users[user.ID] = updated
+		return nil
+	}
+
+	if user.ID == 0 {
+		d.nextUserID++
+		user.ID = d.nextUserID
+	} else if user.ID > d.nextUserID {
+		d.nextUserID = user.ID
+	}
+	d.users[user.ID] = *user
+	return nil
+}
+
+// GetUserByID retrieves a user by ID.
+func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*m.User, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	user, exists := r.store.data.users[id]
+	if !exists {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// GetByTelegramID retrieves a user by Telegram ID.
+func (r *UserRepository) GetByTelegramID(ctx context.Context, id int64) (*m.User, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	users := r.store.data.users
+	for _, key := range sortedKeys(users) {
+		if user := users[key]; user.TelegramID == id {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAllUsers retrieves all users.
+func (r *UserRepository) GetAllUsers(ctx context.Context) ([]*m.User, error) {
+	if err := r.store.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer r.store.mu.Unlock()
+
+	users := r.store.data.users
+	result := make([]*m.User, 0, len(users))
+	for _, key := range sortedKeys(users) {
+		user := users[key]
+		result = append(result, &user)
+	}
+	return result, nil
+}
+
+// UpdateUser updates a stored user, an unknown user is ignored.
+func (r *UserRepository) UpdateUser(ctx context.Context, user *m.User) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	stored, exists := r.store.data.users[user.ID]
+	if !exists {
+		return nil
+	}
+	updated := *user
+	updated.CreatedAt = stored.CreatedAt
+	r.store.data.users[user.ID] = updated
+	return nil
+}
+
+// DeleteUser deletes a user.
+func (r *UserRepository) DeleteUser(ctx context.Context, user *m.User) error {
+	if err := r.store.lock(ctx); err != nil {
+		return err
+	}
+	defer r.store.mu.Unlock()
+
+	delete(r.store.data.users, user.ID)
+	return nil
+}
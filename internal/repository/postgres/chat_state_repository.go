@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.ChatStateRepository = (*PostgresChatStateRepository)(nil)
+
+// PostgresChatStateRepository implements the ChatStateRepository interface using PostgreSQL.
+type PostgresChatStateRepository struct {
+	db queryer
+}
+
+// NewPostgresChatStateRepository creates a new instance of PostgresChatStateRepository.
+func NewPostgresChatStateRepository(db *sql.DB) *PostgresChatStateRepository {
+	return &PostgresChatStateRepository{db: db}
+}
+
+// GetChatState retrieves the state of a chat from the database.
+func (r *PostgresChatStateRepository) GetChatState(ctx context.Context, chatID int64) (*m.ChatState, error) {
+	query := `SELECT chat_id, step, city, provider, storage, unit_size, subscription_id, updated_at FROM chat_states WHERE chat_id = $1`
+	row := r.db.QueryRowContext(ctx, query, chatID)
+
+	var state m.ChatState
+	err := row.Scan(&state.ChatID, &state.Step, &state.City, &state.Provider, &state.Storage, &state.UnitSize, &state.SubscriptionID, &state.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get chat state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveChatState inserts or updates the state of a chat in the database.
+func (r *PostgresChatStateRepository) SaveChatState(ctx context.Context, state *m.ChatState) error {
+	query := `
+		INSERT INTO chat_states (chat_id, step, city, provider, storage, unit_size, subscription_id, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT(chat_id) DO UPDATE SET
+			step = excluded.step,
+			city = excluded.city,
+			provider = excluded.provider,
+			storage = excluded.storage,
+			unit_size = excluded.unit_size,
+			subscription_id = excluded.subscription_id,
+			updated_at = excluded.updated_at
+	`
+	state.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, state.ChatID, state.Step, state.City, state.Provider, state.Storage, state.UnitSize, state.SubscriptionID, state.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save chat state: %w", err)
+	}
+	return nil
+}
+
+// DeleteChatState deletes the state of a chat from the database.
+func (r *PostgresChatStateRepository) DeleteChatState(ctx context.Context, chatID int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM chat_states WHERE chat_id = $1", chatID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat state: %w", err)
+	}
+	return nil
+}
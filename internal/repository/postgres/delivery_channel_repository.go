@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.DeliveryChannelRepository = (*PostgresDeliveryChannelRepository)(nil)
+
+// PostgresDeliveryChannelRepository implements the DeliveryChannelRepository interface using PostgreSQL.
+type PostgresDeliveryChannelRepository struct {
+	db queryer
+}
+
+// NewPostgresDeliveryChannelRepository creates a new instance of PostgresDeliveryChannelRepository.
+func NewPostgresDeliveryChannelRepository(db *sql.DB) *PostgresDeliveryChannelRepository {
+	return &PostgresDeliveryChannelRepository{db: db}
+}
+
+// GetDeliveryChannels retrieves the delivery channels of a user from the database.
+func (r *PostgresDeliveryChannelRepository) GetDeliveryChannels(ctx context.Context, userID int64) ([]*m.DeliveryChannel, error) {
+	query := `
+		SELECT user_id, type, address, enabled, verification_code, created_at, updated_at
+		FROM delivery_channels
+		WHERE user_id = $1
+		ORDER BY type
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []*m.DeliveryChannel
+	for rows.Next() {
+		var channel m.DeliveryChannel
+		err := rows.Scan(&channel.UserID, &channel.Type, &channel.Address, &channel.Enabled, &channel.VerificationCode, &channel.CreatedAt, &channel.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery channel row: %w", err)
+		}
+		channels = append(channels, &channel)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during delivery channels rows iteration: %w", err)
+	}
+	return channels, nil
+}
+
+// SaveDeliveryChannel inserts or updates a delivery channel of a user in the database.
+func (r *PostgresDeliveryChannelRepository) SaveDeliveryChannel(ctx context.Context, channel *m.DeliveryChannel) error {
+	query := `
+		INSERT INTO delivery_channels (user_id, type, address, enabled, verification_code, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT(user_id, type) DO UPDATE SET
+			address = excluded.address,
+			enabled = excluded.enabled,
+			verification_code = excluded.verification_code,
+			updated_at = excluded.updated_at
+	`
+	channel.UpdatedAt = time.Now()
+	if channel.CreatedAt.IsZero() {
+		channel.CreatedAt = channel.UpdatedAt
+	}
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		channel.UserID,
+		channel.Type,
+		channel.Address,
+		channel.Enabled,
+		channel.VerificationCode,
+		channel.CreatedAt,
+		channel.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save delivery channel: %w", err)
+	}
+	return nil
+}
+
+// DeleteDeliveryChannel deletes a delivery channel of a user from the database.
+func (r *PostgresDeliveryChannelRepository) DeleteDeliveryChannel(ctx context.Context, userID int64, channelType m.ChannelType) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM delivery_channels WHERE user_id = $1 AND type = $2", userID, channelType)
+	if err != nil {
+		return fmt.Errorf("failed to delete delivery channel: %w", err)
+	}
+	return nil
+}
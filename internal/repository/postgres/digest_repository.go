@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.DigestRepository = (*PostgresDigestRepository)(nil)
+
+// digestItemColumns are the columns scanned by scanDigestItem.
+const digestItemColumns = `id, user_id, subscription_id, unit, previous_price, due_at, created_at`
+
+// PostgresDigestRepository implements the DigestRepository interface using Postgres.
+type PostgresDigestRepository struct {
+	db queryer
+}
+
+// NewPostgresDigestRepository creates a new instance of PostgresDigestRepository.
+func NewPostgresDigestRepository(db *sql.DB) *PostgresDigestRepository {
+	return &PostgresDigestRepository{db: db}
+}
+
+// AddDigestItem inserts an item or updates the unit and previous price of the stored item of the same subscription, unit and due time.
+// The ID of the stored item is written back into item.
+func (r *PostgresDigestRepository) AddDigestItem(ctx context.Context, item *m.DigestItem) error {
+	query := `
+		INSERT INTO digest_items (user_id, subscription_id, unit_id, unit, previous_price, due_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT(subscription_id, unit_id, due_at) DO UPDATE SET unit = excluded.unit, previous_price = excluded.previous_price
+		RETURNING id
+	`
+	unit, err := json.Marshal(item.Unit)
+	if err != nil {
+		return fmt.Errorf("failed to encode digest item unit: %w", err)
+	}
+
+	item.CreatedAt = time.Now().UTC()
+	err = r.db.QueryRowContext(
+		ctx,
+		query,
+		item.UserID,
+		item.SubscriptionID,
+		item.Unit.ID,
+		string(unit),
+		item.PreviousPrice,
+		item.DueAt.UTC(),
+		item.CreatedAt,
+	).Scan(&item.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add digest item: %w", err)
+	}
+	return nil
+}
+
+// GetDueDigestItems retrieves the items due at now from the database, ordered by user and due time.
+func (r *PostgresDigestRepository) GetDueDigestItems(ctx context.Context, now time.Time) ([]*m.DigestItem, error) {
+	query := `
+		SELECT ` + digestItemColumns + `
+		FROM digest_items
+		WHERE due_at <= $1
+		ORDER BY user_id, due_at, id
+	`
+	rows, err := r.db.QueryContext(ctx, query, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due digest items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []*m.DigestItem
+	for rows.Next() {
+		item, err := scanDigestItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during digest items rows iteration: %w", err)
+	}
+	return items, nil
+}
+
+// DeleteDigestItems deletes the items with the IDs from the database.
+func (r *PostgresDigestRepository) DeleteDigestItems(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := make([]string, len(ids))
+	for i := range ids {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+	query := `DELETE FROM digest_items WHERE id IN (` + strings.Join(placeholders, ", ") + `)`
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete digest items: %w", err)
+	}
+	return nil
+}
+
+// scanDigestItem scans a row of digestItemColumns.
+func scanDigestItem(row interface{ Scan(dest ...any) error }) (*m.DigestItem, error) {
+	var item m.DigestItem
+	var unit string
+	err := row.Scan(&item.ID, &item.UserID, &item.SubscriptionID, &unit, &item.PreviousPrice, &item.DueAt, &item.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan digest item: %w", err)
+	}
+	if err := json.Unmarshal([]byte(unit), &item.Unit); err != nil {
+		return nil, fmt.Errorf("failed to decode digest item unit: %w", err)
+	}
+	return &item, nil
+}
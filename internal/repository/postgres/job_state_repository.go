@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.JobStateRepository = (*PostgresJobStateRepository)(nil)
+
+// jobStateColumns are the columns scanned by scanJobState.
+const jobStateColumns = `name, last_run_at, last_duration_ms, last_error, next_run_at, updated_at`
+
+// PostgresJobStateRepository implements the JobStateRepository interface using Postgres.
+type PostgresJobStateRepository struct {
+	db queryer
+}
+
+// NewPostgresJobStateRepository creates a new instance of PostgresJobStateRepository.
+func NewPostgresJobStateRepository(db *sql.DB) *PostgresJobStateRepository {
+	return &PostgresJobStateRepository{db: db}
+}
+
+// GetJobStates retrieves the states of all jobs from the database, ordered by name.
+func (r *PostgresJobStateRepository) GetJobStates(ctx context.Context) ([]*m.JobState, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+jobStateColumns+` FROM job_states ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job states: %w", err)
+	}
+	defer rows.Close()
+
+	var states []*m.JobState
+	for rows.Next() {
+		state, err := scanJobState(rows)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during job states rows iteration: %w", err)
+	}
+	return states, nil
+}
+
+// GetJobState retrieves the state of the job with the name from the database.
+func (r *PostgresJobStateRepository) GetJobState(ctx context.Context, name string) (*m.JobState, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+jobStateColumns+` FROM job_states WHERE name = $1`, name)
+	state, err := scanJobState(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return state, err
+}
+
+// SaveJobState inserts or replaces the state of the job in the database.
+func (r *PostgresJobStateRepository) SaveJobState(ctx context.Context, state *m.JobState) error {
+	query := `
+		INSERT INTO job_states (name, last_run_at, last_duration_ms, last_error, next_run_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT(name) DO UPDATE SET
+			last_run_at = excluded.last_run_at,
+			last_duration_ms = excluded.last_duration_ms,
+			last_error = excluded.last_error,
+			next_run_at = excluded.next_run_at,
+			updated_at = excluded.updated_at
+	`
+	state.UpdatedAt = time.Now().UTC()
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		state.Name,
+		utcTime(state.LastRunAt),
+		state.LastDuration.Milliseconds(),
+		state.LastError,
+		state.NextRunAt.UTC(),
+		state.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save job state: %w", err)
+	}
+	return nil
+}
+
+// scanJobState scans a row of jobStateColumns.
+func scanJobState(row interface{ Scan(dest ...any) error }) (*m.JobState, error) {
+	var state m.JobState
+	var lastRunAt sql.NullTime
+	var durationMs int64
+	err := row.Scan(&state.Name, &lastRunAt, &durationMs, &state.LastError, &state.NextRunAt, &state.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan job state: %w", err)
+	}
+	if lastRunAt.Valid {
+		state.LastRunAt = &lastRunAt.Time
+	}
+	state.LastDuration = time.Duration(durationMs) * time.Millisecond
+	return &state, nil
+}
@@ -0,0 +1,58 @@
+-- The schema matching the SQLite migrations up to 0004_chat_states.
+
+CREATE TABLE users (
+	id BIGSERIAL PRIMARY KEY,
+	telegram_id BIGINT NOT NULL,
+	username TEXT NOT NULL DEFAULT '',
+	first_name TEXT NOT NULL DEFAULT '',
+	last_name TEXT NOT NULL DEFAULT '',
+	last_notified TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_users_telegram_id ON users (telegram_id);
+
+CREATE TABLE units (
+	id BIGSERIAL PRIMARY KEY,
+	provider TEXT NOT NULL,
+	external_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	city TEXT NOT NULL,
+	storage TEXT NOT NULL,
+	size TEXT NOT NULL,
+	dimension TEXT NOT NULL DEFAULT '',
+	price DOUBLE PRECISION NOT NULL,
+	available BOOLEAN NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	url TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (provider, external_id)
+);
+
+CREATE INDEX idx_units_city_storage_size ON units (city, storage, size);
+
+CREATE TABLE subscriptions (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	provider TEXT NOT NULL DEFAULT '',
+	city TEXT NOT NULL,
+	storage TEXT NOT NULL,
+	unit_size TEXT NOT NULL,
+	status TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_subscriptions_user_id ON subscriptions (user_id);
+
+CREATE TABLE chat_states (
+	chat_id BIGINT PRIMARY KEY,
+	step TEXT NOT NULL,
+	city TEXT NOT NULL DEFAULT '',
+	storage TEXT NOT NULL DEFAULT '',
+	unit_size TEXT NOT NULL DEFAULT '',
+	subscription_id BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,11 @@
+-- The channels the users receive the notifications through, in addition to or instead of Telegram.
+
+CREATE TABLE delivery_channels (
+	user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	type TEXT NOT NULL,
+	address TEXT NOT NULL DEFAULT '',
+	enabled BOOLEAN NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (user_id, type)
+);
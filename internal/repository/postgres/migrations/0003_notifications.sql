@@ -0,0 +1,24 @@
+-- The outbox of the notifications, one row per alert and delivery channel.
+
+CREATE TABLE notifications (
+	id BIGSERIAL PRIMARY KEY,
+	idempotency_key TEXT NOT NULL,
+	user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	subscription_id BIGINT NOT NULL,
+	unit_id BIGINT NOT NULL,
+	channel TEXT NOT NULL,
+	address TEXT NOT NULL DEFAULT '',
+	subject TEXT NOT NULL DEFAULT '',
+	text TEXT NOT NULL,
+	unit JSONB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMPTZ NOT NULL,
+	sent_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (idempotency_key, channel)
+);
+
+CREATE INDEX idx_notifications_status_next_attempt_at ON notifications (status, next_attempt_at);
@@ -0,0 +1,6 @@
+-- The delivery mode of the subscriptions. A digest is sent at digest_time, minutes after midnight
+-- in the time zone of the user, and a weekly digest on digest_weekday, 0 being Sunday.
+
+ALTER TABLE subscriptions ADD COLUMN delivery_mode TEXT NOT NULL DEFAULT 'instant';
+ALTER TABLE subscriptions ADD COLUMN digest_time INTEGER NOT NULL DEFAULT 540;
+ALTER TABLE subscriptions ADD COLUMN digest_weekday INTEGER NOT NULL DEFAULT 1;
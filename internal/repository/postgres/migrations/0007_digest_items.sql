@@ -0,0 +1,14 @@
+-- The units collected for the digests of the users, until the digests are sent.
+
+CREATE TABLE digest_items (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	subscription_id BIGINT NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+	unit_id BIGINT NOT NULL,
+	unit JSONB NOT NULL,
+	due_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (subscription_id, unit_id, due_at)
+);
+
+CREATE INDEX idx_digest_items_due_at ON digest_items (due_at);
@@ -0,0 +1,8 @@
+-- The price rules of the subscriptions: the maximum monthly price, and the minimum price drop
+-- in percent worth an alert. Zero turns a rule off.
+
+ALTER TABLE subscriptions ADD COLUMN max_price DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN min_price_drop INTEGER NOT NULL DEFAULT 0;
+
+-- The price of a unit before the drop it is in a digest for, zero if it became available.
+ALTER TABLE digest_items ADD COLUMN previous_price DOUBLE PRECISION NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- The units announced to the subscriptions. A unit is announced to a subscription once,
+-- its rows are deleted when it is observed unavailable, so it is announced again when it comes back.
+
+CREATE TABLE unit_alerts (
+	subscription_id BIGINT NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+	unit_id BIGINT NOT NULL,
+	announced_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (subscription_id, unit_id)
+);
+
+CREATE INDEX idx_unit_alerts_unit_id ON unit_alerts (unit_id);
@@ -0,0 +1,8 @@
+-- The unit events detected by the parser, stored together with the units and deleted once the notifier
+-- handled them, so no event is lost when the process stops in between.
+
+CREATE TABLE unit_events (
+	id BIGSERIAL PRIMARY KEY,
+	event JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,10 @@
+-- The run history of the scheduled jobs, so their schedule carries on across restarts.
+
+CREATE TABLE job_states (
+	name TEXT PRIMARY KEY,
+	last_run_at TIMESTAMPTZ,
+	last_duration_ms BIGINT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_run_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+-- The wizard keeps the provider of the selected storage, the subscription is limited to it.
+
+ALTER TABLE chat_states ADD COLUMN provider TEXT NOT NULL DEFAULT '';
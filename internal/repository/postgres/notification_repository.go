@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.NotificationRepository = (*PostgresNotificationRepository)(nil)
+
+// notificationColumns are the columns scanned by scanNotification.
+const notificationColumns = `id, idempotency_key, user_id, subscription_id, unit_id, channel, address, subject, text, unit,
+	status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at`
+
+// PostgresNotificationRepository implements the NotificationRepository interface using PostgreSQL.
+type PostgresNotificationRepository struct {
+	db queryer
+}
+
+// NewPostgresNotificationRepository creates a new instance of PostgresNotificationRepository.
+func NewPostgresNotificationRepository(db *sql.DB) *PostgresNotificationRepository {
+	return &PostgresNotificationRepository{db: db}
+}
+
+// EnqueueNotification inserts a notification unless one with the same idempotency key and channel exists.
+// The ID of the stored notification is written back into notification.
+func (r *PostgresNotificationRepository) EnqueueNotification(ctx context.Context, notification *m.Notification) (bool, error) {
+	query := `
+		INSERT INTO notifications (idempotency_key, user_id, subscription_id, unit_id, channel, address, subject, text, unit,
+			status, attempts, last_error, next_attempt_at, sent_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT(idempotency_key, channel) DO NOTHING
+		RETURNING id
+	`
+	unit, err := json.Marshal(notification.Unit)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode notification unit: %w", err)
+	}
+
+	now := time.Now().UTC()
+	notification.CreatedAt = now
+	notification.UpdatedAt = now
+	err = r.db.QueryRowContext(
+		ctx,
+		query,
+		notification.IdempotencyKey,
+		notification.UserID,
+		notification.SubscriptionID,
+		notification.UnitID,
+		notification.Channel,
+		notification.Address,
+		notification.Subject,
+		notification.Text,
+		string(unit),
+		notification.Status,
+		notification.Attempts,
+		notification.LastError,
+		notification.NextAttemptAt.UTC(),
+		utcTime(notification.SentAt),
+		notification.CreatedAt,
+		notification.UpdatedAt,
+	).Scan(&notification.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+	return true, nil
+}
+
+// GetDueNotifications retrieves the pending and failed notifications due at now from the database.
+func (r *PostgresNotificationRepository) GetDueNotifications(ctx context.Context, now time.Time, limit int) ([]*m.Notification, error) {
+	query := `
+		SELECT ` + notificationColumns + `
+		FROM notifications
+		WHERE status IN ($1, $2) AND next_attempt_at <= $3
+		ORDER BY next_attempt_at, id
+		LIMIT $4
+	`
+	rows, err := r.db.QueryContext(ctx, query, m.NotificationStatusPending, m.NotificationStatusFailed, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*m.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during notifications rows iteration: %w", err)
+	}
+	return notifications, nil
+}
+
+// ClaimDueNotifications takes the pending and failed notifications due at now and leases them until leaseUntil.
+// The rows are locked while they are claimed, a row locked by another dispatcher is skipped.
+func (r *PostgresNotificationRepository) ClaimDueNotifications(
+	ctx context.Context,
+	now, leaseUntil time.Time,
+	limit int,
+) ([]*m.Notification, error) {
+	query := `
+		UPDATE notifications SET next_attempt_at = $1
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE status IN ($2, $3) AND next_attempt_at <= $4
+			ORDER BY next_attempt_at, id
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + notificationColumns
+	rows, err := r.db.QueryContext(ctx, query,
+		leaseUntil.UTC(), m.NotificationStatusPending, m.NotificationStatusFailed, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*m.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during notifications rows iteration: %w", err)
+	}
+	// RETURNING does not keep the order of the subquery, the IDs follow the order of enqueueing
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].ID < notifications[j].ID })
+	return notifications, nil
+}
+
+// GetNotificationByID retrieves a notification by ID from the database.
+func (r *PostgresNotificationRepository) GetNotificationByID(ctx context.Context, id int64) (*m.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE id = $1`
+	notification, err := scanNotification(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return notification, nil
+}
+
+// UpdateNotification updates the delivery status of a notification in the database.
+func (r *PostgresNotificationRepository) UpdateNotification(ctx context.Context, notification *m.Notification) error {
+	query := `
+		UPDATE notifications
+		SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4, sent_at = $5, updated_at = $6
+		WHERE id = $7
+	`
+	notification.UpdatedAt = time.Now().UTC()
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		notification.Status,
+		notification.Attempts,
+		notification.LastError,
+		notification.NextAttemptAt.UTC(),
+		utcTime(notification.SentAt),
+		notification.UpdatedAt,
+		notification.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update notification: %w", err)
+	}
+	return nil
+}
+
+// DeleteNotificationsBefore deletes the sent and given up notifications last updated before the time.
+func (r *PostgresNotificationRepository) DeleteNotificationsBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM notifications WHERE status IN ($1, $2) AND updated_at < $3`
+	result, err := r.db.ExecContext(ctx, query, m.NotificationStatusSent, m.NotificationStatusDead, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete notifications: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete notifications: %w", err)
+	}
+	return deleted, nil
+}
+
+// scanNotification scans a row of notificationColumns.
+func scanNotification(row interface{ Scan(dest ...any) error }) (*m.Notification, error) {
+	var notification m.Notification
+	var unit string
+	var sentAt sql.NullTime
+	err := row.Scan(
+		&notification.ID,
+		&notification.IdempotencyKey,
+		&notification.UserID,
+		&notification.SubscriptionID,
+		&notification.UnitID,
+		&notification.Channel,
+		&notification.Address,
+		&notification.Subject,
+		&notification.Text,
+		&unit,
+		&notification.Status,
+		&notification.Attempts,
+		&notification.LastError,
+		&notification.NextAttemptAt,
+		&sentAt,
+		&notification.CreatedAt,
+		&notification.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan notification: %w", err)
+	}
+	if err := json.Unmarshal([]byte(unit), &notification.Unit); err != nil {
+		return nil, fmt.Errorf("failed to decode notification unit: %w", err)
+	}
+	if sentAt.Valid {
+		notification.SentAt = &sentAt.Time
+	}
+	return &notification, nil
+}
+
+// utcTime converts an optional time to UTC for storing.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
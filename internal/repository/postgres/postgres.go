@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "github.com/lib/pq" // Register the PostgreSQL driver
+)
+
+// NewPostgresDB initializes a new PostgreSQL connection pool.
+// Unlike SQLite, PostgreSQL handles concurrent writers, so several bot replicas can share the database.
+func NewPostgresDB(url string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
+	}
+
+	// Set reasonable connection limits
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(5)
+	db.SetConnMaxLifetime(30 * time.Minute)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to ping PostgreSQL database: %w", err)
+	}
+
+	slog.Info("PostgreSQL database connected successfully")
+	return db, nil
+}
@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"time"
+)
+
+var _ repository.SubscriptionRepository = (*PostgresSubscriptionRepository)(nil)
+
+// subscriptionColumns are the columns scanned by scanSubscription.
+const subscriptionColumns = `id, user_id, provider, city, storage, unit_size, status,
+	delivery_mode, digest_time, digest_weekday, max_price, min_price_drop, created_at, updated_at`
+
+// PostgresSubscriptionRepository implements the SubscriptionRepository interface using PostgreSQL.
+type PostgresSubscriptionRepository struct {
+	db queryer
+}
+
+// NewPostgresSubscriptionRepository creates a new instance of PostgresSubscriptionRepository.
+func NewPostgresSubscriptionRepository(db *sql.DB) *PostgresSubscriptionRepository {
+	return &PostgresSubscriptionRepository{db: db}
+}
+
+// CreateSubscription inserts or updates a subscription in the database.
+// The ID of the stored subscription is written back into subscription.
+func (r *PostgresSubscriptionRepository) CreateSubscription(ctx context.Context, subscription *m.Subscription) error {
+	query := `
+        INSERT INTO subscriptions (
+            user_id, provider, city, storage, unit_size, status, delivery_mode, digest_time, digest_weekday,
+            max_price, min_price_drop, created_at, updated_at
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT(id) DO UPDATE SET
+            user_id = excluded.user_id,
+            provider = excluded.provider,
+            city = excluded.city,
+            storage = excluded.storage,
+            unit_size = excluded.unit_size,
+            status = excluded.status,
+            delivery_mode = excluded.delivery_mode,
+            digest_time = excluded.digest_time,
+            digest_weekday = excluded.digest_weekday,
+            max_price = excluded.max_price,
+            min_price_drop = excluded.min_price_drop,
+            updated_at = excluded.updated_at
+        RETURNING id
+    `
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		subscription.UserID,
+		subscription.Provider,
+		subscription.City,
+		subscription.Storage,
+		subscription.UnitSize,
+		subscription.Status,
+		subscription.DeliveryMode,
+		subscription.DigestTime,
+		subscription.DigestWeekday,
+		subscription.MaxPrice,
+		subscription.MinPriceDrop,
+		time.Now(),
+		time.Now(),
+	).Scan(&subscription.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return nil
+}
+
+// GetSubscriptionByID retrieves a subscription by ID from the database.
+func (r *PostgresSubscriptionRepository) GetSubscriptionByID(ctx context.Context, id int64) (*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1`
+	subscription, err := scanSubscription(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get subscription by ID: %w", err)
+	}
+	return subscription, nil
+}
+
+// GetSubscriptionsByUserID retrieves all subscriptions by user ID from the database.
+func (r *PostgresSubscriptionRepository) GetSubscriptionsByUserID(ctx context.Context, userID int64) ([]*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE user_id = $1`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscriptions by user ID: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*m.Subscription
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription row: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during rows iteration: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// GetAllSubscriptions retrieves all subscriptions from the database.
+func (r *PostgresSubscriptionRepository) GetAllSubscriptions(ctx context.Context) ([]*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*m.Subscription
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription row: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during rows iteration: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// GetActiveSubscriptions retrieves all active subscriptions from the database.
+func (r *PostgresSubscriptionRepository) GetActiveSubscriptions(ctx context.Context) ([]*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE status = 'active'`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*m.Subscription
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan active subscription row: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during active subscriptions iteration: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// UpdateSubscription updates a subscription in the database.
+func (r *PostgresSubscriptionRepository) UpdateSubscription(ctx context.Context, subscription *m.Subscription) error {
+	query := `
+		UPDATE subscriptions
+		SET user_id = $1, provider = $2, city = $3, storage = $4, unit_size = $5, status = $6,
+			delivery_mode = $7, digest_time = $8, digest_weekday = $9, max_price = $10, min_price_drop = $11,
+			updated_at = $12
+		WHERE id = $13
+	`
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		subscription.UserID,
+		subscription.Provider,
+		subscription.City,
+		subscription.Storage,
+		subscription.UnitSize,
+		subscription.Status,
+		subscription.DeliveryMode,
+		subscription.DigestTime,
+		subscription.DigestWeekday,
+		subscription.MaxPrice,
+		subscription.MinPriceDrop,
+		time.Now(),
+		subscription.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return nil
+}
+
+// DeleteSubscription deletes a subscription from the database.
+func (r *PostgresSubscriptionRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM subscriptions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	return nil
+}
+
+// scanSubscription scans a row of subscriptionColumns.
+func scanSubscription(row interface{ Scan(dest ...any) error }) (*m.Subscription, error) {
+	var subscription m.Subscription
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.UserID,
+		&subscription.Provider,
+		&subscription.City,
+		&subscription.Storage,
+		&subscription.UnitSize,
+		&subscription.Status,
+		&subscription.DeliveryMode,
+		&subscription.DigestTime,
+		&subscription.DigestWeekday,
+		&subscription.MaxPrice,
+		&subscription.MinPriceDrop,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
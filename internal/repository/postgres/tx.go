@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.Transactor = (*PostgresTransactor)(nil)
+
+// queryer is the part of *sql.DB and *sql.Tx the repositories use,
+// so the same repository code runs inside and outside a transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// PostgresTransactor runs units of work over the PostgreSQL repositories.
+type PostgresTransactor struct {
+	db *sql.DB
+}
+
+// NewPostgresTransactor creates a new instance of PostgresTransactor.
+func NewPostgresTransactor(db *sql.DB) *PostgresTransactor {
+	return &PostgresTransactor{db: db}
+}
+
+// WithTx runs fn with repositories bound to a single transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (t *PostgresTransactor) WithTx(ctx context.Context, fn func(ctx context.Context, repos repository.Repositories) error) error {
+	return inTx(ctx, t.db, func(tx *sql.Tx) error {
+		return fn(ctx, newRepositories(tx))
+	})
+}
+
+// NewRepositories creates the PostgreSQL repositories over the database.
+func NewRepositories(db *sql.DB) repository.Repositories {
+	return newRepositories(db)
+}
+
+// newRepositories creates the PostgreSQL repositories over a database or a transaction.
+func newRepositories(db queryer) repository.Repositories {
+	return repository.Repositories{
+		Users:            &PostgresUserRepository{db: db},
+		Units:            &PostgresUnitRepository{db: db},
+		Subscriptions:    &PostgresSubscriptionRepository{db: db},
+		ChatStates:       &PostgresChatStateRepository{db: db},
+		DeliveryChannels: &PostgresDeliveryChannelRepository{db: db},
+		Notifications:    &PostgresNotificationRepository{db: db},
+		Digests:          &PostgresDigestRepository{db: db},
+		UnitAlerts:       &PostgresUnitAlertRepository{db: db},
+		UnitEvents:       &PostgresUnitEventRepository{db: db},
+		JobStates:        &PostgresJobStateRepository{db: db},
+	}
+}
+
+// inTx runs fn in a transaction. A repository that is already bound to a transaction joins it.
+func inTx(ctx context.Context, db queryer, fn func(tx *sql.Tx) error) error {
+	switch db := db.(type) {
+	case *sql.Tx:
+		return fn(db)
+	case *sql.DB:
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported database handle %T", db)
+	}
+}
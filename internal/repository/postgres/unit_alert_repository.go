@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UnitAlertRepository = (*PostgresUnitAlertRepository)(nil)
+
+// PostgresUnitAlertRepository implements the UnitAlertRepository interface using Postgres.
+type PostgresUnitAlertRepository struct {
+	db queryer
+}
+
+// NewPostgresUnitAlertRepository creates a new instance of PostgresUnitAlertRepository.
+func NewPostgresUnitAlertRepository(db *sql.DB) *PostgresUnitAlertRepository {
+	return &PostgresUnitAlertRepository{db: db}
+}
+
+// ClaimUnitAlert records the announcement of the unit to the subscription unless it is recorded already.
+func (r *PostgresUnitAlertRepository) ClaimUnitAlert(ctx context.Context, subscriptionID, unitID int64, at time.Time) (bool, error) {
+	query := `
+		INSERT INTO unit_alerts (subscription_id, unit_id, announced_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT(subscription_id, unit_id) DO NOTHING
+	`
+	result, err := r.db.ExecContext(ctx, query, subscriptionID, unitID, at.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim unit alert: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim unit alert: %w", err)
+	}
+	return claimed > 0, nil
+}
+
+// ClearUnitAlerts forgets the announcements of the unit.
+func (r *PostgresUnitAlertRepository) ClearUnitAlerts(ctx context.Context, unitID int64) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM unit_alerts WHERE unit_id = $1", unitID); err != nil {
+		return fmt.Errorf("failed to clear unit alerts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UnitRepository = (*PostgresUnitRepository)(nil)
+
+// PostgresUnitRepository implements the UnitRepository interface using PostgreSQL.
+type PostgresUnitRepository struct {
+	db queryer
+}
+
+// NewPostgresUnitRepository creates a new instance of PostgresUnitRepository.
+func NewPostgresUnitRepository(db *sql.DB) *PostgresUnitRepository {
+	return &PostgresUnitRepository{db: db}
+}
+
+// upsertUnitQuery inserts a unit or updates the stored unit with the same provider and external identifier.
+const upsertUnitQuery = `
+	INSERT INTO units (provider, external_id, name, city, storage, size, dimension, price, available, description, url, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT(provider, external_id) DO UPDATE SET
+		name = excluded.name,
+		city = excluded.city,
+		storage = excluded.storage,
+		size = excluded.size,
+		dimension = excluded.dimension,
+		price = excluded.price,
+		available = excluded.available,
+		description = excluded.description,
+		url = excluded.url,
+		updated_at = excluded.updated_at
+	RETURNING id
+`
+
+// CreateUnit inserts or updates a unit in the database.
+// Units are matched by their provider and external identifier, the ID of the stored unit is written back into unit.
+func (r *PostgresUnitRepository) CreateUnit(ctx context.Context, unit *m.Unit) error {
+	err := r.db.QueryRowContext(ctx, upsertUnitQuery, upsertUnitArgs(unit)...).Scan(&unit.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save unit: %w", err)
+	}
+	return nil
+}
+
+// UpsertUnits inserts or updates the units in a single transaction using a prepared statement.
+// Either all units are saved or none, the IDs of the stored units are written back into units.
+func (r *PostgresUnitRepository) UpsertUnits(ctx context.Context, units []m.Unit) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, upsertUnitQuery)
+		if err != nil {
+			return fmt.Errorf("failed to prepare unit upsert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i := range units {
+			unit := &units[i]
+			if err := stmt.QueryRowContext(ctx, upsertUnitArgs(unit)...).Scan(&unit.ID); err != nil {
+				return fmt.Errorf("failed to save unit %s/%s: %w", unit.Provider, unit.ExternalID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// upsertUnitArgs returns the arguments of upsertUnitQuery for the unit.
+func upsertUnitArgs(unit *m.Unit) []any {
+	return []any{
+		unit.Provider,
+		unit.ExternalID,
+		unit.Name,
+		unit.City,
+		unit.Storage,
+		unit.Size,
+		unit.Dimension,
+		unit.Price,
+		unit.Available,
+		unit.Description,
+		unit.URL,
+		unit.CreatedAt,
+		unit.UpdatedAt,
+	}
+}
+
+// GetUnitByID retrieves a unit by ID from the database.
+func (r *PostgresUnitRepository) GetUnitByID(ctx context.Context, id int64) (*m.Unit, error) {
+	query := `
+		SELECT id, provider, external_id, name, city, storage, size, dimension, price, available, description, url, created_at, updated_at 
+		FROM units 
+		WHERE id = $1
+	`
+	row := r.db.QueryRowContext(ctx, query, id)
+
+	var unit m.Unit
+	err := row.Scan(
+		&unit.ID,
+		&unit.Provider,
+		&unit.ExternalID,
+		&unit.Name,
+		&unit.City,
+		&unit.Storage,
+		&unit.Size,
+		&unit.Dimension,
+		&unit.Price,
+		&unit.Available,
+		&unit.Description,
+		&unit.URL,
+		&unit.CreatedAt,
+		&unit.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get unit by ID: %w", err)
+	}
+	return &unit, nil
+}
+
+// GetAllUnits retrieves all units from the database.
+func (r *PostgresUnitRepository) GetAllUnits(ctx context.Context) ([]*m.Unit, error) {
+	query := `
+		SELECT id, provider, external_id, name, city, storage, size, dimension, price, available, description, url, created_at, updated_at 
+		FROM units
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all units: %w", err)
+	}
+	defer rows.Close()
+
+	var units []*m.Unit
+	for rows.Next() {
+		var unit m.Unit
+		if err := rows.Scan(
+			&unit.ID,
+			&unit.Provider,
+			&unit.ExternalID,
+			&unit.Name,
+			&unit.City,
+			&unit.Storage,
+			&unit.Size,
+			&unit.Dimension,
+			&unit.Price,
+			&unit.Available,
+			&unit.Description,
+			&unit.URL,
+			&unit.CreatedAt,
+			&unit.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan unit row: %w", err)
+		}
+		units = append(units, &unit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during rows iteration: %w", err)
+	}
+	return units, nil
+}
+
+// GetCities retrieves the list of cities from the database.
+func (r *PostgresUnitRepository) GetCities(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT DISTINCT city 
+		FROM units
+	`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cities: %w", err)
+	}
+	defer rows.Close()
+
+	var cities []string
+	for rows.Next() {
+		var city string
+		if err := rows.Scan(&city); err != nil {
+			return nil, fmt.Errorf("failed to scan city row: %w", err)
+		}
+		cities = append(cities, city)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during cities rows iteration: %w", err)
+	}
+	return cities, nil
+}
+
+// GetStoragesByCity retrieves the storages of every provider in the city from the database.
+func (r *PostgresUnitRepository) GetStoragesByCity(ctx context.Context, city string) ([]m.Storage, error) {
+	query := `
+		SELECT DISTINCT provider, storage
+		FROM units
+		WHERE city = $1
+		ORDER BY storage, provider
+	`
+	rows, err := r.db.QueryContext(ctx, query, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storages by city: %w", err)
+	}
+	defer rows.Close()
+
+	var storages []m.Storage
+	for rows.Next() {
+		var storage m.Storage
+		if err := rows.Scan(&storage.Provider, &storage.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan storage row: %w", err)
+		}
+		storages = append(storages, storage)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during storages rows iteration: %w", err)
+	}
+	return storages, nil
+}
+
+// GetUnitSizesByStorage retrieves the unit sizes of the storage of a provider in the city from the database.
+func (r *PostgresUnitRepository) GetUnitSizesByStorage(ctx context.Context, storage m.Storage, city string) ([]string, error) {
+	query := `
+		SELECT DISTINCT size
+		FROM units
+		WHERE provider = $1 AND storage = $2 AND city = $3
+	`
+	rows, err := r.db.QueryContext(ctx, query, storage.Provider, storage.Name, city)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unit sizes by storage: %w", err)
+	}
+	defer rows.Close()
+
+	var sizes []string
+	for rows.Next() {
+		var size string
+		if err := rows.Scan(&size); err != nil {
+			return nil, fmt.Errorf("failed to scan size row: %w", err)
+		}
+		sizes = append(sizes, size)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during unit sizes rows iteration: %w", err)
+	}
+	return sizes, nil
+}
+
+// UpdateUnit updates a unit in the database.
+func (r *PostgresUnitRepository) UpdateUnit(ctx context.Context, unit *m.Unit) error {
+	query := `
+		UPDATE units 
+		SET provider = $1, external_id = $2, name = $3, city = $4, storage = $5, size = $6, dimension = $7, price = $8, available = $9, description = $10, url = $11, updated_at = $12
+		WHERE id = $13
+	`
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		unit.Provider,
+		unit.ExternalID,
+		unit.Name,
+		unit.City,
+		unit.Storage,
+		unit.Size,
+		unit.Dimension,
+		unit.Price,
+		unit.Available,
+		unit.Description,
+		unit.URL,
+		time.Now(),
+		unit.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update unit: %w", err)
+	}
+	return nil
+}
+
+// DeleteUnit deletes a unit from the database.
+func (r *PostgresUnitRepository) DeleteUnit(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM units WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete unit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UserRepository = (*PostgresUserRepository)(nil)
+
+// NewPostgresUserRepository creates a new PostgresUserRepository.
+func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+// userColumns are the columns scanned by scanUser.
+const userColumns = `id, telegram_id, username, first_name, last_name, last_notified, active,
+	time_zone, quiet_hours_start, quiet_hours_end, created_at, updated_at`
+
+// PostgresUserRepository implements the UserRepository interface using PostgreSQL.
+type PostgresUserRepository struct {
+	db queryer
+}
+
+// CreateUser inserts or updates a user in the database.
+// A user without an ID is inserted, the ID of the stored user is written back into user.
+func (r *PostgresUserRepository) CreateUser(ctx context.Context, user *m.User) error {
+	query := `
+		INSERT INTO users (
+			id, telegram_id, username, first_name, last_name, last_notified, active,
+			time_zone, quiet_hours_start, quiet_hours_end, created_at, updated_at
+		)
+		VALUES (COALESCE($1, nextval(pg_get_serial_sequence('users', 'id'))), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT(id) DO UPDATE SET
+			telegram_id = excluded.telegram_id,
+			username = excluded.username,
+			first_name = excluded.first_name,
+			last_name = excluded.last_name,
+			last_notified = excluded.last_notified,
+			active = excluded.active,
+			time_zone = excluded.time_zone,
+			quiet_hours_start = excluded.quiet_hours_start,
+			quiet_hours_end = excluded.quiet_hours_end,
+			updated_at = excluded.updated_at
+		RETURNING id
+	`
+	// A NULL ID takes the next value of the sequence, an ID of zero would be stored as is
+	id := sql.NullInt64{Int64: user.ID, Valid: user.ID != 0}
+	err := r.db.QueryRowContext(
+		ctx,
+		query,
+		id,
+		user.TelegramID,
+		user.UserName,
+		user.FirstName,
+		user.LastName,
+		user.LastNotified,
+		user.Active,
+		user.TimeZone,
+		user.QuietHoursStart,
+		user.QuietHoursEnd,
+		user.CreatedAt,
+		user.UpdatedAt,
+	).Scan(&user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save user: %w", err)
+	}
+	return nil
+}
+
+// GetUserByID retrieves a user by ID from the database.
+func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id int64) (*m.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+	return user, nil
+}
+
+// GetByTelegramID retrieves a user by Telegram ID from the database.
+func (r *PostgresUserRepository) GetByTelegramID(ctx context.Context, id int64) (*m.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE telegram_id = $1`
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user by Telegram ID: %w", err)
+	}
+	return user, nil
+}
+
+// GetAllUsers retrieves all users from the database.
+func (r *PostgresUserRepository) GetAllUsers(ctx context.Context) ([]*m.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*m.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// UpdateUser updates a user in the database.
+func (r *PostgresUserRepository) UpdateUser(ctx context.Context, user *m.User) error {
+	query := `
+		UPDATE users
+		SET telegram_id = $1, username = $2, first_name = $3, last_name = $4, last_notified = $5, active = $6,
+			time_zone = $7, quiet_hours_start = $8, quiet_hours_end = $9, updated_at = $10
+		WHERE id = $11
+	`
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		user.TelegramID,
+		user.UserName,
+		user.FirstName,
+		user.LastName,
+		user.LastNotified,
+		user.Active,
+		user.TimeZone,
+		user.QuietHoursStart,
+		user.QuietHoursEnd,
+		user.UpdatedAt,
+		user.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser deletes a user from the database.
+func (r *PostgresUserRepository) DeleteUser(ctx context.Context, user *m.User) error {
+	query := `DELETE FROM users WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+// scanUser scans a row of userColumns.
+func scanUser(row interface{ Scan(dest ...any) error }) (*m.User, error) {
+	var user m.User
+	err := row.Scan(
+		&user.ID,
+		&user.TelegramID,
+		&user.UserName,
+		&user.FirstName,
+		&user.LastName,
+		&user.LastNotified,
+		&user.Active,
+		&user.TimeZone,
+		&user.QuietHoursStart,
+		&user.QuietHoursEnd,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -1,38 +1,134 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
 )
 
 // UserRepository defines the methods to interact with the user data.
 type UserRepository interface {
-	CreateUser(user *m.User) error
-	GetAllUsers() ([]*m.User, error)
-	GetByTelegramID(id int64) (*m.User, error)
-	GetUserByID(id int64) (*m.User, error)
-	UpdateUser(user *m.User) error
-	DeleteUser(user *m.User) error
+	CreateUser(ctx context.Context, user *m.User) error
+	GetAllUsers(ctx context.Context) ([]*m.User, error)
+	GetByTelegramID(ctx context.Context, id int64) (*m.User, error)
+	GetUserByID(ctx context.Context, id int64) (*m.User, error)
+	UpdateUser(ctx context.Context, user *m.User) error
+	DeleteUser(ctx context.Context, user *m.User) error
 }
 
 // UnitRepository defines the methods to interact with the unit data.
 type UnitRepository interface {
-	CreateUnit(unit *m.Unit) error
-	GetAllUnits() ([]*m.Unit, error)
-	GetCities() ([]string, error)
-	GetStoragesByCity(text string) ([]string, error)
-	GetUnitByID(id int64) (*m.Unit, error)
-	GetUnitSizesByStorage(text string) ([]string, error)
-	UpdateUnit(unit *m.Unit) error
-	DeleteUnit(id int64) error
+	CreateUnit(ctx context.Context, unit *m.Unit) error
+	UpsertUnits(ctx context.Context, units []m.Unit) error
+	GetAllUnits(ctx context.Context) ([]*m.Unit, error)
+	GetCities(ctx context.Context) ([]string, error)
+	GetStoragesByCity(ctx context.Context, city string) ([]m.Storage, error)
+	GetUnitByID(ctx context.Context, id int64) (*m.Unit, error)
+	GetUnitSizesByStorage(ctx context.Context, storage m.Storage, city string) ([]string, error)
+	UpdateUnit(ctx context.Context, unit *m.Unit) error
+	DeleteUnit(ctx context.Context, id int64) error
 }
 
 // SubscriptionRepository defines the methods to interact with the subscription data.
 type SubscriptionRepository interface {
-	CreateSubscription(subscription *m.Subscription) error
-	GetActiveSubscriptions() ([]*m.Subscription, error)
-	GetAllSubscriptions() ([]*m.Subscription, error)
-	GetSubscriptionByID(id int64) (*m.Subscription, error)
-	GetSubscriptionsByUserID(id int64) ([]*m.Subscription, error)
-	UpdateSubscription(subscription *m.Subscription) error
-	DeleteSubscription(id int64) error
+	CreateSubscription(ctx context.Context, subscription *m.Subscription) error
+	GetActiveSubscriptions(ctx context.Context) ([]*m.Subscription, error)
+	GetAllSubscriptions(ctx context.Context) ([]*m.Subscription, error)
+	GetSubscriptionByID(ctx context.Context, id int64) (*m.Subscription, error)
+	GetSubscriptionsByUserID(ctx context.Context, id int64) ([]*m.Subscription, error)
+	UpdateSubscription(ctx context.Context, subscription *m.Subscription) error
+	DeleteSubscription(ctx context.Context, id int64) error
+}
+
+// ChatStateRepository defines the methods to interact with the chat state data.
+type ChatStateRepository interface {
+	GetChatState(ctx context.Context, chatID int64) (*m.ChatState, error)
+	SaveChatState(ctx context.Context, state *m.ChatState) error
+	DeleteChatState(ctx context.Context, chatID int64) error
+}
+
+// DeliveryChannelRepository defines the methods to interact with the delivery channels of the users.
+type DeliveryChannelRepository interface {
+	GetDeliveryChannels(ctx context.Context, userID int64) ([]*m.DeliveryChannel, error)
+	SaveDeliveryChannel(ctx context.Context, channel *m.DeliveryChannel) error
+	DeleteDeliveryChannel(ctx context.Context, userID int64, channelType m.ChannelType) error
+}
+
+// NotificationRepository defines the methods to interact with the notification outbox.
+type NotificationRepository interface {
+	// EnqueueNotification stores a new notification and reports whether it was stored.
+	// A notification with the idempotency key and channel of a stored one is ignored.
+	EnqueueNotification(ctx context.Context, notification *m.Notification) (bool, error)
+	// GetDueNotifications returns up to limit pending or failed notifications due at now, the oldest first.
+	GetDueNotifications(ctx context.Context, now time.Time, limit int) ([]*m.Notification, error)
+	// ClaimDueNotifications takes up to limit pending or failed notifications due at now, the oldest first,
+	// and moves their next attempt to leaseUntil, so no other dispatcher takes them meanwhile.
+	// A claimed notification that is not updated before the lease runs out, e.g. after a crash, is due again.
+	ClaimDueNotifications(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*m.Notification, error)
+	GetNotificationByID(ctx context.Context, id int64) (*m.Notification, error)
+	UpdateNotification(ctx context.Context, notification *m.Notification) error
+	// DeleteNotificationsBefore deletes the sent and given up notifications last updated before the time
+	// and returns the number of deleted notifications.
+	DeleteNotificationsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DigestRepository defines the methods to interact with the units collected for the digests.
+type DigestRepository interface {
+	// AddDigestItem stores an item, replacing the unit and previous price of a stored item of the same subscription,
+	// unit and due time.
+	AddDigestItem(ctx context.Context, item *m.DigestItem) error
+	// GetDueDigestItems returns the items due at now, ordered by user and due time.
+	GetDueDigestItems(ctx context.Context, now time.Time) ([]*m.DigestItem, error)
+	DeleteDigestItems(ctx context.Context, ids []int64) error
+}
+
+// UnitAlertRepository keeps track of the units announced to the subscriptions.
+// A unit is announced to a subscription once, and again only after it was observed unavailable.
+type UnitAlertRepository interface {
+	// ClaimUnitAlert records that the unit is announced to the subscription at the time.
+	// It returns false if the unit was announced to the subscription before and not cleared since.
+	ClaimUnitAlert(ctx context.Context, subscriptionID, unitID int64, at time.Time) (bool, error)
+	// ClearUnitAlerts forgets the announcements of the unit to all subscriptions.
+	ClearUnitAlerts(ctx context.Context, unitID int64) error
+}
+
+// UnitEventRepository defines the methods to interact with the unit events handed over to the notifier.
+// The parser stores the events together with the units, and they are deleted once they were handled,
+// so the events of a stopped process are handled after the restart.
+type UnitEventRepository interface {
+	// AddUnitEvents stores the events and writes their IDs back into them.
+	AddUnitEvents(ctx context.Context, events []m.UnitEvent) error
+	// GetUnitEvents returns up to limit stored events with an ID greater than afterID, the oldest first.
+	GetUnitEvents(ctx context.Context, afterID int64, limit int) ([]m.UnitEvent, error)
+	DeleteUnitEvents(ctx context.Context, ids []int64) error
+}
+
+// JobStateRepository defines the methods to interact with the run history of the scheduled jobs.
+type JobStateRepository interface {
+	// GetJobStates returns the states of all jobs ordered by name.
+	GetJobStates(ctx context.Context) ([]*m.JobState, error)
+	GetJobState(ctx context.Context, name string) (*m.JobState, error)
+	// SaveJobState inserts or replaces the state of the job with the name.
+	SaveJobState(ctx context.Context, state *m.JobState) error
+}
+
+// Repositories groups the repositories taking part in a unit of work.
+type Repositories struct {
+	Users            UserRepository
+	Units            UnitRepository
+	Subscriptions    SubscriptionRepository
+	ChatStates       ChatStateRepository
+	DeliveryChannels DeliveryChannelRepository
+	Notifications    NotificationRepository
+	Digests          DigestRepository
+	UnitAlerts       UnitAlertRepository
+	UnitEvents       UnitEventRepository
+	JobStates        JobStateRepository
+}
+
+// Transactor runs units of work: the changes made through the repositories passed to fn are committed together
+// if fn returns nil, and discarded otherwise.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
 }
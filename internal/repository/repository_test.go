@@ -1 +1,60 @@
 package repository
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int
+		wantErr  bool
+	}{
+		{
+			name: "should order the migrations by version",
+			files: fstest.MapFS{
+				"m/0010_later.sql":  {Data: []byte("SELECT 10")},
+				"m/0002_second.sql": {Data: []byte("SELECT 2")},
+				"m/README.md":       {Data: []byte("not a migration")},
+			},
+			versions: []int{2, 10},
+		},
+		{
+			name:    "should reject a file without a version",
+			files:   fstest.MapFS{"m/initial.sql": {Data: []byte("SELECT 1")}},
+			wantErr: true,
+		},
+		{
+			name: "should reject duplicate versions",
+			files: fstest.MapFS{
+				"m/0001_a.sql": {Data: []byte("SELECT 1")},
+				"m/1_b.sql":    {Data: []byte("SELECT 1")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			migrations, err := LoadMigrations(tt.files, "m")
+
+			// Assert
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			versions := make([]int, 0, len(migrations))
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+			}
+			assert.Equal(t, tt.versions, versions)
+		})
+	}
+}
@@ -0,0 +1,872 @@
+// Package repositorytest provides the conformance suite every repository backend has to pass,
+// so the backends stay interchangeable.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+// Backend creates empty repositories and the transactor over them.
+// It is called once per test, so the tests do not see each other's data.
+type Backend func(t *testing.T) (repository.Repositories, repository.Transactor)
+
+// Run runs the conformance suite against the backend.
+func Run(t *testing.T, newBackend Backend) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, newBackend) })
+	t.Run("Units", func(t *testing.T) { testUnits(t, newBackend) })
+	t.Run("Subscriptions", func(t *testing.T) { testSubscriptions(t, newBackend) })
+	t.Run("ChatStates", func(t *testing.T) { testChatStates(t, newBackend) })
+	t.Run("DeliveryChannels", func(t *testing.T) { testDeliveryChannels(t, newBackend) })
+	t.Run("Notifications", func(t *testing.T) { testNotifications(t, newBackend) })
+	t.Run("Digests", func(t *testing.T) { testDigests(t, newBackend) })
+	t.Run("UnitAlerts", func(t *testing.T) { testUnitAlerts(t, newBackend) })
+	t.Run("UnitEvents", func(t *testing.T) { testUnitEvents(t, newBackend) })
+	t.Run("JobStates", func(t *testing.T) { testJobStates(t, newBackend) })
+	t.Run("Transactor", func(t *testing.T) { testTransactor(t, newBackend) })
+}
+
+// NewUser returns a user that is not stored yet.
+func NewUser(telegramID int64) *m.User {
+	now := time.Now()
+	return &m.User{
+		TelegramID: telegramID, UserName: "user", FirstName: "First", LastName: "Last", Active: true,
+		CreatedAt: now, UpdatedAt: now,
+	}
+}
+
+// NewUnit returns a unit of the kladovkin provider that is not stored yet.
+func NewUnit(externalID, city, storage, size string) m.Unit {
+	now := time.Now()
+	return m.Unit{
+		Provider:   "kladovkin",
+		ExternalID: externalID,
+		Name:       "S-" + externalID,
+		City:       city,
+		Storage:    storage,
+		Size:       size,
+		Dimension:  "1x2x2.5",
+		Price:      3500,
+		Available:  true,
+		URL:        "https://kladovkin.ru/units/" + externalID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+func testUsers(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	users := repos.Users
+
+	t.Run("should return nil for an unknown user", func(t *testing.T) {
+		user, err := users.GetUserByID(ctx, 404)
+		require.NoError(t, err)
+		assert.Nil(t, user)
+
+		user, err = users.GetByTelegramID(ctx, 404)
+		require.NoError(t, err)
+		assert.Nil(t, user)
+	})
+
+	first, second := NewUser(1001), NewUser(1002)
+	t.Run("should assign distinct IDs to new users", func(t *testing.T) {
+		require.NoError(t, users.CreateUser(ctx, first))
+		require.NoError(t, users.CreateUser(ctx, second))
+
+		assert.NotZero(t, first.ID)
+		assert.NotEqual(t, first.ID, second.ID)
+	})
+
+	t.Run("should find a user by Telegram ID", func(t *testing.T) {
+		user, err := users.GetByTelegramID(ctx, 1002)
+		require.NoError(t, err)
+		require.NotNil(t, user)
+		assert.Equal(t, second.ID, user.ID)
+		assert.Equal(t, "user", user.UserName)
+		assert.Equal(t, "First", user.FirstName)
+		assert.Equal(t, "Last", user.LastName)
+		assert.True(t, user.Active)
+	})
+
+	t.Run("should update a user saved with its ID", func(t *testing.T) {
+		first.FirstName = "Renamed"
+		require.NoError(t, users.CreateUser(ctx, first))
+
+		user, err := users.GetUserByID(ctx, first.ID)
+		require.NoError(t, err)
+		require.NotNil(t, user)
+		assert.Equal(t, "Renamed", user.FirstName)
+
+		all, err := users.GetAllUsers(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("should update a user", func(t *testing.T) {
+		notified := time.Now().Truncate(time.Second)
+		first.LastNotified = notified
+		first.Active = false
+		first.TimeZone = "Asia/Yekaterinburg"
+		first.QuietHoursStart, first.QuietHoursEnd = 23*60, 8*60
+		require.NoError(t, users.UpdateUser(ctx, first))
+
+		user, err := users.GetUserByID(ctx, first.ID)
+		require.NoError(t, err)
+		require.NotNil(t, user)
+		assert.True(t, notified.Equal(user.LastNotified), "last notified %s, want %s", user.LastNotified, notified)
+		assert.False(t, user.Active)
+		assert.Equal(t, "Asia/Yekaterinburg", user.TimeZone)
+		assert.Equal(t, 23*60, user.QuietHoursStart)
+		assert.Equal(t, 8*60, user.QuietHoursEnd)
+	})
+
+	t.Run("should delete a user", func(t *testing.T) {
+		require.NoError(t, users.DeleteUser(ctx, first))
+
+		user, err := users.GetUserByID(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Nil(t, user)
+	})
+}
+
+func testUnits(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	units := repos.Units
+
+	t.Run("should return nil for an unknown unit", func(t *testing.T) {
+		unit, err := units.GetUnitByID(ctx, 404)
+		require.NoError(t, err)
+		assert.Nil(t, unit)
+	})
+
+	unit := NewUnit("1", "Москва", "Ленинский", "2 м²")
+	t.Run("should store a unit", func(t *testing.T) {
+		require.NoError(t, units.CreateUnit(ctx, &unit))
+		require.NotZero(t, unit.ID)
+
+		stored, err := units.GetUnitByID(ctx, unit.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, unit.Provider, stored.Provider)
+		assert.Equal(t, unit.ExternalID, stored.ExternalID)
+		assert.Equal(t, unit.Name, stored.Name)
+		assert.Equal(t, unit.Dimension, stored.Dimension)
+		assert.Equal(t, unit.URL, stored.URL)
+		assert.InDelta(t, unit.Price, stored.Price, 0.001)
+		assert.True(t, stored.Available)
+	})
+
+	t.Run("should upsert by provider and external ID", func(t *testing.T) {
+		again := NewUnit("1", "Москва", "Ленинский", "2 м²")
+		again.Price = 3900
+		other := NewUnit("1", "Москва", "Ленинский", "2 м²")
+		other.Provider = "other"
+
+		require.NoError(t, units.CreateUnit(ctx, &again))
+		require.NoError(t, units.CreateUnit(ctx, &other))
+
+		assert.Equal(t, unit.ID, again.ID)
+		assert.NotEqual(t, unit.ID, other.ID)
+		stored, err := units.GetUnitByID(ctx, unit.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.InDelta(t, 3900, stored.Price, 0.001)
+		require.NoError(t, units.DeleteUnit(ctx, other.ID))
+	})
+
+	t.Run("should upsert units in bulk", func(t *testing.T) {
+		batch := []m.Unit{
+			NewUnit("1", "Москва", "Ленинский", "2 м²"),
+			NewUnit("2", "Москва", "Ленинский", "4 м²"),
+			NewUnit("3", "Москва", "Бутово", "2 м²"),
+			NewUnit("4", "Санкт-Петербург", "Обводный", "6 м²"),
+		}
+
+		require.NoError(t, units.UpsertUnits(ctx, batch))
+
+		assert.Equal(t, unit.ID, batch[0].ID)
+		for _, u := range batch {
+			assert.NotZero(t, u.ID)
+		}
+		all, err := units.GetAllUnits(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 4)
+	})
+
+	t.Run("should list the distinct cities, storages and sizes", func(t *testing.T) {
+		cities, err := units.GetCities(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Москва", "Санкт-Петербург"}, cities)
+
+		storages, err := units.GetStoragesByCity(ctx, "Москва")
+		require.NoError(t, err)
+		assert.Equal(t, []m.Storage{{Provider: "kladovkin", Name: "Бутово"}, {Provider: "kladovkin", Name: "Ленинский"}}, storages)
+
+		sizes, err := units.GetUnitSizesByStorage(ctx, m.Storage{Provider: "kladovkin", Name: "Ленинский"}, "Москва")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"2 м²", "4 м²"}, sizes)
+	})
+
+	t.Run("should scope the storages and sizes by provider and city", func(t *testing.T) {
+		other := NewUnit("5", "Москва", "Ленинский", "8 м²")
+		other.Provider = "other"
+		elsewhere := NewUnit("6", "Санкт-Петербург", "Ленинский", "10 м²")
+		require.NoError(t, units.UpsertUnits(ctx, []m.Unit{other, elsewhere}))
+
+		storages, err := units.GetStoragesByCity(ctx, "Москва")
+		require.NoError(t, err)
+		assert.Contains(t, storages, m.Storage{Provider: "other", Name: "Ленинский"})
+
+		sizes, err := units.GetUnitSizesByStorage(ctx, m.Storage{Provider: "kladovkin", Name: "Ленинский"}, "Москва")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"2 м²", "4 м²"}, sizes)
+
+		sizes, err = units.GetUnitSizesByStorage(ctx, m.Storage{Provider: "other", Name: "Ленинский"}, "Москва")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"8 м²"}, sizes)
+	})
+
+	t.Run("should update a unit", func(t *testing.T) {
+		unit.Available = false
+		require.NoError(t, units.UpdateUnit(ctx, &unit))
+
+		stored, err := units.GetUnitByID(ctx, unit.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.False(t, stored.Available)
+	})
+
+	t.Run("should delete a unit", func(t *testing.T) {
+		require.NoError(t, units.DeleteUnit(ctx, unit.ID))
+
+		stored, err := units.GetUnitByID(ctx, unit.ID)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+}
+
+func testSubscriptions(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	subscriptions := repos.Subscriptions
+
+	owner, other := NewUser(2001), NewUser(2002)
+	require.NoError(t, repos.Users.CreateUser(ctx, owner))
+	require.NoError(t, repos.Users.CreateUser(ctx, other))
+
+	t.Run("should return nil for an unknown subscription", func(t *testing.T) {
+		subscription, err := subscriptions.GetSubscriptionByID(ctx, 404)
+		require.NoError(t, err)
+		assert.Nil(t, subscription)
+	})
+
+	subscription := &m.Subscription{
+		UserID: owner.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+	}
+	t.Run("should store a subscription", func(t *testing.T) {
+		require.NoError(t, subscriptions.CreateSubscription(ctx, subscription))
+		require.NotZero(t, subscription.ID)
+
+		stored, err := subscriptions.GetSubscriptionByID(ctx, subscription.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, owner.ID, stored.UserID)
+		assert.Equal(t, "Москва", stored.City)
+		assert.Equal(t, "Ленинский", stored.Storage)
+		assert.Equal(t, "2 м²", stored.UnitSize)
+		assert.Equal(t, m.SubscriptionStatusActive, stored.Status)
+		assert.Empty(t, stored.Provider)
+	})
+
+	t.Run("should store the delivery mode", func(t *testing.T) {
+		subscription.DeliveryMode = m.DeliveryModeWeekly
+		subscription.DigestTime = 18 * 60
+		subscription.DigestWeekday = time.Friday
+		require.NoError(t, subscriptions.UpdateSubscription(ctx, subscription))
+
+		stored, err := subscriptions.GetSubscriptionByID(ctx, subscription.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, m.DeliveryModeWeekly, stored.DeliveryMode)
+		assert.Equal(t, 18*60, stored.DigestTime)
+		assert.Equal(t, time.Friday, stored.DigestWeekday)
+	})
+
+	t.Run("should store the price rules", func(t *testing.T) {
+		subscription.MaxPrice = 4500.5
+		subscription.MinPriceDrop = 10
+		require.NoError(t, subscriptions.UpdateSubscription(ctx, subscription))
+
+		stored, err := subscriptions.GetSubscriptionByID(ctx, subscription.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, 4500.5, stored.MaxPrice)
+		assert.Equal(t, 10, stored.MinPriceDrop)
+	})
+
+	t.Run("should list the subscriptions of a user", func(t *testing.T) {
+		require.NoError(t, subscriptions.CreateSubscription(ctx, &m.Subscription{
+			UserID: other.ID, Provider: "kladovkin", City: "Москва", Storage: "Бутово", UnitSize: "4 м²",
+			Status: m.SubscriptionStatusActive,
+		}))
+
+		owned, err := subscriptions.GetSubscriptionsByUserID(ctx, owner.ID)
+		require.NoError(t, err)
+		require.Len(t, owned, 1)
+		assert.Equal(t, subscription.ID, owned[0].ID)
+
+		all, err := subscriptions.GetAllSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("should list only the active subscriptions", func(t *testing.T) {
+		subscription.Status = m.SubscriptionStatusPaused
+		require.NoError(t, subscriptions.UpdateSubscription(ctx, subscription))
+
+		active, err := subscriptions.GetActiveSubscriptions(ctx)
+		require.NoError(t, err)
+		require.Len(t, active, 1)
+		assert.Equal(t, other.ID, active[0].UserID)
+		assert.Equal(t, "kladovkin", active[0].Provider)
+	})
+
+	t.Run("should delete a subscription", func(t *testing.T) {
+		require.NoError(t, subscriptions.DeleteSubscription(ctx, subscription.ID))
+
+		stored, err := subscriptions.GetSubscriptionByID(ctx, subscription.ID)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+}
+
+func testChatStates(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	states := repos.ChatStates
+
+	t.Run("should return nil for an unknown chat", func(t *testing.T) {
+		state, err := states.GetChatState(ctx, 42)
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+
+	t.Run("should save and update the state", func(t *testing.T) {
+		require.NoError(t, states.SaveChatState(ctx, &m.ChatState{ChatID: 42, Step: m.ChatStepStorage, City: "Москва"}))
+		require.NoError(t, states.SaveChatState(ctx, &m.ChatState{
+			ChatID: 42, Step: m.ChatStepUnitSize, City: "Москва", Storage: "Ленинский", SubscriptionID: 7,
+		}))
+
+		state, err := states.GetChatState(ctx, 42)
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		assert.Equal(t, m.ChatStepUnitSize, state.Step)
+		assert.Equal(t, "Ленинский", state.Storage)
+		assert.Equal(t, int64(7), state.SubscriptionID)
+		assert.False(t, state.UpdatedAt.IsZero())
+	})
+
+	t.Run("should delete the state", func(t *testing.T) {
+		require.NoError(t, states.DeleteChatState(ctx, 42))
+
+		state, err := states.GetChatState(ctx, 42)
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+}
+
+func testDeliveryChannels(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	channels := repos.DeliveryChannels
+
+	owner, other := NewUser(4001), NewUser(4002)
+	require.NoError(t, repos.Users.CreateUser(ctx, owner))
+	require.NoError(t, repos.Users.CreateUser(ctx, other))
+
+	t.Run("should return no channels for a user without any", func(t *testing.T) {
+		stored, err := channels.GetDeliveryChannels(ctx, owner.ID)
+		require.NoError(t, err)
+		assert.Empty(t, stored)
+	})
+
+	t.Run("should save and update the channels of a user", func(t *testing.T) {
+		require.NoError(t, channels.SaveDeliveryChannel(ctx, &m.DeliveryChannel{
+			UserID: owner.ID, Type: m.ChannelWebhook, Address: "https://example.com/hook", Enabled: true,
+		}))
+		require.NoError(t, channels.SaveDeliveryChannel(ctx, &m.DeliveryChannel{
+			UserID: owner.ID, Type: m.ChannelEmail, Address: "old@example.com", Enabled: true,
+		}))
+		require.NoError(t, channels.SaveDeliveryChannel(ctx, &m.DeliveryChannel{
+			UserID: owner.ID, Type: m.ChannelEmail, Address: "user@example.com", Enabled: false, VerificationCode: "ABC123",
+		}))
+		require.NoError(t, channels.SaveDeliveryChannel(ctx, &m.DeliveryChannel{
+			UserID: other.ID, Type: m.ChannelTelegram, Enabled: false,
+		}))
+
+		stored, err := channels.GetDeliveryChannels(ctx, owner.ID)
+		require.NoError(t, err)
+		require.Len(t, stored, 2)
+		assert.Equal(t, m.ChannelEmail, stored[0].Type)
+		assert.Equal(t, "user@example.com", stored[0].Address)
+		assert.False(t, stored[0].Enabled)
+		assert.Equal(t, "ABC123", stored[0].VerificationCode)
+		assert.False(t, stored[0].CreatedAt.IsZero())
+		assert.Equal(t, m.ChannelWebhook, stored[1].Type)
+		assert.True(t, stored[1].Enabled)
+		assert.True(t, stored[1].Confirmed())
+	})
+
+	t.Run("should delete a channel", func(t *testing.T) {
+		require.NoError(t, channels.DeleteDeliveryChannel(ctx, owner.ID, m.ChannelEmail))
+
+		stored, err := channels.GetDeliveryChannels(ctx, owner.ID)
+		require.NoError(t, err)
+		require.Len(t, stored, 1)
+		assert.Equal(t, m.ChannelWebhook, stored[0].Type)
+
+		stored, err = channels.GetDeliveryChannels(ctx, other.ID)
+		require.NoError(t, err)
+		assert.Len(t, stored, 1)
+	})
+}
+
+func testNotifications(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	notifications := repos.Notifications
+
+	user := NewUser(5001)
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	now := time.Now().Truncate(time.Second)
+	newNotification := func(key string, channel m.ChannelType, nextAttemptAt time.Time) *m.Notification {
+		return &m.Notification{
+			IdempotencyKey: key,
+			UserID:         user.ID,
+			SubscriptionID: 1,
+			UnitID:         2,
+			Channel:        channel,
+			Subject:        "Unit available",
+			Text:           "A unit is available",
+			Unit:           NewUnit("2", "Москва", "Ленинский", "2 м²"),
+			Status:         m.NotificationStatusPending,
+			NextAttemptAt:  nextAttemptAt,
+		}
+	}
+
+	t.Run("should return nil for an unknown notification", func(t *testing.T) {
+		notification, err := notifications.GetNotificationByID(ctx, 404)
+		require.NoError(t, err)
+		assert.Nil(t, notification)
+	})
+
+	first := newNotification("1:2:available", m.ChannelTelegram, now.Add(-time.Minute))
+	t.Run("should enqueue a notification once per key and channel", func(t *testing.T) {
+		enqueued, err := notifications.EnqueueNotification(ctx, first)
+		require.NoError(t, err)
+		assert.True(t, enqueued)
+		assert.NotZero(t, first.ID)
+
+		enqueued, err = notifications.EnqueueNotification(ctx, newNotification("1:2:available", m.ChannelTelegram, now))
+		require.NoError(t, err)
+		assert.False(t, enqueued)
+
+		email := newNotification("1:2:available", m.ChannelEmail, now)
+		email.Address = "user@example.com"
+		enqueued, err = notifications.EnqueueNotification(ctx, email)
+		require.NoError(t, err)
+		assert.True(t, enqueued)
+
+		stored, err := notifications.GetNotificationByID(ctx, email.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, "user@example.com", stored.Address)
+		assert.Equal(t, "Ленинский", stored.Unit.Storage)
+		assert.Equal(t, m.NotificationStatusPending, stored.Status)
+		assert.Nil(t, stored.SentAt)
+	})
+
+	t.Run("should return the due notifications, the oldest first", func(t *testing.T) {
+		_, err := notifications.EnqueueNotification(ctx, newNotification("1:3:available", m.ChannelTelegram, now.Add(time.Hour)))
+		require.NoError(t, err)
+
+		due, err := notifications.GetDueNotifications(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		assert.Equal(t, first.ID, due[0].ID)
+		assert.Equal(t, m.ChannelEmail, due[1].Channel)
+
+		due, err = notifications.GetDueNotifications(ctx, now, 1)
+		require.NoError(t, err)
+		assert.Len(t, due, 1)
+	})
+
+	t.Run("should update the delivery status", func(t *testing.T) {
+		sentAt := now
+		first.Status = m.NotificationStatusSent
+		first.Attempts = 2
+		first.LastError = "timeout"
+		first.SentAt = &sentAt
+		require.NoError(t, notifications.UpdateNotification(ctx, first))
+
+		stored, err := notifications.GetNotificationByID(ctx, first.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, m.NotificationStatusSent, stored.Status)
+		assert.Equal(t, 2, stored.Attempts)
+		assert.Equal(t, "timeout", stored.LastError)
+		require.NotNil(t, stored.SentAt)
+		assert.True(t, sentAt.Equal(*stored.SentAt), "sent at %s, want %s", stored.SentAt, sentAt)
+
+		due, err := notifications.GetDueNotifications(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, m.ChannelEmail, due[0].Channel)
+	})
+
+	t.Run("should not return failed notifications before their next attempt", func(t *testing.T) {
+		due, err := notifications.GetDueNotifications(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		email := due[0]
+		email.Status = m.NotificationStatusFailed
+		email.Attempts = 1
+		email.NextAttemptAt = now.Add(5 * time.Minute)
+		require.NoError(t, notifications.UpdateNotification(ctx, email))
+
+		due, err = notifications.GetDueNotifications(ctx, now, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+
+		due, err = notifications.GetDueNotifications(ctx, now.Add(5*time.Minute), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, email.ID, due[0].ID)
+	})
+
+	t.Run("should delete only the finished notifications updated before a time", func(t *testing.T) {
+		deleted, err := notifications.DeleteNotificationsBefore(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+
+		deleted, err = notifications.DeleteNotificationsBefore(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		stored, err := notifications.GetNotificationByID(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Nil(t, stored, "the sent notification is deleted")
+		due, err := notifications.GetDueNotifications(ctx, now.Add(time.Hour), 10)
+		require.NoError(t, err)
+		assert.Len(t, due, 2, "the failed and pending notifications are kept")
+	})
+
+	t.Run("should claim the due notifications once until the lease runs out", func(t *testing.T) {
+		at := now.Add(time.Hour)
+		leaseUntil := at.Add(10 * time.Minute)
+		claimed, err := notifications.ClaimDueNotifications(ctx, at, leaseUntil, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 2)
+		assert.Less(t, claimed[0].ID, claimed[1].ID)
+		assert.True(t, leaseUntil.Equal(claimed[0].NextAttemptAt), "leased until %s", claimed[0].NextAttemptAt)
+
+		again, err := notifications.ClaimDueNotifications(ctx, at, leaseUntil, 10)
+		require.NoError(t, err)
+		assert.Empty(t, again, "the claimed notifications are not claimed again")
+
+		expired, err := notifications.ClaimDueNotifications(ctx, leaseUntil, leaseUntil.Add(10*time.Minute), 1)
+		require.NoError(t, err)
+		assert.Len(t, expired, 1, "the notifications are due again once the lease runs out")
+	})
+}
+
+func testDigests(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	digests := repos.Digests
+
+	owner, other := NewUser(6001), NewUser(6002)
+	require.NoError(t, repos.Users.CreateUser(ctx, owner))
+	require.NoError(t, repos.Users.CreateUser(ctx, other))
+	newSubscription := func(user *m.User) *m.Subscription {
+		subscription := &m.Subscription{
+			UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+			DeliveryMode: m.DeliveryModeDaily, DigestTime: 9 * 60,
+		}
+		require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, subscription))
+		return subscription
+	}
+	ownerSubscription, otherSubscription := newSubscription(owner), newSubscription(other)
+
+	now := time.Now().Truncate(time.Second)
+	newItem := func(subscription *m.Subscription, unitID int64, dueAt time.Time) *m.DigestItem {
+		unit := NewUnit(fmt.Sprint(unitID), "Москва", "Ленинский", "2 м²")
+		unit.ID = unitID
+		return &m.DigestItem{UserID: subscription.UserID, SubscriptionID: subscription.ID, Unit: unit, DueAt: dueAt}
+	}
+
+	t.Run("should return no items when none are due", func(t *testing.T) {
+		due, err := digests.GetDueDigestItems(ctx, now)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+	})
+
+	t.Run("should return the due items by user", func(t *testing.T) {
+		require.NoError(t, digests.AddDigestItem(ctx, newItem(otherSubscription, 1, now)))
+		first := newItem(ownerSubscription, 1, now.Add(-time.Hour))
+		require.NoError(t, digests.AddDigestItem(ctx, first))
+		assert.NotZero(t, first.ID)
+		require.NoError(t, digests.AddDigestItem(ctx, newItem(ownerSubscription, 2, now)))
+		require.NoError(t, digests.AddDigestItem(ctx, newItem(ownerSubscription, 3, now.Add(time.Hour))))
+
+		due, err := digests.GetDueDigestItems(ctx, now)
+		require.NoError(t, err)
+		require.Len(t, due, 3)
+		assert.Equal(t, first.ID, due[0].ID)
+		assert.Equal(t, int64(2), due[1].Unit.ID)
+		assert.Equal(t, other.ID, due[2].UserID)
+		assert.True(t, now.Equal(due[1].DueAt), "due at %s, want %s", due[1].DueAt, now)
+		assert.Equal(t, "Ленинский", due[1].Unit.Storage)
+	})
+
+	t.Run("should replace the unit and previous price of an item added again", func(t *testing.T) {
+		item := newItem(ownerSubscription, 2, now)
+		item.Unit.Price = 1500
+		item.PreviousPrice = 2000
+		require.NoError(t, digests.AddDigestItem(ctx, item))
+
+		due, err := digests.GetDueDigestItems(ctx, now)
+		require.NoError(t, err)
+		require.Len(t, due, 3)
+		assert.Equal(t, item.ID, due[1].ID)
+		assert.Equal(t, 1500.0, due[1].Unit.Price)
+		assert.Equal(t, 2000.0, due[1].PreviousPrice)
+	})
+
+	t.Run("should delete the items", func(t *testing.T) {
+		due, err := digests.GetDueDigestItems(ctx, now)
+		require.NoError(t, err)
+		require.NoError(t, digests.DeleteDigestItems(ctx, []int64{due[0].ID, due[1].ID}))
+		require.NoError(t, digests.DeleteDigestItems(ctx, nil))
+
+		due, err = digests.GetDueDigestItems(ctx, now.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		assert.Equal(t, int64(3), due[0].Unit.ID)
+		assert.Equal(t, other.ID, due[1].UserID)
+	})
+
+	t.Run("should delete the items of a deleted subscription", func(t *testing.T) {
+		require.NoError(t, repos.Subscriptions.DeleteSubscription(ctx, otherSubscription.ID))
+
+		due, err := digests.GetDueDigestItems(ctx, now.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, ownerSubscription.ID, due[0].SubscriptionID)
+	})
+}
+
+func testUnitAlerts(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	alerts := repos.UnitAlerts
+
+	user := NewUser(7001)
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	newSubscription := func() *m.Subscription {
+		subscription := &m.Subscription{
+			UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+		}
+		require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, subscription))
+		return subscription
+	}
+	first, second := newSubscription(), newSubscription()
+	now := time.Now()
+
+	t.Run("should claim an alert once per subscription and unit", func(t *testing.T) {
+		claimed, err := alerts.ClaimUnitAlert(ctx, first.ID, 1, now)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+
+		claimed, err = alerts.ClaimUnitAlert(ctx, first.ID, 1, now)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+
+		claimed, err = alerts.ClaimUnitAlert(ctx, second.ID, 1, now)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+
+		claimed, err = alerts.ClaimUnitAlert(ctx, first.ID, 2, now)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("should clear the alerts of a unit", func(t *testing.T) {
+		require.NoError(t, alerts.ClearUnitAlerts(ctx, 1))
+
+		claimed, err := alerts.ClaimUnitAlert(ctx, first.ID, 1, now)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+
+		claimed, err = alerts.ClaimUnitAlert(ctx, first.ID, 2, now)
+		require.NoError(t, err)
+		assert.False(t, claimed, "the alerts of other units are kept")
+	})
+}
+
+func testUnitEvents(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	events := repos.UnitEvents
+
+	detectedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	previous := NewUnit("1", "Москва", "Ленинский", "2 м²")
+	current := previous
+	current.Price = 4500
+	stored := []m.UnitEvent{
+		{Type: m.UnitEventNew, Unit: NewUnit("2", "Москва", "Ленинский", "4 м²"), DetectedAt: detectedAt},
+		{Type: m.UnitEventPriceChanged, Unit: current, Previous: &previous, DetectedAt: detectedAt},
+		{Type: m.UnitEventRemoved, Unit: NewUnit("3", "Москва", "Ленинский", "6 м²"), DetectedAt: detectedAt},
+	}
+
+	t.Run("should store events and assign their IDs", func(t *testing.T) {
+		require.NoError(t, events.AddUnitEvents(ctx, stored))
+
+		for _, event := range stored {
+			assert.NotZero(t, event.ID)
+		}
+		assert.Less(t, stored[0].ID, stored[1].ID)
+		assert.Less(t, stored[1].ID, stored[2].ID)
+	})
+
+	t.Run("should return the events after an ID, the oldest first", func(t *testing.T) {
+		all, err := events.GetUnitEvents(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+		assert.Equal(t, stored[0].ID, all[0].ID)
+		assert.Equal(t, m.UnitEventPriceChanged, all[1].Type)
+		assert.Equal(t, current.Price, all[1].Unit.Price)
+		require.NotNil(t, all[1].Previous)
+		assert.Equal(t, previous.Price, all[1].Previous.Price)
+		assert.True(t, detectedAt.Equal(all[1].DetectedAt))
+
+		page, err := events.GetUnitEvents(ctx, stored[0].ID, 1)
+		require.NoError(t, err)
+		require.Len(t, page, 1)
+		assert.Equal(t, stored[1].ID, page[0].ID)
+	})
+
+	t.Run("should delete handled events", func(t *testing.T) {
+		require.NoError(t, events.DeleteUnitEvents(ctx, []int64{stored[0].ID, stored[2].ID}))
+
+		remaining, err := events.GetUnitEvents(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, stored[1].ID, remaining[0].ID)
+	})
+}
+
+func testJobStates(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, _ := newBackend(t)
+	jobs := repos.JobStates
+
+	now := time.Now().Truncate(time.Second)
+
+	t.Run("should return nil for an unknown job", func(t *testing.T) {
+		state, err := jobs.GetJobState(ctx, "unknown")
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+
+	t.Run("should save a job that has not run yet", func(t *testing.T) {
+		require.NoError(t, jobs.SaveJobState(ctx, &m.JobState{Name: "parse", NextRunAt: now}))
+
+		state, err := jobs.GetJobState(ctx, "parse")
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		assert.Nil(t, state.LastRunAt)
+		assert.True(t, now.Equal(state.NextRunAt), "next run at %s, want %s", state.NextRunAt, now)
+	})
+
+	t.Run("should replace the state of a job", func(t *testing.T) {
+		lastRunAt := now
+		require.NoError(t, jobs.SaveJobState(ctx, &m.JobState{
+			Name: "parse", LastRunAt: &lastRunAt, LastDuration: 1500 * time.Millisecond, LastError: "timeout",
+			NextRunAt: now.Add(30 * time.Minute),
+		}))
+
+		state, err := jobs.GetJobState(ctx, "parse")
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		require.NotNil(t, state.LastRunAt)
+		assert.True(t, lastRunAt.Equal(*state.LastRunAt))
+		assert.Equal(t, 1500*time.Millisecond, state.LastDuration)
+		assert.Equal(t, "timeout", state.LastError)
+		assert.True(t, now.Add(30*time.Minute).Equal(state.NextRunAt))
+	})
+
+	t.Run("should list the jobs by name", func(t *testing.T) {
+		require.NoError(t, jobs.SaveJobState(ctx, &m.JobState{Name: "backup", NextRunAt: now}))
+
+		states, err := jobs.GetJobStates(ctx)
+		require.NoError(t, err)
+		require.Len(t, states, 2)
+		assert.Equal(t, "backup", states[0].Name)
+		assert.Equal(t, "parse", states[1].Name)
+	})
+}
+
+func testTransactor(t *testing.T, newBackend Backend) {
+	ctx := context.Background()
+	repos, transactor := newBackend(t)
+	errAbort := errors.New("abort")
+
+	t.Run("should roll back when the unit of work fails", func(t *testing.T) {
+		err := transactor.WithTx(ctx, func(ctx context.Context, tx repository.Repositories) error {
+			require.NoError(t, tx.Units.UpsertUnits(ctx, []m.Unit{NewUnit("1", "Москва", "Ленинский", "2 м²")}))
+			require.NoError(t, tx.Users.CreateUser(ctx, NewUser(3001)))
+			return errAbort
+		})
+
+		assert.ErrorIs(t, err, errAbort)
+		units, err := repos.Units.GetAllUnits(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, units)
+		user, err := repos.Users.GetByTelegramID(ctx, 3001)
+		require.NoError(t, err)
+		assert.Nil(t, user)
+	})
+
+	t.Run("should commit when the unit of work succeeds", func(t *testing.T) {
+		err := transactor.WithTx(ctx, func(ctx context.Context, tx repository.Repositories) error {
+			if err := tx.Units.UpsertUnits(ctx, []m.Unit{NewUnit("1", "Москва", "Ленинский", "2 м²")}); err != nil {
+				return err
+			}
+
+			// The unit of work sees its own changes
+			units, err := tx.Units.GetAllUnits(ctx)
+			if err != nil {
+				return err
+			}
+			assert.Len(t, units, 1)
+			return nil
+		})
+
+		require.NoError(t, err)
+		units, err := repos.Units.GetAllUnits(ctx)
+		require.NoError(t, err)
+		assert.Len(t, units, 1)
+	})
+}
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.ChatStateRepository = (*SQLiteChatStateRepository)(nil)
+
+// SQLiteChatStateRepository implements the ChatStateRepository interface using SQLite.
+type SQLiteChatStateRepository struct {
+	db queryer
+}
+
+// NewSQLiteChatStateRepository creates a new instance of SQLiteChatStateRepository.
+func NewSQLiteChatStateRepository(db *sql.DB) *SQLiteChatStateRepository {
+	return &SQLiteChatStateRepository{db: db}
+}
+
+// GetChatState retrieves the state of a chat from the database.
+func (r *SQLiteChatStateRepository) GetChatState(ctx context.Context, chatID int64) (*m.ChatState, error) {
+	query := `SELECT chat_id, step, city, provider, storage, unit_size, subscription_id, updated_at FROM chat_states WHERE chat_id = ?`
+	row := r.db.QueryRowContext(ctx, query, chatID)
+
+	var state m.ChatState
+	err := row.Scan(&state.ChatID, &state.Step, &state.City, &state.Provider, &state.Storage, &state.UnitSize, &state.SubscriptionID, &state.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get chat state: %w", err)
+	}
+	return &state, nil
+}
+
+// SaveChatState inserts or updates the state of a chat in the database.
+func (r *SQLiteChatStateRepository) SaveChatState(ctx context.Context, state *m.ChatState) error {
+	query := `
+		INSERT INTO chat_states (chat_id, step, city, provider, storage, unit_size, subscription_id, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET
+			step = excluded.step,
+			city = excluded.city,
+			provider = excluded.provider,
+			storage = excluded.storage,
+			unit_size = excluded.unit_size,
+			subscription_id = excluded.subscription_id,
+			updated_at = excluded.updated_at
+	`
+	state.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, state.ChatID, state.Step, state.City, state.Provider, state.Storage, state.UnitSize, state.SubscriptionID, state.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save chat state: %w", err)
+	}
+	return nil
+}
+
+// DeleteChatState deletes the state of a chat from the database.
+func (r *SQLiteChatStateRepository) DeleteChatState(ctx context.Context, chatID int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM chat_states WHERE chat_id = ?", chatID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.DeliveryChannelRepository = (*SQLiteDeliveryChannelRepository)(nil)
+
+// SQLiteDeliveryChannelRepository implements the DeliveryChannelRepository interface using SQLite.
+type SQLiteDeliveryChannelRepository struct {
+	db queryer
+}
+
+// NewSQLiteDeliveryChannelRepository creates a new instance of SQLiteDeliveryChannelRepository.
+func NewSQLiteDeliveryChannelRepository(db *sql.DB) *SQLiteDeliveryChannelRepository {
+	return &SQLiteDeliveryChannelRepository{db: db}
+}
+
+// GetDeliveryChannels retrieves the delivery channels of a user from the database.
+func (r *SQLiteDeliveryChannelRepository) GetDeliveryChannels(ctx context.Context, userID int64) ([]*m.DeliveryChannel, error) {
+	query := `
+		SELECT user_id, type, address, enabled, verification_code, created_at, updated_at
+		FROM delivery_channels
+		WHERE user_id = ?
+		ORDER BY type
+	`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []*m.DeliveryChannel
+	for rows.Next() {
+		var channel m.DeliveryChannel
+		err := rows.Scan(&channel.UserID, &channel.Type, &channel.Address, &channel.Enabled, &channel.VerificationCode, &channel.CreatedAt, &channel.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan delivery channel row: %w", err)
+		}
+		channels = append(channels, &channel)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during delivery channels rows iteration: %w", err)
+	}
+	return channels, nil
+}
+
+// SaveDeliveryChannel inserts or updates a delivery channel of a user in the database.
+func (r *SQLiteDeliveryChannelRepository) SaveDeliveryChannel(ctx context.Context, channel *m.DeliveryChannel) error {
+	query := `
+		INSERT INTO delivery_channels (user_id, type, address, enabled, verification_code, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, type) DO UPDATE SET
+			address = excluded.address,
+			enabled = excluded.enabled,
+			verification_code = excluded.verification_code,
+			updated_at = excluded.updated_at
+	`
+	channel.UpdatedAt = time.Now()
+	if channel.CreatedAt.IsZero() {
+		channel.CreatedAt = channel.UpdatedAt
+	}
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		channel.UserID,
+		channel.Type,
+		channel.Address,
+		channel.Enabled,
+		channel.VerificationCode,
+		channel.CreatedAt,
+		channel.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save delivery channel: %w", err)
+	}
+	return nil
+}
+
+// DeleteDeliveryChannel deletes a delivery channel of a user from the database.
+func (r *SQLiteDeliveryChannelRepository) DeleteDeliveryChannel(ctx context.Context, userID int64, channelType m.ChannelType) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM delivery_channels WHERE user_id = ? AND type = ?", userID, channelType)
+	if err != nil {
+		return fmt.Errorf("failed to delete delivery channel: %w", err)
+	}
+	return nil
+}
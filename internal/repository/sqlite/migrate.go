@@ -0,0 +1,138 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]repository.Migration, error) {
+	return repository.LoadMigrations(migrationFiles, "migrations")
+}
+
+// Migrate applies the pending embedded migrations to the database.
+// Each migration runs in its own transaction together with its record in the schema_migrations table,
+// so a failing migration leaves the database at the previous version.
+func Migrate(db *sql.DB) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	return applyMigrations(db, migrations)
+}
+
+// applyMigrations applies the migrations that are not recorded in the schema_migrations table yet.
+func applyMigrations(db *sql.DB, migrations []repository.Migration) error {
+	if err := ensureMigrationsTable(db); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if _, done := applied[migration.Version]; done {
+			continue
+		}
+		if err := applyMigration(db, migration); err != nil {
+			return err
+		}
+		slog.Info("Applied database migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration and records it.
+func applyMigration(db *sql.DB, migration repository.Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", migration.Version, err)
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op
+
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// MigrationStatuses returns all embedded migrations together with the time they were applied, if they were.
+func MigrationStatuses(db *sql.DB) ([]repository.MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.MigrationStatuses(migrations, applied), nil
+}
+
+// ensureMigrationsTable creates the table recording the applied migrations.
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied migration versions with the time they were applied.
+func appliedMigrations(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during applied migrations iteration: %w", err)
+	}
+	return applied, nil
+}
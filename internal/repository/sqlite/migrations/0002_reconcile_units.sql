@@ -0,0 +1,25 @@
+-- Reconcile the units table with models.Unit.
+-- The initial table lacks the dimension column, so no unit could ever be stored in it.
+-- Units are scraped from the providers, the table is recreated and refilled by the next parser run.
+
+DROP TABLE units;
+
+CREATE TABLE units (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	provider TEXT NOT NULL,
+	external_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	city TEXT NOT NULL,
+	storage TEXT NOT NULL,
+	size TEXT NOT NULL,
+	dimension TEXT NOT NULL DEFAULT '',
+	price REAL NOT NULL,
+	available BOOLEAN NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	url TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	UNIQUE (provider, external_id)
+);
+
+CREATE INDEX idx_units_city_storage_size ON units (city, storage, size);
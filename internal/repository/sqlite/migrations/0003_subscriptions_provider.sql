@@ -0,0 +1,3 @@
+-- Subscriptions can be limited to the units of one provider, an empty provider matches any.
+
+ALTER TABLE subscriptions ADD COLUMN provider TEXT NOT NULL DEFAULT '';
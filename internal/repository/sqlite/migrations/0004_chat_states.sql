@@ -0,0 +1,11 @@
+-- The progress of the chats through the subscription wizard.
+
+CREATE TABLE chat_states (
+	chat_id INTEGER PRIMARY KEY,
+	step TEXT NOT NULL,
+	city TEXT NOT NULL DEFAULT '',
+	storage TEXT NOT NULL DEFAULT '',
+	unit_size TEXT NOT NULL DEFAULT '',
+	subscription_id INTEGER NOT NULL DEFAULT 0,
+	updated_at DATETIME NOT NULL
+);
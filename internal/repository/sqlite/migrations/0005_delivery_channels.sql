@@ -0,0 +1,12 @@
+-- The channels the users receive the notifications through, in addition to or instead of Telegram.
+
+CREATE TABLE delivery_channels (
+	user_id INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	address TEXT NOT NULL DEFAULT '',
+	enabled BOOLEAN NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	PRIMARY KEY (user_id, type),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
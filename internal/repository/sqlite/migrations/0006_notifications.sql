@@ -0,0 +1,24 @@
+-- The outbox of the notifications, one row per alert and delivery channel.
+
+CREATE TABLE notifications (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	idempotency_key TEXT NOT NULL,
+	user_id INTEGER NOT NULL,
+	subscription_id INTEGER NOT NULL,
+	unit_id INTEGER NOT NULL,
+	channel TEXT NOT NULL,
+	address TEXT NOT NULL DEFAULT '',
+	subject TEXT NOT NULL DEFAULT '',
+	text TEXT NOT NULL,
+	unit TEXT NOT NULL, -- JSON snapshot of the unit
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at DATETIME NOT NULL,
+	sent_at DATETIME,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	UNIQUE (idempotency_key, channel)
+);
+
+CREATE INDEX idx_notifications_status_next_attempt_at ON notifications (status, next_attempt_at);
@@ -0,0 +1,3 @@
+-- Users who blocked the bot or deleted their account are inactive until they send /start again.
+
+ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT 1;
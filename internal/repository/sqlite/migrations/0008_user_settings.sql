@@ -0,0 +1,5 @@
+-- The time zone and the quiet hours of the users, the quiet hours are minutes after midnight.
+
+ALTER TABLE users ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN quiet_hours_start INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN quiet_hours_end INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,16 @@
+-- The units collected for the digests of the users, until the digests are sent.
+
+CREATE TABLE digest_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	subscription_id INTEGER NOT NULL,
+	unit_id INTEGER NOT NULL,
+	unit TEXT NOT NULL, -- JSON snapshot of the unit
+	due_at DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	UNIQUE (subscription_id, unit_id, due_at),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_digest_items_due_at ON digest_items (due_at);
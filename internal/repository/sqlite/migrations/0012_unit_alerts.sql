@@ -0,0 +1,12 @@
+-- The units announced to the subscriptions. A unit is announced to a subscription once,
+-- its rows are deleted when it is observed unavailable, so it is announced again when it comes back.
+
+CREATE TABLE unit_alerts (
+	subscription_id INTEGER NOT NULL,
+	unit_id INTEGER NOT NULL,
+	announced_at DATETIME NOT NULL,
+	PRIMARY KEY (subscription_id, unit_id),
+	FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_unit_alerts_unit_id ON unit_alerts (unit_id);
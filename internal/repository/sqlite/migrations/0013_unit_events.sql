@@ -0,0 +1,8 @@
+-- The unit events detected by the parser, stored together with the units and deleted once the notifier
+-- handled them, so no event is lost when the process stops in between.
+
+CREATE TABLE unit_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event TEXT NOT NULL, -- JSON encoded event
+	created_at DATETIME NOT NULL
+);
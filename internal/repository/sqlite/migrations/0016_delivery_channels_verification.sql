@@ -0,0 +1,6 @@
+-- Email addresses are confirmed with a code before notifications are sent to them.
+-- The addresses set up before are not confirmed, their users have to set them up again.
+
+ALTER TABLE delivery_channels ADD COLUMN verification_code TEXT NOT NULL DEFAULT '';
+
+UPDATE delivery_channels SET verification_code = upper(hex(randomblob(5))) WHERE type = 'email';
@@ -0,0 +1,4 @@
+-- The cleanup of the outbox deletes the old notifications by their update time.
+-- The databases migrated before the index got its own migration already have it.
+
+CREATE INDEX IF NOT EXISTS idx_notifications_updated_at ON notifications (updated_at);
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
@@ -8,55 +9,6 @@ import (
 	"time"
 )
 
-// InitializeDatabase sets up the SQLite database with the required tables.
-func InitializeDatabase(db *sql.DB) error {
-	createTablesSQL := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		telegram_id INTEGER NOT NULL,
-		username TEXT,
-		first_name TEXT,
-		last_name TEXT,
-		last_notified DATETIME,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS units (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		city TEXT NOT NULL,
-		storage TEXT NOT NULL,
-		size TEXT NOT NULL,
-		price REAL NOT NULL,
-		available BOOLEAN NOT NULL,
-		description TEXT,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS subscriptions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		city TEXT NOT NULL,
-		storage TEXT NOT NULL,
-		unit_size TEXT NOT NULL,
-		status TEXT NOT NULL,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL,
-		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);
-	`
-
-	_, err := db.Exec(createTablesSQL)
-	if err != nil {
-		return fmt.Errorf("failed to create tables: %v", err)
-	}
-
-	log.Println("Database tables created or already exist.")
-	return nil
-}
-
 // NewSQLiteDB initializes a new SQLite database connection.
 func NewSQLiteDB(dbFilePath string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dbFilePath)
@@ -76,6 +28,14 @@ func NewSQLiteDB(dbFilePath string) (*sql.DB, error) {
 	return db, nil
 }
 
+// Backup writes a consistent copy of the database into a new file at path, the database stays usable meanwhile.
+func Backup(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up SQLite database: %w", err)
+	}
+	return nil
+}
+
 // CurrentTimestamp returns the current time formatted as a string for use in SQLite.
 func CurrentTimestamp() string {
 	return time.Now().Format("2006-01-02 15:04:05")
@@ -1 +1,247 @@
 package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/repositorytest"
+)
+
+// newTestDB creates a migrated SQLite database in a temporary directory.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, Migrate(db))
+	return db
+}
+
+func TestMigrate(t *testing.T) {
+	t.Run("should be idempotent", func(t *testing.T) {
+		// Arrange
+		db := newTestDB(t)
+
+		// Act
+		err := Migrate(db)
+
+		// Assert
+		require.NoError(t, err)
+		statuses, err := MigrationStatuses(db)
+		require.NoError(t, err)
+		require.NotEmpty(t, statuses)
+		for _, status := range statuses {
+			assert.NotNil(t, status.AppliedAt, "migration %d", status.Version)
+		}
+	})
+
+	t.Run("should upgrade a database created before the migrations", func(t *testing.T) {
+		// Arrange
+		ctx := context.Background()
+		db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "legacy.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		migrations, err := Migrations()
+		require.NoError(t, err)
+		_, err = db.Exec(migrations[0].SQL)
+		require.NoError(t, err)
+		_, err = db.Exec(
+			"INSERT INTO subscriptions (user_id, city, storage, unit_size, status, created_at, updated_at) "+
+				"VALUES (1, 'Москва', 'Кладовкин на Ленинском', '2 м²', 'active', ?, ?)",
+			time.Now(), time.Now(),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = Migrate(db)
+
+		// Assert
+		require.NoError(t, err)
+		subscriptions, err := NewSQLiteSubscriptionRepository(db).GetActiveSubscriptions(ctx)
+		require.NoError(t, err)
+		require.Len(t, subscriptions, 1)
+		assert.Equal(t, "Москва", subscriptions[0].City)
+		assert.Empty(t, subscriptions[0].Provider)
+	})
+}
+
+func TestSQLiteUnitRepository(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewSQLiteUnitRepository(newTestDB(t))
+	unit := &m.Unit{
+		Provider: "kladovkin", ExternalID: "101", Name: "S-1", City: "Москва", Storage: "Кладовкин на Ленинском",
+		Size: "2 м²", Dimension: "1x2x2.5", Price: 3500, Available: true,
+		CreatedAt: time.Now(), UpdatedAt: time.Now(),
+	}
+
+	// Act
+	require.NoError(t, repo.CreateUnit(ctx, unit))
+
+	// Assert
+	require.NotZero(t, unit.ID)
+	stored, err := repo.GetUnitByID(ctx, unit.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, unit.Dimension, stored.Dimension)
+
+	t.Run("should upsert by provider and external ID", func(t *testing.T) {
+		again := *unit
+		again.ID = 0
+		again.Price = 3900
+
+		require.NoError(t, repo.CreateUnit(ctx, &again))
+
+		assert.Equal(t, unit.ID, again.ID)
+		units, err := repo.GetAllUnits(ctx)
+		require.NoError(t, err)
+		require.Len(t, units, 1)
+		assert.InDelta(t, 3900, units[0].Price, 0.001)
+	})
+
+	t.Run("should list the unit sizes of a storage", func(t *testing.T) {
+		sizes, err := repo.GetUnitSizesByStorage(ctx, m.Storage{Provider: "kladovkin", Name: "Кладовкин на Ленинском"}, "Москва")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"2 м²"}, sizes)
+	})
+}
+
+func TestSQLiteUnitRepositoryUpsertUnits(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewSQLiteUnitRepository(newTestDB(t))
+	newUnit := func(externalID string) m.Unit {
+		return m.Unit{
+			Provider: "kladovkin", ExternalID: externalID, Name: "S-" + externalID, City: "Москва",
+			Storage: "Кладовкин на Ленинском", Size: "2 м²", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		}
+	}
+
+	t.Run("should save all units and write back their IDs", func(t *testing.T) {
+		// Arrange
+		units := []m.Unit{newUnit("1"), newUnit("2")}
+
+		// Act
+		err := repo.UpsertUnits(ctx, units)
+
+		// Assert
+		require.NoError(t, err)
+		assert.NotZero(t, units[0].ID)
+		assert.NotZero(t, units[1].ID)
+		stored, err := repo.GetAllUnits(ctx)
+		require.NoError(t, err)
+		assert.Len(t, stored, 2)
+	})
+
+	t.Run("should save nothing on a cancelled context", func(t *testing.T) {
+		// Arrange
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		// Act
+		err := repo.UpsertUnits(cancelled, []m.Unit{newUnit("3")})
+
+		// Assert
+		require.Error(t, err)
+		stored, err := repo.GetAllUnits(ctx)
+		require.NoError(t, err)
+		assert.Len(t, stored, 2)
+	})
+}
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) (repository.Repositories, repository.Transactor) {
+		db := newTestDB(t)
+		return NewRepositories(db), NewSQLiteTransactor(db)
+	})
+}
+
+func TestSQLiteChatStateRepository(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewSQLiteChatStateRepository(newTestDB(t))
+
+	t.Run("should return nil for an unknown chat", func(t *testing.T) {
+		state, err := repo.GetChatState(ctx, 42)
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+
+	t.Run("should save and update the state", func(t *testing.T) {
+		// Act
+		require.NoError(t, repo.SaveChatState(ctx, &m.ChatState{ChatID: 42, Step: m.ChatStepStorage, City: "Москва"}))
+		require.NoError(t, repo.SaveChatState(ctx, &m.ChatState{ChatID: 42, Step: m.ChatStepUnitSize, City: "Москва", Storage: "Кладовкин на Ленинском"}))
+		state, err := repo.GetChatState(ctx, 42)
+
+		// Assert
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		assert.Equal(t, m.ChatStepUnitSize, state.Step)
+		assert.Equal(t, "Москва", state.City)
+		assert.Equal(t, "Кладовкин на Ленинском", state.Storage)
+		assert.False(t, state.UpdatedAt.IsZero())
+	})
+
+	t.Run("should delete the state", func(t *testing.T) {
+		// Act
+		require.NoError(t, repo.DeleteChatState(ctx, 42))
+		state, err := repo.GetChatState(ctx, 42)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+}
+
+func TestSQLiteSubscriptionRepository(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := NewSQLiteSubscriptionRepository(newTestDB(t))
+	subscription := &m.Subscription{UserID: 1, City: "Москва", Storage: "Кладовкин на Ленинском", UnitSize: "2 м²", Status: m.SubscriptionStatusActive}
+
+	// Act
+	require.NoError(t, repo.CreateSubscription(ctx, subscription))
+
+	// Assert
+	require.NotZero(t, subscription.ID)
+	stored, err := repo.GetSubscriptionByID(ctx, subscription.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, subscription.City, stored.City)
+
+	t.Run("should pause the subscription", func(t *testing.T) {
+		stored.Status = m.SubscriptionStatusPaused
+		require.NoError(t, repo.UpdateSubscription(ctx, stored))
+
+		active, err := repo.GetActiveSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, active)
+	})
+
+	t.Run("should stop on a cancelled context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := repo.GetAllSubscriptions(cancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("should delete the subscription", func(t *testing.T) {
+		require.NoError(t, repo.DeleteSubscription(ctx, subscription.ID))
+
+		deleted, err := repo.GetSubscriptionByID(ctx, subscription.ID)
+		require.NoError(t, err)
+		assert.Nil(t, deleted)
+	})
+}
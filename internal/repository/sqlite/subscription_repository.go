@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -11,9 +12,13 @@ import (
 
 var _ repository.SubscriptionRepository = (*SQLiteSubscriptionRepository)(nil)
 
+// subscriptionColumns are the columns scanned by scanSubscription.
+const subscriptionColumns = `id, user_id, provider, city, storage, unit_size, status,
+	delivery_mode, digest_time, digest_weekday, max_price, min_price_drop, created_at, updated_at`
+
 // SQLiteSubscriptionRepository implements the SubscriptionRepository interface using SQLite.
 type SQLiteSubscriptionRepository struct {
-	db *sql.DB
+	db queryer
 }
 
 // NewSQLiteSubscriptionRepository creates a new instance of SQLiteSubscriptionRepository.
@@ -22,28 +27,46 @@ func NewSQLiteSubscriptionRepository(db *sql.DB) *SQLiteSubscriptionRepository {
 }
 
 // CreateSubscription inserts or updates a subscription in the database.
-func (r *SQLiteSubscriptionRepository) CreateSubscription(subscription *m.Subscription) error {
+// The ID of the stored subscription is written back into subscription.
+func (r *SQLiteSubscriptionRepository) CreateSubscription(ctx context.Context, subscription *m.Subscription) error {
 	query := `
-        INSERT INTO subscriptions (user_id, city, storage, unit_size, status, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?)
+        INSERT INTO subscriptions (
+            user_id, provider, city, storage, unit_size, status, delivery_mode, digest_time, digest_weekday,
+            max_price, min_price_drop, created_at, updated_at
+        )
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
         ON CONFLICT(id) DO UPDATE SET
             user_id = excluded.user_id,
+            provider = excluded.provider,
             city = excluded.city,
             storage = excluded.storage,
             unit_size = excluded.unit_size,
             status = excluded.status,
+            delivery_mode = excluded.delivery_mode,
+            digest_time = excluded.digest_time,
+            digest_weekday = excluded.digest_weekday,
+            max_price = excluded.max_price,
+            min_price_drop = excluded.min_price_drop,
             updated_at = excluded.updated_at
+        RETURNING id
     `
-	_, err := r.db.Exec(
+	err := r.db.QueryRowContext(
+		ctx,
 		query,
 		subscription.UserID,
+		subscription.Provider,
 		subscription.City,
 		subscription.Storage,
 		subscription.UnitSize,
 		subscription.Status,
+		subscription.DeliveryMode,
+		subscription.DigestTime,
+		subscription.DigestWeekday,
+		subscription.MaxPrice,
+		subscription.MinPriceDrop,
 		time.Now(),
 		time.Now(),
-	)
+	).Scan(&subscription.ID)
 	if err != nil {
 		return fmt.Errorf("failed to save subscription: %w", err)
 	}
@@ -51,34 +74,22 @@ func (r *SQLiteSubscriptionRepository) CreateSubscription(subscription *m.Subscr
 }
 
 // GetSubscriptionByID retrieves a subscription by ID from the database.
-func (r *SQLiteSubscriptionRepository) GetSubscriptionByID(id int64) (*m.Subscription, error) {
-	query := `SELECT id, user_id, city, storage, unit_size, status, created_at, updated_at FROM subscriptions WHERE id = ?`
-	row := r.db.QueryRow(query, id)
-
-	var subscription m.Subscription
-	err := row.Scan(
-		&subscription.ID,
-		&subscription.UserID,
-		&subscription.City,
-		&subscription.Storage,
-		&subscription.UnitSize,
-		&subscription.Status,
-		&subscription.CreatedAt,
-		&subscription.UpdatedAt,
-	)
+func (r *SQLiteSubscriptionRepository) GetSubscriptionByID(ctx context.Context, id int64) (*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = ?`
+	subscription, err := scanSubscription(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get subscription by ID: %w", err)
 	}
-	return &subscription, nil
+	return subscription, nil
 }
 
 // GetSubscriptionsByUserID retrieves all subscriptions by user ID from the database.
-func (r *SQLiteSubscriptionRepository) GetSubscriptionsByUserID(userID int64) ([]*m.Subscription, error) {
-	query := `SELECT id, user_id, city, storage, unit_size, status, created_at, updated_at FROM subscriptions WHERE user_id = ?`
-	rows, err := r.db.Query(query, userID)
+func (r *SQLiteSubscriptionRepository) GetSubscriptionsByUserID(ctx context.Context, userID int64) ([]*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE user_id = ?`
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get subscriptions by user ID: %w", err)
 	}
@@ -86,20 +97,11 @@ func (r *SQLiteSubscriptionRepository) GetSubscriptionsByUserID(userID int64) ([
 
 	var subscriptions []*m.Subscription
 	for rows.Next() {
-		var subscription m.Subscription
-		if err := rows.Scan(
-			&subscription.ID,
-			&subscription.UserID,
-			&subscription.City,
-			&subscription.Storage,
-			&subscription.UnitSize,
-			&subscription.Status,
-			&subscription.CreatedAt,
-			&subscription.UpdatedAt,
-		); err != nil {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan subscription row: %w", err)
 		}
-		subscriptions = append(subscriptions, &subscription)
+		subscriptions = append(subscriptions, subscription)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed during rows iteration: %w", err)
@@ -108,9 +110,9 @@ func (r *SQLiteSubscriptionRepository) GetSubscriptionsByUserID(userID int64) ([
 }
 
 // GetAllSubscriptions retrieves all subscriptions from the database.
-func (r *SQLiteSubscriptionRepository) GetAllSubscriptions() ([]*m.Subscription, error) {
-	query := `SELECT id, user_id, city, storage, unit_size, status, created_at, updated_at FROM subscriptions`
-	rows, err := r.db.Query(query)
+func (r *SQLiteSubscriptionRepository) GetAllSubscriptions(ctx context.Context) ([]*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions`
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all subscriptions: %w", err)
 	}
@@ -118,20 +120,11 @@ func (r *SQLiteSubscriptionRepository) GetAllSubscriptions() ([]*m.Subscription,
 
 	var subscriptions []*m.Subscription
 	for rows.Next() {
-		var subscription m.Subscription
-		if err := rows.Scan(
-			&subscription.ID,
-			&subscription.UserID,
-			&subscription.City,
-			&subscription.Storage,
-			&subscription.UnitSize,
-			&subscription.Status,
-			&subscription.CreatedAt,
-			&subscription.UpdatedAt,
-		); err != nil {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan subscription row: %w", err)
 		}
-		subscriptions = append(subscriptions, &subscription)
+		subscriptions = append(subscriptions, subscription)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed during rows iteration: %w", err)
@@ -140,9 +133,9 @@ func (r *SQLiteSubscriptionRepository) GetAllSubscriptions() ([]*m.Subscription,
 }
 
 // GetActiveSubscriptions retrieves all active subscriptions from the database.
-func (r *SQLiteSubscriptionRepository) GetActiveSubscriptions() ([]*m.Subscription, error) {
-	query := `SELECT id, user_id, city, storage, unit_size, status, created_at, updated_at FROM subscriptions WHERE status = 'active'`
-	rows, err := r.db.Query(query)
+func (r *SQLiteSubscriptionRepository) GetActiveSubscriptions(ctx context.Context) ([]*m.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE status = 'active'`
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active subscriptions: %w", err)
 	}
@@ -150,20 +143,11 @@ func (r *SQLiteSubscriptionRepository) GetActiveSubscriptions() ([]*m.Subscripti
 
 	var subscriptions []*m.Subscription
 	for rows.Next() {
-		var subscription m.Subscription
-		if err := rows.Scan(
-			&subscription.ID,
-			&subscription.UserID,
-			&subscription.City,
-			&subscription.Storage,
-			&subscription.UnitSize,
-			&subscription.Status,
-			&subscription.CreatedAt,
-			&subscription.UpdatedAt,
-		); err != nil {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan active subscription row: %w", err)
 		}
-		subscriptions = append(subscriptions, &subscription)
+		subscriptions = append(subscriptions, subscription)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed during active subscriptions iteration: %w", err)
@@ -172,15 +156,27 @@ func (r *SQLiteSubscriptionRepository) GetActiveSubscriptions() ([]*m.Subscripti
 }
 
 // UpdateSubscription updates a subscription in the database.
-func (r *SQLiteSubscriptionRepository) UpdateSubscription(subscription *m.Subscription) error {
-	query := `UPDATE subscriptions SET user_id = ?, city = ?, storage = ?, unit_size = ?, status = ?, updated_at = ? WHERE id = ?`
-	_, err := r.db.Exec(
+func (r *SQLiteSubscriptionRepository) UpdateSubscription(ctx context.Context, subscription *m.Subscription) error {
+	query := `
+		UPDATE subscriptions
+		SET user_id = ?, provider = ?, city = ?, storage = ?, unit_size = ?, status = ?,
+			delivery_mode = ?, digest_time = ?, digest_weekday = ?, max_price = ?, min_price_drop = ?, updated_at = ?
+		WHERE id = ?
+	`
+	_, err := r.db.ExecContext(
+		ctx,
 		query,
 		subscription.UserID,
+		subscription.Provider,
 		subscription.City,
 		subscription.Storage,
 		subscription.UnitSize,
 		subscription.Status,
+		subscription.DeliveryMode,
+		subscription.DigestTime,
+		subscription.DigestWeekday,
+		subscription.MaxPrice,
+		subscription.MinPriceDrop,
 		time.Now(),
 		subscription.ID,
 	)
@@ -191,10 +187,44 @@ func (r *SQLiteSubscriptionRepository) UpdateSubscription(subscription *m.Subscr
 }
 
 // DeleteSubscription deletes a subscription from the database.
-func (r *SQLiteSubscriptionRepository) DeleteSubscription(id int64) error {
-	_, err := r.db.Exec("DELETE FROM subscriptions WHERE id = ?", id)
+// The pending digest items and the claimed alerts of the subscription are deleted with it,
+// since the foreign keys of SQLite are not enforced and do not cascade.
+func (r *SQLiteSubscriptionRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		for _, query := range []string{
+			"DELETE FROM digest_items WHERE subscription_id = ?",
+			"DELETE FROM unit_alerts WHERE subscription_id = ?",
+			"DELETE FROM subscriptions WHERE id = ?",
+		} {
+			if _, err := tx.ExecContext(ctx, query, id); err != nil {
+				return fmt.Errorf("failed to delete subscription: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// scanSubscription scans a row of subscriptionColumns.
+func scanSubscription(row interface{ Scan(dest ...any) error }) (*m.Subscription, error) {
+	var subscription m.Subscription
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.UserID,
+		&subscription.Provider,
+		&subscription.City,
+		&subscription.Storage,
+		&subscription.UnitSize,
+		&subscription.Status,
+		&subscription.DeliveryMode,
+		&subscription.DigestTime,
+		&subscription.DigestWeekday,
+		&subscription.MaxPrice,
+		&subscription.MinPriceDrop,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to delete subscription: %w", err)
+		return nil, err
 	}
-	return nil
+	return &subscription, nil
 }
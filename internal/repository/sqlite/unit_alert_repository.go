@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UnitAlertRepository = (*SQLiteUnitAlertRepository)(nil)
+
+// SQLiteUnitAlertRepository implements the UnitAlertRepository interface using SQLite.
+type SQLiteUnitAlertRepository struct {
+	db queryer
+}
+
+// NewSQLiteUnitAlertRepository creates a new instance of SQLiteUnitAlertRepository.
+func NewSQLiteUnitAlertRepository(db *sql.DB) *SQLiteUnitAlertRepository {
+	return &SQLiteUnitAlertRepository{db: db}
+}
+
+// ClaimUnitAlert records the announcement of the unit to the subscription unless it is recorded already.
+func (r *SQLiteUnitAlertRepository) ClaimUnitAlert(ctx context.Context, subscriptionID, unitID int64, at time.Time) (bool, error) {
+	query := `
+		INSERT INTO unit_alerts (subscription_id, unit_id, announced_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(subscription_id, unit_id) DO NOTHING
+	`
+	result, err := r.db.ExecContext(ctx, query, subscriptionID, unitID, at.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim unit alert: %w", err)
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim unit alert: %w", err)
+	}
+	return claimed > 0, nil
+}
+
+// ClearUnitAlerts forgets the announcements of the unit.
+func (r *SQLiteUnitAlertRepository) ClearUnitAlerts(ctx context.Context, unitID int64) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM unit_alerts WHERE unit_id = ?", unitID); err != nil {
+		return fmt.Errorf("failed to clear unit alerts: %w", err)
+	}
+	return nil
+}
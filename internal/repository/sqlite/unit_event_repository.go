@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+var _ repository.UnitEventRepository = (*SQLiteUnitEventRepository)(nil)
+
+// SQLiteUnitEventRepository implements the UnitEventRepository interface using SQLite.
+type SQLiteUnitEventRepository struct {
+	db queryer
+}
+
+// NewSQLiteUnitEventRepository creates a new instance of SQLiteUnitEventRepository.
+func NewSQLiteUnitEventRepository(db *sql.DB) *SQLiteUnitEventRepository {
+	return &SQLiteUnitEventRepository{db: db}
+}
+
+// AddUnitEvents inserts the events into the database in a single transaction.
+// The IDs of the stored events are written back into events.
+func (r *SQLiteUnitEventRepository) AddUnitEvents(ctx context.Context, events []m.UnitEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO unit_events (event, created_at) VALUES (?, ?) RETURNING id`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare unit event insert: %w", err)
+		}
+		defer stmt.Close()
+
+		now := time.Now().UTC()
+		for i := range events {
+			event, err := json.Marshal(events[i])
+			if err != nil {
+				return fmt.Errorf("failed to encode unit event: %w", err)
+			}
+			if err := stmt.QueryRowContext(ctx, string(event), now).Scan(&events[i].ID); err != nil {
+				return fmt.Errorf("failed to add unit event: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetUnitEvents retrieves up to limit events with an ID greater than afterID from the database, the oldest first.
+func (r *SQLiteUnitEventRepository) GetUnitEvents(ctx context.Context, afterID int64, limit int) ([]m.UnitEvent, error) {
+	query := `
+		SELECT id, event
+		FROM unit_events
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []m.UnitEvent
+	for rows.Next() {
+		var id int64
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, fmt.Errorf("failed to scan unit event: %w", err)
+		}
+		var event m.UnitEvent
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode unit event: %w", err)
+		}
+		event.ID = id
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed during unit events rows iteration: %w", err)
+	}
+	return events, nil
+}
+
+// DeleteUnitEvents deletes the events with the IDs from the database.
+func (r *SQLiteUnitEventRepository) DeleteUnitEvents(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := `DELETE FROM unit_events WHERE id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete unit events: %w", err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -14,7 +15,7 @@ var _ repository.UnitRepository = (*SQLiteUnitRepository)(nil)
 
 // SQLiteUnitRepository implements the UnitRepository interface using SQLite.
 type SQLiteUnitRepository struct {
-	db *sql.DB
+	db queryer
 }
 
 // NewSQLiteUnitRepository creates a new instance of SQLiteUnitRepository.
@@ -22,59 +23,96 @@ func NewSQLiteUnitRepository(db *sql.DB) *SQLiteUnitRepository {
 	return &SQLiteUnitRepository{db: db}
 }
 
+// upsertUnitQuery inserts a unit or updates the stored unit with the same provider and external identifier.
+const upsertUnitQuery = `
+	INSERT INTO units (provider, external_id, name, city, storage, size, dimension, price, available, description, url, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(provider, external_id) DO UPDATE SET
+		name = excluded.name,
+		city = excluded.city,
+		storage = excluded.storage,
+		size = excluded.size,
+		dimension = excluded.dimension,
+		price = excluded.price,
+		available = excluded.available,
+		description = excluded.description,
+		url = excluded.url,
+		updated_at = excluded.updated_at
+	RETURNING id
+`
+
 // CreateUnit inserts or updates a unit in the database.
-func (r *SQLiteUnitRepository) CreateUnit(unit *m.Unit) error {
-	query := `
-		INSERT INTO units (id, name, city, size, dimension, price, available, description, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			name = excluded.name,
-			city = excluded.city,
-			size = excluded.size,
-			dimension = excluded.dimension,
-			price = excluded.price,
-			available = excluded.available,
-			description = excluded.description,
-			updated_at = excluded.updated_at
-	`
-	_, err := r.db.Exec(
-		query,
-		unit.ID,
+// Units are matched by their provider and external identifier, the ID of the stored unit is written back into unit.
+func (r *SQLiteUnitRepository) CreateUnit(ctx context.Context, unit *m.Unit) error {
+	err := r.db.QueryRowContext(ctx, upsertUnitQuery, upsertUnitArgs(unit)...).Scan(&unit.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save unit: %w", err)
+	}
+	return nil
+}
+
+// UpsertUnits inserts or updates the units in a single transaction using a prepared statement.
+// Either all units are saved or none, the IDs of the stored units are written back into units.
+func (r *SQLiteUnitRepository) UpsertUnits(ctx context.Context, units []m.Unit) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, upsertUnitQuery)
+		if err != nil {
+			return fmt.Errorf("failed to prepare unit upsert: %w", err)
+		}
+		defer stmt.Close()
+
+		for i := range units {
+			unit := &units[i]
+			if err := stmt.QueryRowContext(ctx, upsertUnitArgs(unit)...).Scan(&unit.ID); err != nil {
+				return fmt.Errorf("failed to save unit %s/%s: %w", unit.Provider, unit.ExternalID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// upsertUnitArgs returns the arguments of upsertUnitQuery for the unit.
+func upsertUnitArgs(unit *m.Unit) []any {
+	return []any{
+		unit.Provider,
+		unit.ExternalID,
 		unit.Name,
 		unit.City,
+		unit.Storage,
 		unit.Size,
 		unit.Dimension,
 		unit.Price,
 		unit.Available,
 		unit.Description,
+		unit.URL,
 		unit.CreatedAt,
 		unit.UpdatedAt,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to save unit: %w", err)
 	}
-	return nil
 }
 
 // GetUnitByID retrieves a unit by ID from the database.
-func (r *SQLiteUnitRepository) GetUnitByID(id int64) (*m.Unit, error) {
+func (r *SQLiteUnitRepository) GetUnitByID(ctx context.Context, id int64) (*m.Unit, error) {
 	query := `
-		SELECT id, name, city, size, dimension, price, available, description, created_at, updated_at 
+		SELECT id, provider, external_id, name, city, storage, size, dimension, price, available, description, url, created_at, updated_at 
 		FROM units 
 		WHERE id = ?
 	`
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 
 	var unit m.Unit
 	err := row.Scan(
 		&unit.ID,
+		&unit.Provider,
+		&unit.ExternalID,
 		&unit.Name,
 		&unit.City,
+		&unit.Storage,
 		&unit.Size,
 		&unit.Dimension,
 		&unit.Price,
 		&unit.Available,
 		&unit.Description,
+		&unit.URL,
 		&unit.CreatedAt,
 		&unit.UpdatedAt,
 	)
@@ -88,12 +126,12 @@ func (r *SQLiteUnitRepository) GetUnitByID(id int64) (*m.Unit, error) {
 }
 
 // GetAllUnits retrieves all units from the database.
-func (r *SQLiteUnitRepository) GetAllUnits() ([]*m.Unit, error) {
+func (r *SQLiteUnitRepository) GetAllUnits(ctx context.Context) ([]*m.Unit, error) {
 	query := `
-		SELECT id, name, city, size, dimension, price, available, description, created_at, updated_at 
+		SELECT id, provider, external_id, name, city, storage, size, dimension, price, available, description, url, created_at, updated_at 
 		FROM units
 	`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all units: %w", err)
 	}
@@ -104,13 +142,17 @@ func (r *SQLiteUnitRepository) GetAllUnits() ([]*m.Unit, error) {
 		var unit m.Unit
 		if err := rows.Scan(
 			&unit.ID,
+			&unit.Provider,
+			&unit.ExternalID,
 			&unit.Name,
 			&unit.City,
+			&unit.Storage,
 			&unit.Size,
 			&unit.Dimension,
 			&unit.Price,
 			&unit.Available,
 			&unit.Description,
+			&unit.URL,
 			&unit.CreatedAt,
 			&unit.UpdatedAt,
 		); err != nil {
@@ -125,12 +167,12 @@ func (r *SQLiteUnitRepository) GetAllUnits() ([]*m.Unit, error) {
 }
 
 // GetCities retrieves the list of cities from the database.
-func (r *SQLiteUnitRepository) GetCities() ([]string, error) {
+func (r *SQLiteUnitRepository) GetCities(ctx context.Context) ([]string, error) {
 	query := `
 		SELECT DISTINCT city 
 		FROM units
 	`
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cities: %w", err)
 	}
@@ -150,23 +192,24 @@ func (r *SQLiteUnitRepository) GetCities() ([]string, error) {
 	return cities, nil
 }
 
-// GetStoragesByCity retrieves storage names based on the city from the database.
-func (r *SQLiteUnitRepository) GetStoragesByCity(city string) ([]string, error) {
+// GetStoragesByCity retrieves the storages of every provider in the city from the database.
+func (r *SQLiteUnitRepository) GetStoragesByCity(ctx context.Context, city string) ([]m.Storage, error) {
 	query := `
-		SELECT DISTINCT name 
-		FROM units 
+		SELECT DISTINCT provider, storage
+		FROM units
 		WHERE city = ?
+		ORDER BY storage, provider
 	`
-	rows, err := r.db.Query(query, city)
+	rows, err := r.db.QueryContext(ctx, query, city)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get storages by city: %w", err)
 	}
 	defer rows.Close()
 
-	var storages []string
+	var storages []m.Storage
 	for rows.Next() {
-		var storage string
-		if err := rows.Scan(&storage); err != nil {
+		var storage m.Storage
+		if err := rows.Scan(&storage.Provider, &storage.Name); err != nil {
 			return nil, fmt.Errorf("failed to scan storage row: %w", err)
 		}
 		storages = append(storages, storage)
@@ -177,14 +220,14 @@ func (r *SQLiteUnitRepository) GetStoragesByCity(city string) ([]string, error)
 	return storages, nil
 }
 
-// GetUnitSizesByStorage retrieves unit sizes based on the storage name from the database.
-func (r *SQLiteUnitRepository) GetUnitSizesByStorage(storage string) ([]string, error) {
+// GetUnitSizesByStorage retrieves the unit sizes of the storage of a provider in the city from the database.
+func (r *SQLiteUnitRepository) GetUnitSizesByStorage(ctx context.Context, storage m.Storage, city string) ([]string, error) {
 	query := `
-		SELECT DISTINCT size 
-		FROM units 
-		WHERE name = ?
+		SELECT DISTINCT size
+		FROM units
+		WHERE provider = ? AND storage = ? AND city = ?
 	`
-	rows, err := r.db.Query(query, storage)
+	rows, err := r.db.QueryContext(ctx, query, storage.Provider, storage.Name, city)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get unit sizes by storage: %w", err)
 	}
@@ -205,21 +248,26 @@ func (r *SQLiteUnitRepository) GetUnitSizesByStorage(storage string) ([]string,
 }
 
 // UpdateUnit updates a unit in the database.
-func (r *SQLiteUnitRepository) UpdateUnit(unit *m.Unit) error {
+func (r *SQLiteUnitRepository) UpdateUnit(ctx context.Context, unit *m.Unit) error {
 	query := `
 		UPDATE units 
-		SET name = ?, city = ?, size = ?, dimension = ?, price = ?, available = ?, description = ?, updated_at = ?
+		SET provider = ?, external_id = ?, name = ?, city = ?, storage = ?, size = ?, dimension = ?, price = ?, available = ?, description = ?, url = ?, updated_at = ?
 		WHERE id = ?
 	`
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(
+		ctx,
 		query,
+		unit.Provider,
+		unit.ExternalID,
 		unit.Name,
 		unit.City,
+		unit.Storage,
 		unit.Size,
 		unit.Dimension,
 		unit.Price,
 		unit.Available,
 		unit.Description,
+		unit.URL,
 		time.Now(),
 		unit.ID,
 	)
@@ -230,8 +278,8 @@ func (r *SQLiteUnitRepository) UpdateUnit(unit *m.Unit) error {
 }
 
 // DeleteUnit deletes a unit from the database.
-func (r *SQLiteUnitRepository) DeleteUnit(id int64) error {
-	_, err := r.db.Exec("DELETE FROM units WHERE id = ?", id)
+func (r *SQLiteUnitRepository) DeleteUnit(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM units WHERE id = ?", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete unit: %w", err)
 	}
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -15,35 +16,55 @@ func NewSQLiteUserRepository(db *sql.DB) *SQLiteUserRepository {
 	return &SQLiteUserRepository{db: db}
 }
 
+// userColumns are the columns scanned by scanUser.
+const userColumns = `id, telegram_id, username, first_name, last_name, last_notified, active,
+	time_zone, quiet_hours_start, quiet_hours_end, created_at, updated_at`
+
 // SQLiteUserRepository implements the UserRepository interface using SQLite.
 type SQLiteUserRepository struct {
-	db *sql.DB
+	db queryer
 }
 
 // CreateUser inserts or updates a user in the database.
-func (r *SQLiteUserRepository) CreateUser(user *m.User) error {
+// A user without an ID is inserted, the ID of the stored user is written back into user.
+func (r *SQLiteUserRepository) CreateUser(ctx context.Context, user *m.User) error {
 	query := `
-		INSERT INTO users (id, telegram_id, username, first_name, last_name, last_notified, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO users (
+			id, telegram_id, username, first_name, last_name, last_notified, active,
+			time_zone, quiet_hours_start, quiet_hours_end, created_at, updated_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			telegram_id = excluded.telegram_id,
 			username = excluded.username,
 			first_name = excluded.first_name,
 			last_name = excluded.last_name,
 			last_notified = excluded.last_notified,
+			active = excluded.active,
+			time_zone = excluded.time_zone,
+			quiet_hours_start = excluded.quiet_hours_start,
+			quiet_hours_end = excluded.quiet_hours_end,
 			updated_at = excluded.updated_at
+		RETURNING id
 	`
-	_, err := r.db.Exec(
+	// A NULL ID makes SQLite assign the next one, an ID of zero would be stored as is
+	id := sql.NullInt64{Int64: user.ID, Valid: user.ID != 0}
+	err := r.db.QueryRowContext(
+		ctx,
 		query,
-		user.ID,
+		id,
 		user.TelegramID,
 		user.UserName,
 		user.FirstName,
 		user.LastName,
 		user.LastNotified,
+		user.Active,
+		user.TimeZone,
+		user.QuietHoursStart,
+		user.QuietHoursEnd,
 		user.CreatedAt,
 		user.UpdatedAt,
-	)
+	).Scan(&user.ID)
 	if err != nil {
 		return fmt.Errorf("failed to save user: %w", err)
 	}
@@ -51,41 +72,35 @@ func (r *SQLiteUserRepository) CreateUser(user *m.User) error {
 }
 
 // GetUserByID retrieves a user by ID from the database.
-func (r *SQLiteUserRepository) GetUserByID(id int64) (*m.User, error) {
-	query := `SELECT id, telegram_id, username, first_name, last_name, last_notified, created_at, updated_at FROM users WHERE id = ?`
-	row := r.db.QueryRow(query, id)
-
-	var user m.User
-	err := row.Scan(&user.ID, &user.TelegramID, &user.UserName, &user.FirstName, &user.LastName, &user.LastNotified, &user.CreatedAt, &user.UpdatedAt)
+func (r *SQLiteUserRepository) GetUserByID(ctx context.Context, id int64) (*m.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
-	return &user, nil
+	return user, nil
 }
 
 // GetByTelegramID retrieves a user by Telegram ID from the database.
-func (r *SQLiteUserRepository) GetByTelegramID(id int64) (*m.User, error) {
-	query := `SELECT id, telegram_id, username, first_name, last_name, last_notified, created_at, updated_at FROM users WHERE telegram_id = ?`
-	row := r.db.QueryRow(query, id)
-
-	var user m.User
-	err := row.Scan(&user.ID, &user.TelegramID, &user.UserName, &user.FirstName, &user.LastName, &user.LastNotified, &user.CreatedAt, &user.UpdatedAt)
+func (r *SQLiteUserRepository) GetByTelegramID(ctx context.Context, id int64) (*m.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE telegram_id = ?`
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get user by Telegram ID: %w", err)
 	}
-	return &user, nil
+	return user, nil
 }
 
 // GetAllUsers retrieves all users from the database.
-func (r *SQLiteUserRepository) GetAllUsers() ([]*m.User, error) {
-	query := `SELECT id, telegram_id, username, first_name, last_name, last_notified, created_at, updated_at FROM users`
-	rows, err := r.db.Query(query)
+func (r *SQLiteUserRepository) GetAllUsers(ctx context.Context) ([]*m.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users`
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all users: %w", err)
 	}
@@ -93,30 +108,35 @@ func (r *SQLiteUserRepository) GetAllUsers() ([]*m.User, error) {
 
 	users := make([]*m.User, 0)
 	for rows.Next() {
-		var user m.User
-		err := rows.Scan(&user.ID, &user.TelegramID, &user.UserName, &user.FirstName, &user.LastName, &user.LastNotified, &user.CreatedAt, &user.UpdatedAt)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 	return users, nil
 }
 
 // UpdateUser updates a user in the database.
-func (r *SQLiteUserRepository) UpdateUser(user *m.User) error {
+func (r *SQLiteUserRepository) UpdateUser(ctx context.Context, user *m.User) error {
 	query := `
 		UPDATE users
-		SET telegram_id = ?, username = ?, first_name = ?, last_name = ?, last_notified = ?, updated_at = ?
+		SET telegram_id = ?, username = ?, first_name = ?, last_name = ?, last_notified = ?, active = ?,
+			time_zone = ?, quiet_hours_start = ?, quiet_hours_end = ?, updated_at = ?
 		WHERE id = ?
 	`
-	_, err := r.db.Exec(
+	_, err := r.db.ExecContext(
+		ctx,
 		query,
 		user.TelegramID,
 		user.UserName,
 		user.FirstName,
 		user.LastName,
 		user.LastNotified,
+		user.Active,
+		user.TimeZone,
+		user.QuietHoursStart,
+		user.QuietHoursEnd,
 		user.UpdatedAt,
 		user.ID,
 	)
@@ -127,11 +147,34 @@ func (r *SQLiteUserRepository) UpdateUser(user *m.User) error {
 }
 
 // DeleteUser deletes a user from the database.
-func (r *SQLiteUserRepository) DeleteUser(user *m.User) error {
+func (r *SQLiteUserRepository) DeleteUser(ctx context.Context, user *m.User) error {
 	query := `DELETE FROM users WHERE id = ?`
-	_, err := r.db.Exec(query, user.ID)
+	_, err := r.db.ExecContext(ctx, query, user.ID)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 	return nil
 }
+
+// scanUser scans a row of userColumns.
+func scanUser(row interface{ Scan(dest ...any) error }) (*m.User, error) {
+	var user m.User
+	err := row.Scan(
+		&user.ID,
+		&user.TelegramID,
+		&user.UserName,
+		&user.FirstName,
+		&user.LastName,
+		&user.LastNotified,
+		&user.Active,
+		&user.TimeZone,
+		&user.QuietHoursStart,
+		&user.QuietHoursEnd,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
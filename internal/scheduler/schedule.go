@@ -0,0 +1,189 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCronLookahead bounds the search for the next time matching a cron expression.
+const maxCronLookahead = 5 * 366 * 24 * time.Hour
+
+// Schedule computes the run times of a job.
+type Schedule interface {
+	// Next returns the first run time after the time.
+	Next(after time.Time) time.Time
+}
+
+// descriptors are the shorthands accepted in place of a cron expression.
+var descriptors = map[string]string{
+	"@hourly": "0 * * * *",
+	"@daily":  "0 0 * * *",
+	"@weekly": "0 0 * * 0",
+}
+
+// ParseSchedule parses a schedule, either an interval like "@every 30m", a shorthand like "@daily",
+// or a cron expression of five fields: minute, hour, day of month, month and day of week.
+// The cron fields accept "*", values, ranges, steps and lists, e.g. "*/15 8-20 * * 1-5".
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if every, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %w", every, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid interval %q: must be positive", every)
+		}
+		return intervalSchedule(interval), nil
+	}
+	if expression, ok := descriptors[spec]; ok {
+		spec = expression
+	}
+	return parseCron(spec)
+}
+
+// intervalSchedule runs a job at a fixed interval after its previous run.
+type intervalSchedule time.Duration
+
+// Next returns the time the interval after the time.
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(s))
+}
+
+// cronSchedule runs a job at the times matching a cron expression, in the time zone of the given times.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays uint64 // Bit sets of the matching values
+
+	anyDay, anyWeekday bool // A field left at "*" does not restrict the day
+}
+
+// cronField describes the range of values of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField  = cronField{name: "minute", min: 0, max: 59}
+	hourField    = cronField{name: "hour", min: 0, max: 23}
+	dayField     = cronField{name: "day of month", min: 1, max: 31}
+	monthField   = cronField{name: "month", min: 1, max: 12}
+	weekdayField = cronField{name: "day of week", min: 0, max: 7} // Both 0 and 7 are Sunday
+)
+
+// parseCron parses a cron expression of five fields.
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", spec, len(fields))
+	}
+
+	var s cronSchedule
+	var err error
+	parsers := []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{minuteField, &s.minutes},
+		{hourField, &s.hours},
+		{dayField, &s.days},
+		{monthField, &s.months},
+		{weekdayField, &s.weekdays},
+	}
+	for i, parser := range parsers {
+		if *parser.bits, err = parser.field.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+	}
+	if s.weekdays&(1<<7) != 0 {
+		s.weekdays |= 1
+	}
+	s.anyDay = fields[2] == "*"
+	s.anyWeekday = fields[4] == "*"
+	return &s, nil
+}
+
+// parse returns the bit set of the values matching a field.
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q of the %s", stepPart, f.name)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = f.value(lowPart); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highPart); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q of the %s", rangePart, f.name)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single value of the field.
+func (f cronField) value(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, expected %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first minute after the time matching the expression,
+// or the zero time if none comes within the next five years, e.g. for February 30.
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronLookahead)
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case s.months&(1<<uint(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of the time matches. Like in cron, a day matches either field
+// if both the day of month and the day of week are restricted.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	weekday := s.weekdays&(1<<uint(t.Weekday())) != 0
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
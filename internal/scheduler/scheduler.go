@@ -0,0 +1,226 @@
+// Package scheduler runs the periodic jobs of the bot, such as parsing the sources and sending the digests.
+// The run history of every job is kept in the database, so a restart neither repeats nor skips runs:
+// a job whose run time passed while the process was down runs right after the start.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+)
+
+// idleWait is how long the scheduler sleeps when no job has a next run.
+const idleWait = time.Hour
+
+// Job is a task run on a schedule.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Jitter   time.Duration // Up to this random delay is added to every run time, spreading the load on the sources
+	Run      func(ctx context.Context) error
+}
+
+// job is a registered job together with its run state.
+type job struct {
+	Job
+	state   m.JobState
+	running bool
+}
+
+// Scheduler runs the registered jobs at their scheduled times.
+// A job is never run twice at the same time, a run falling due while the previous one is still running is skipped.
+type Scheduler struct {
+	repo   repository.JobStateRepository
+	jobs   []*job
+	now    func() time.Time
+	jitter func(maximum time.Duration) time.Duration
+
+	mu sync.Mutex // Guards the run state of the jobs
+	wg sync.WaitGroup
+}
+
+// NewScheduler creates a new Scheduler keeping the run history of the jobs in the repository.
+func NewScheduler(repo repository.JobStateRepository) *Scheduler {
+	return &Scheduler{
+		repo:   repo,
+		now:    time.Now,
+		jitter: randomJitter,
+	}
+}
+
+// Add registers a job. Jobs must be added before the scheduler is started.
+func (s *Scheduler) Add(j Job) {
+	s.jobs = append(s.jobs, &job{Job: j, state: m.JobState{Name: j.Name}})
+}
+
+// Start runs the jobs until the context is done, then waits for the running jobs to return.
+func (s *Scheduler) Start(ctx context.Context) error {
+	slog.Info("Scheduler started", "jobs", len(s.jobs))
+	defer s.wg.Wait()
+
+	for _, j := range s.jobs {
+		s.plan(ctx, j)
+	}
+
+	timer := time.NewTimer(s.untilNextRun())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Scheduler shutting down")
+			return nil
+		case <-timer.C:
+			s.runDue(ctx)
+			timer.Reset(s.untilNextRun())
+		}
+	}
+}
+
+// plan restores the run history of the job and sets its first run.
+// The first run follows the last one according to the schedule, a run missed while the process was down is made now.
+func (s *Scheduler) plan(ctx context.Context, j *job) {
+	stored, err := s.repo.GetJobState(ctx, j.Name)
+	if err != nil {
+		slog.Error("Failed to load job state", "job", j.Name, "error", err)
+	}
+
+	s.mu.Lock()
+	if stored != nil {
+		j.state = *stored
+	}
+	now := s.now()
+	next := now
+	if j.state.LastRunAt != nil {
+		next = *j.state.LastRunAt
+	}
+	j.state.NextRunAt = s.nextRun(j, next)
+	if !j.state.NextRunAt.IsZero() && j.state.NextRunAt.Before(now) {
+		j.state.NextRunAt = now
+	}
+	state := j.state
+	s.mu.Unlock()
+
+	if state.NextRunAt.IsZero() {
+		slog.Warn("Job is never due", "job", j.Name)
+	} else {
+		slog.Info("Job scheduled", "job", j.Name, "nextRunAt", state.NextRunAt)
+	}
+	s.save(ctx, &state)
+}
+
+// runDue starts the jobs that are due and plans their next run.
+func (s *Scheduler) runDue(ctx context.Context) {
+	s.mu.Lock()
+	var skipped []m.JobState
+	now := s.now()
+	for _, j := range s.jobs {
+		if j.state.NextRunAt.IsZero() || j.state.NextRunAt.After(now) {
+			continue
+		}
+
+		j.state.NextRunAt = s.nextRun(j, now)
+		if j.running {
+			slog.Warn("Skipping job run, the previous run is still running", "job", j.Name)
+			skipped = append(skipped, j.state)
+			continue
+		}
+
+		j.running = true
+		s.wg.Add(1)
+		go s.run(ctx, j, now)
+	}
+	s.mu.Unlock()
+
+	for i := range skipped {
+		s.save(ctx, &skipped[i])
+	}
+}
+
+// run runs the job and records the outcome. A run interrupted by the shutdown is not recorded,
+// so it is made again after the restart.
+func (s *Scheduler) run(ctx context.Context, j *job, startedAt time.Time) {
+	defer s.wg.Done()
+
+	slog.Info("Running job", "job", j.Name)
+	err := runJob(ctx, j)
+	duration := s.now().Sub(startedAt)
+
+	s.mu.Lock()
+	j.running = false
+	if ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	j.state.LastRunAt = &startedAt
+	j.state.LastDuration = duration
+	j.state.LastError = ""
+	if err != nil {
+		j.state.LastError = err.Error()
+	}
+	state := j.state
+	s.mu.Unlock()
+
+	if err != nil {
+		slog.Error("Job failed", "job", j.Name, "duration", duration, "error", err)
+	} else {
+		slog.Info("Job finished", "job", j.Name, "duration", duration)
+	}
+	s.save(ctx, &state)
+}
+
+// runJob runs the job once and turns a panic into an error, which is recorded like any other failure.
+func runJob(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Job panicked", "job", j.Name, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return j.Run(ctx)
+}
+
+// nextRun returns the run of the job following the time, delayed by a random jitter.
+func (s *Scheduler) nextRun(j *job, after time.Time) time.Time {
+	next := j.Schedule.Next(after)
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(s.jitter(j.Jitter))
+}
+
+// untilNextRun returns the time until the earliest next run of the jobs.
+func (s *Scheduler) untilNextRun() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := idleWait
+	now := s.now()
+	for _, j := range s.jobs {
+		if !j.state.NextRunAt.IsZero() {
+			wait = min(wait, j.state.NextRunAt.Sub(now))
+		}
+	}
+	return max(wait, 0)
+}
+
+// save stores the state of a job, a failure only loses the history shown to the admin.
+func (s *Scheduler) save(ctx context.Context, state *m.JobState) {
+	if err := s.repo.SaveJobState(ctx, state); err != nil {
+		slog.Error("Failed to save job state", "job", state.Name, "error", err)
+	}
+}
+
+// randomJitter returns a random duration below the maximum, zero for a maximum of zero.
+func randomJitter(maximum time.Duration) time.Duration {
+	if maximum <= 0 {
+		return 0
+	}
+	return rand.N(maximum)
+}
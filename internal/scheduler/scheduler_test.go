@@ -0,0 +1,198 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/memory"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// Friday, 7 June 2024
+	after := time.Date(2024, 6, 7, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@every 30m", after.Add(30 * time.Minute)},
+		{"@hourly", time.Date(2024, 6, 7, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 6, 9, 0, 0, 0, 0, time.UTC)},
+		{"* * * * *", time.Date(2024, 6, 7, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 6, 7, 10, 15, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2024, 6, 8, 3, 30, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2024, 6, 9, 9, 0, 0, 0, time.UTC)},
+		{"5,50 10 * * *", time.Date(2024, 6, 7, 10, 50, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 15 * 1", time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)}, // Either the 15th or a Monday
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			// Act
+			schedule, err := ParseSchedule(tt.spec)
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule.Next(after))
+		})
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	specs := []string{"", "@every", "@every soon", "@every -1m", "@yearly", "* * * *", "60 * * * *", "*/0 * * * *",
+		"5-1 * * * *", "0 24 * * *", "0 0 0 * *", "0 0 * 13 *", "0 0 * * 8", "a * * * *"}
+
+	for _, spec := range specs {
+		t.Run(spec, func(t *testing.T) {
+			_, err := ParseSchedule(spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// newTestScheduler creates a scheduler without jitter over the repository.
+func newTestScheduler(repo repository.JobStateRepository) *Scheduler {
+	s := NewScheduler(repo)
+	s.jitter = func(time.Duration) time.Duration { return 0 }
+	return s
+}
+
+// startScheduler runs the scheduler until the test finishes.
+func startScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// every parses an interval schedule.
+func every(t *testing.T, interval string) Schedule {
+	t.Helper()
+
+	schedule, err := ParseSchedule("@every " + interval)
+	require.NoError(t, err)
+	return schedule
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should run a job missed while stopped and record the run", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().JobStates
+		lastRunAt := time.Now().Add(-time.Hour)
+		require.NoError(t, repo.SaveJobState(ctx, &m.JobState{Name: "parse", LastRunAt: &lastRunAt}))
+		ran := make(chan struct{}, 1)
+		s := newTestScheduler(repo)
+		s.Add(Job{Name: "parse", Schedule: every(t, "30m"), Run: func(context.Context) error {
+			ran <- struct{}{}
+			return errors.New("source unavailable")
+		}})
+
+		// Act
+		startScheduler(t, s)
+
+		// Assert
+		select {
+		case <-ran:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the missed run was not made")
+		}
+		assert.Eventually(t, func() bool {
+			state, err := repo.GetJobState(ctx, "parse")
+			return err == nil && state.LastRunAt.After(lastRunAt) && state.LastError == "source unavailable"
+		}, 5*time.Second, time.Millisecond)
+		state, err := repo.GetJobState(ctx, "parse")
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), state.NextRunAt, time.Minute)
+	})
+
+	t.Run("should record a panicking job as failed and keep running it", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().JobStates
+		var runs atomic.Int32
+		s := newTestScheduler(repo)
+		s.Add(Job{Name: "digests", Schedule: every(t, "5ms"), Run: func(context.Context) error {
+			runs.Add(1)
+			panic("nil map")
+		}})
+
+		// Act
+		startScheduler(t, s)
+
+		// Assert
+		assert.Eventually(t, func() bool {
+			state, err := repo.GetJobState(ctx, "digests")
+			return err == nil && state != nil && state.LastError == "job panicked: nil map"
+		}, 5*time.Second, time.Millisecond)
+		assert.Eventually(t, func() bool { return runs.Load() > 1 }, 5*time.Second, time.Millisecond,
+			"the job runs again after the panic")
+	})
+
+	t.Run("should carry on the schedule of the previous run after a restart", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().JobStates
+		lastRunAt := time.Now().Add(-time.Minute)
+		require.NoError(t, repo.SaveJobState(ctx, &m.JobState{Name: "parse", LastRunAt: &lastRunAt}))
+		var runs atomic.Int32
+		s := newTestScheduler(repo)
+		s.Add(Job{Name: "parse", Schedule: every(t, "1h"), Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		}})
+
+		// Act
+		startScheduler(t, s)
+
+		// Assert
+		assert.Eventually(t, func() bool {
+			state, err := repo.GetJobState(ctx, "parse")
+			return err == nil && lastRunAt.Add(time.Hour).Equal(state.NextRunAt)
+		}, 5*time.Second, time.Millisecond)
+		assert.Zero(t, runs.Load())
+	})
+
+	t.Run("should skip a run while the previous one is still running", func(t *testing.T) {
+		// Arrange
+		repo := memory.NewStore().Repositories().JobStates
+		release := make(chan struct{})
+		var runs atomic.Int32
+		s := newTestScheduler(repo)
+		s.Add(Job{Name: "backup", Schedule: every(t, "5ms"), Run: func(context.Context) error {
+			runs.Add(1)
+			<-release
+			return nil
+		}})
+		startScheduler(t, s)
+
+		// Act
+		time.Sleep(100 * time.Millisecond)
+		overlapping := runs.Load()
+		close(release)
+
+		// Assert
+		assert.Equal(t, int32(1), overlapping)
+		assert.Eventually(t, func() bool { return runs.Load() > 1 }, 5*time.Second, time.Millisecond,
+			"the job runs again once the previous run returned")
+	})
+}
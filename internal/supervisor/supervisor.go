@@ -0,0 +1,179 @@
+// Package supervisor keeps the long-running services of the bot alive.
+// A service that fails, panics or returns before the shutdown is restarted with an exponential backoff,
+// and given up on when it keeps failing.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+)
+
+var (
+	// ErrPanic is wrapped by the error of a service that panicked.
+	ErrPanic = errors.New("panic")
+	// ErrExited is the error of a service that returned without an error before the shutdown.
+	ErrExited = errors.New("service exited unexpectedly")
+)
+
+// Alerter notifies the admin about the failures of the services.
+type Alerter interface {
+	SendErrorNotification(ctx context.Context, text string) error
+}
+
+// Status is the state of a supervised service.
+type Status struct {
+	Name        string
+	Running     bool
+	Stopped     bool // Given up on after too many restarts
+	Restarts    int
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// Supervisor runs services and restarts them when they fail.
+type Supervisor struct {
+	cfg     *config.SupervisorConfig
+	alerter Alerter
+	now     func() time.Time
+
+	mu       sync.Mutex
+	services []*Status
+	wg       sync.WaitGroup
+}
+
+// NewSupervisor creates a new Supervisor alerting the admin through the alerter.
+func NewSupervisor(cfg *config.SupervisorConfig, alerter Alerter) *Supervisor {
+	return &Supervisor{
+		cfg:     cfg,
+		alerter: alerter,
+		now:     time.Now,
+	}
+}
+
+// Go runs the service in a new goroutine until the context is done.
+func (s *Supervisor) Go(ctx context.Context, name string, service func(ctx context.Context) error) {
+	status := &Status{Name: name}
+	s.mu.Lock()
+	s.services = append(s.services, status)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.supervise(ctx, status, service)
+	}()
+}
+
+// Wait waits for all services to return.
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+}
+
+// Statuses returns the states of the services in the order they were started.
+func (s *Supervisor) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, len(s.services))
+	for i, status := range s.services {
+		statuses[i] = *status
+	}
+	return statuses
+}
+
+// supervise runs the service and restarts it after a failure until the context is done.
+// The backoff doubles with every failure and starts over once the service ran for the maximum backoff.
+// The service is given up on when it would be restarted more than the maximum restarts within the restart window.
+func (s *Supervisor) supervise(ctx context.Context, status *Status, service func(ctx context.Context) error) {
+	backoff := s.cfg.InitialBackoff
+	var restarts []time.Time
+	for {
+		s.update(status, func() { status.Running = true })
+		startedAt := s.now()
+		err := run(ctx, service)
+		s.update(status, func() { status.Running = false })
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = ErrExited
+		}
+
+		now := s.now()
+		if now.Sub(startedAt) >= s.cfg.MaxBackoff {
+			backoff = s.cfg.InitialBackoff
+		}
+		restarts = withinWindow(restarts, now.Add(-s.cfg.RestartWindow))
+		stop := s.cfg.MaxRestarts > 0 && len(restarts) >= s.cfg.MaxRestarts
+		s.update(status, func() {
+			status.LastError = err.Error()
+			status.LastErrorAt = now
+			status.Stopped = stop
+		})
+
+		if stop {
+			slog.Error("Service failed too often, giving up", "service", status.Name, "restarts", len(restarts), "error", err)
+			s.alert(ctx, fmt.Sprintf("Service %s failed %d times within %s and is stopped: %s",
+				status.Name, len(restarts)+1, s.cfg.RestartWindow, err))
+			return
+		}
+		slog.Error("Service failed, restarting", "service", status.Name, "backoff", backoff, "error", err)
+		s.alert(ctx, fmt.Sprintf("Service %s failed and is restarted in %s: %s", status.Name, backoff, err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		restarts = append(restarts, s.now())
+		s.update(status, func() { status.Restarts++ })
+		backoff = min(backoff*2, s.cfg.MaxBackoff)
+	}
+}
+
+// update changes the status of a service.
+func (s *Supervisor) update(status *Status, change func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change()
+}
+
+// alert notifies the admin, a failure to do so is only logged.
+func (s *Supervisor) alert(ctx context.Context, text string) {
+	if s.alerter == nil {
+		return
+	}
+	if err := s.alerter.SendErrorNotification(ctx, text); err != nil {
+		slog.Error("Failed to notify the admin", "error", err)
+	}
+}
+
+// run runs the service once and turns a panic into an error.
+func run(ctx context.Context, service func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Service panicked", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("%w: %v", ErrPanic, r)
+		}
+	}()
+	return service(ctx)
+}
+
+// withinWindow drops the times before the start of the window.
+func withinWindow(times []time.Time, start time.Time) []time.Time {
+	recent := times[:0]
+	for _, t := range times {
+		if !t.Before(start) {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
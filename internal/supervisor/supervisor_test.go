@@ -0,0 +1,151 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+)
+
+// recordingAlerter records the alerts sent to the admin.
+type recordingAlerter struct {
+	mu     sync.Mutex
+	alerts []string
+}
+
+func (a *recordingAlerter) SendErrorNotification(_ context.Context, text string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.alerts = append(a.alerts, text)
+	return nil
+}
+
+func (a *recordingAlerter) Alerts() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.alerts...)
+}
+
+func newTestSupervisor(maxRestarts int) (*Supervisor, *recordingAlerter) {
+	alerter := &recordingAlerter{}
+	cfg := &config.SupervisorConfig{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		MaxRestarts:    maxRestarts,
+		RestartWindow:  time.Hour,
+	}
+	return NewSupervisor(cfg, alerter), alerter
+}
+
+func TestSupervisor(t *testing.T) {
+	t.Run("should restart a failed service", func(t *testing.T) {
+		// Arrange
+		s, alerter := newTestSupervisor(0)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var runs atomic.Int32
+		recovered := make(chan struct{})
+
+		// Act
+		s.Go(ctx, "parser", func(ctx context.Context) error {
+			if runs.Add(1) < 3 {
+				return errors.New("fetch failed")
+			}
+			close(recovered)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		// Assert
+		select {
+		case <-recovered:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the service was not restarted")
+		}
+		statuses := s.Statuses()
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "parser", statuses[0].Name)
+		assert.True(t, statuses[0].Running)
+		assert.Equal(t, 2, statuses[0].Restarts)
+		assert.Equal(t, "fetch failed", statuses[0].LastError)
+		assert.False(t, statuses[0].LastErrorAt.IsZero())
+		assert.Len(t, alerter.Alerts(), 2)
+
+		cancel()
+		s.Wait()
+		assert.False(t, s.Statuses()[0].Running)
+	})
+
+	t.Run("should recover a panic into an error", func(t *testing.T) {
+		// Arrange
+		s, _ := newTestSupervisor(1)
+		ctx := context.Background()
+
+		// Act
+		s.Go(ctx, "notifier", func(context.Context) error {
+			panic("nil map")
+		})
+		s.Wait()
+
+		// Assert
+		statuses := s.Statuses()
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "panic: nil map", statuses[0].LastError)
+	})
+
+	t.Run("should give up after the maximum restarts", func(t *testing.T) {
+		// Arrange
+		s, alerter := newTestSupervisor(2)
+		ctx := context.Background()
+		var runs atomic.Int32
+
+		// Act
+		s.Go(ctx, "event bus", func(context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+		s.Wait()
+
+		// Assert
+		assert.Equal(t, int32(3), runs.Load())
+		statuses := s.Statuses()
+		require.Len(t, statuses, 1)
+		assert.True(t, statuses[0].Stopped)
+		assert.False(t, statuses[0].Running)
+		assert.Equal(t, 2, statuses[0].Restarts)
+		assert.Equal(t, ErrExited.Error(), statuses[0].LastError)
+		alerts := alerter.Alerts()
+		require.Len(t, alerts, 3)
+		assert.Contains(t, alerts[2], "Service event bus failed 3 times within 1h0m0s and is stopped")
+	})
+
+	t.Run("should not restart a service stopped by the shutdown", func(t *testing.T) {
+		// Arrange
+		s, alerter := newTestSupervisor(0)
+		ctx, cancel := context.WithCancel(context.Background())
+		var runs atomic.Int32
+
+		// Act
+		s.Go(ctx, "scheduler", func(ctx context.Context) error {
+			runs.Add(1)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		cancel()
+		s.Wait()
+
+		// Assert
+		assert.Equal(t, int32(1), runs.Load())
+		assert.Empty(t, s.Statuses()[0].LastError)
+		assert.Empty(t, alerter.Alerts())
+	})
+}
@@ -0,0 +1,106 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/supervisor"
+)
+
+// jobTimeLayout formats the run times of the scheduled jobs, which are shown in UTC.
+const jobTimeLayout = "2006-01-02 15:04 MST"
+
+// isAdmin reports whether the message comes from the admin of the bot.
+func (b *Bot) isAdmin(message *tgbotapi.Message) bool {
+	return message.From != nil && message.From.ID == b.cfg.AdminID
+}
+
+// handleJobsCommand shows the status of the scheduled jobs to the admin.
+// Other users get the answer to an unknown command, so the command is not disclosed.
+func (b *Bot) handleJobsCommand(ctx context.Context, message *tgbotapi.Message) {
+	if !b.isAdmin(message) {
+		b.handleUnknownCommand(ctx, message)
+		return
+	}
+
+	states, err := b.jobStateRepo.GetJobStates(ctx)
+	if err != nil {
+		slog.Error("Failed to retrieve job states", "error", err)
+		b.sendErrorMessage(ctx, message.Chat.ID, "Failed to retrieve the jobs. Please try again later.")
+		return
+	}
+	b.sendMessage(ctx, message.Chat.ID, formatJobStates(states))
+}
+
+// formatJobStates describes the last and next run of every job.
+func formatJobStates(states []*m.JobState) string {
+	if len(states) == 0 {
+		return "No jobs have been scheduled yet."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Scheduled jobs:\n")
+	for _, state := range states {
+		sb.WriteString("\n" + state.Name + "\n")
+		switch {
+		case state.LastRunAt == nil:
+			sb.WriteString("Last run: never\n")
+		case state.LastError != "":
+			fmt.Fprintf(&sb, "Last run: %s (%s), failed: %s\n",
+				state.LastRunAt.UTC().Format(jobTimeLayout), state.LastDuration.Round(time.Millisecond), state.LastError)
+		default:
+			fmt.Fprintf(&sb, "Last run: %s (%s), succeeded\n",
+				state.LastRunAt.UTC().Format(jobTimeLayout), state.LastDuration.Round(time.Millisecond))
+		}
+		if state.NextRunAt.IsZero() {
+			sb.WriteString("Next run: never\n")
+		} else {
+			fmt.Fprintf(&sb, "Next run: %s\n", state.NextRunAt.UTC().Format(jobTimeLayout))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// handleServicesCommand shows the state of the supervised services to the admin.
+func (b *Bot) handleServicesCommand(ctx context.Context, message *tgbotapi.Message) {
+	if !b.isAdmin(message) {
+		b.handleUnknownCommand(ctx, message)
+		return
+	}
+
+	var statuses []supervisor.Status
+	if b.services != nil {
+		statuses = b.services.Statuses()
+	}
+	b.sendMessage(ctx, message.Chat.ID, formatServiceStatuses(statuses))
+}
+
+// formatServiceStatuses describes the state, the restarts and the last error of every service.
+func formatServiceStatuses(statuses []supervisor.Status) string {
+	if len(statuses) == 0 {
+		return "No services are supervised."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Services:\n")
+	for _, status := range statuses {
+		state := "restarting"
+		switch {
+		case status.Running:
+			state = "running"
+		case status.Stopped:
+			state = "stopped"
+		}
+		fmt.Fprintf(&sb, "\n%s: %s, %d restarts\n", status.Name, state, status.Restarts)
+		if status.LastError != "" {
+			fmt.Fprintf(&sb, "Last error: %s at %s\n", status.LastError, status.LastErrorAt.UTC().Format(jobTimeLayout))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -3,53 +3,108 @@ package telegram
 import (
 	"context"
 	"log/slog"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"github.com/movax01h/kladovkin-telegram-bot/config"
 	r "github.com/movax01h/kladovkin-telegram-bot/internal/repository"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/supervisor"
 )
 
+// updateTimeout bounds the handling of a single update, including the database calls it makes.
+const updateTimeout = 30 * time.Second
+
 type Bot struct {
 	cfg              config.TelegramConfig
-	api              *tgbotapi.BotAPI
+	api              *Scheduler
 	userRepo         r.UserRepository
 	unitRepo         r.UnitRepository
 	subscriptionRepo r.SubscriptionRepository
+	chatStateRepo    r.ChatStateRepository
+	channelRepo      r.DeliveryChannelRepository
+	jobStateRepo     r.JobStateRepository
+	undo             *undoStore
+	verifications    *verificationThrottle
+	services         ServiceMonitor
+	emailVerifier    EmailVerifier
+
+	receiveOnce sync.Once
+	updates     tgbotapi.UpdatesChannel
 }
 
-// NewBot creates a new Bot instance.
-func NewBot(cfg config.TelegramConfig, userRepo r.UserRepository, unitRepo r.UnitRepository, subscriptionRepo r.SubscriptionRepository) (*Bot, error) {
-	api, err := tgbotapi.NewBotAPI(cfg.BotToken)
-	if err != nil {
-		return nil, err
-	}
+// ServiceMonitor reports the states of the supervised services.
+type ServiceMonitor interface {
+	Statuses() []supervisor.Status
+}
 
+// NewBot creates a new Bot instance talking to Telegram through the client.
+// Every call goes through a Scheduler keeping the bot within the rate limits of Telegram.
+func NewBot(cfg config.TelegramConfig, api Client, userRepo r.UserRepository, unitRepo r.UnitRepository, subscriptionRepo r.SubscriptionRepository, chatStateRepo r.ChatStateRepository, channelRepo r.DeliveryChannelRepository, jobStateRepo r.JobStateRepository) *Bot {
 	return &Bot{
 		cfg:              cfg,
-		api:              api,
+		api:              NewScheduler(api, cfg),
 		userRepo:         userRepo,
 		unitRepo:         unitRepo,
 		subscriptionRepo: subscriptionRepo,
-	}, nil
+		chatStateRepo:    chatStateRepo,
+		channelRepo:      channelRepo,
+		jobStateRepo:     jobStateRepo,
+		undo:             newUndoStore(undoWindow),
+		verifications:    newVerificationThrottle(verificationInterval),
+	}
 }
 
-// Start begins polling for updates and handling messages.
-func (b *Bot) Start(ctx context.Context) error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+// EmailVerifier emails the codes confirming the email addresses of the users.
+type EmailVerifier interface {
+	SendVerificationCode(ctx context.Context, address, code string) error
+}
+
+// SetEmailVerifier enables the email delivery channel, whose addresses are confirmed through the verifier.
+// Without a verifier the users cannot set up email notifications.
+func (b *Bot) SetEmailVerifier(verifier EmailVerifier) {
+	b.emailVerifier = verifier
+}
+
+// SetServiceMonitor makes the states of the supervised services available to the admin.
+func (b *Bot) SetServiceMonitor(services ServiceMonitor) {
+	b.services = services
+}
 
-	updates := b.api.GetUpdatesChan(u)
+// Start begins polling for updates and handling messages and inline keyboard presses.
+// The bot can be started again after a failure, the updates are received until the context is done.
+func (b *Bot) Start(ctx context.Context) error {
+	b.receiveOnce.Do(func() {
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		b.updates = b.api.GetUpdatesChan(u)
+	})
 
 	for {
 		select {
-		case update := <-updates:
-			if update.Message != nil {
-				b.handleMessage(ctx, update.Message)
+		case update, ok := <-b.updates:
+			if !ok {
+				return nil
 			}
+			b.handleUpdate(ctx, update)
 		case <-ctx.Done():
 			slog.Info("Telegram bot is shutting down")
+			b.api.StopReceivingUpdates()
 			return ctx.Err()
 		}
 	}
 }
+
+// handleUpdate dispatches an update to its handler with a deadline.
+func (b *Bot) handleUpdate(ctx context.Context, update tgbotapi.Update) {
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	switch {
+	case update.Message != nil:
+		b.handleMessage(ctx, update.Message)
+	case update.CallbackQuery != nil:
+		b.handleCallbackQuery(ctx, update.CallbackQuery)
+	}
+}
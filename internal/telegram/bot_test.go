@@ -1 +1,1005 @@
 package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/notifier"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/memory"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/supervisor"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/telegram/telegramtest"
+)
+
+// testAdminID is the chat of the admin of the bots started by startTestBot.
+const testAdminID = 1
+
+// newTestBot creates a bot over in-memory repositories.
+// The bot has no API client, so only the paths that do not talk to Telegram can be tested.
+func newTestBot(store *memory.Store) *Bot {
+	repos := store.Repositories()
+	return &Bot{
+		userRepo:         repos.Users,
+		unitRepo:         repos.Units,
+		subscriptionRepo: repos.Subscriptions,
+		chatStateRepo:    repos.ChatStates,
+		channelRepo:      repos.DeliveryChannels,
+		jobStateRepo:     repos.JobStates,
+		undo:             newUndoStore(undoWindow),
+		verifications:    newVerificationThrottle(verificationInterval),
+	}
+}
+
+// startTestBot starts a bot over in-memory repositories talking to a fake Bot API server.
+// The options set up the bot before it starts. The bot stops when the test finishes.
+func startTestBot(t *testing.T, store *memory.Store, options ...func(b *Bot)) *telegramtest.Server {
+	t.Helper()
+
+	server := telegramtest.NewServer(t)
+	repos := store.Repositories()
+	cfg := config.TelegramConfig{BotToken: telegramtest.Token, AdminID: testAdminID, APIEndpoint: server.Endpoint()}
+	client, err := NewClient(cfg)
+	require.NoError(t, err)
+	b := NewBot(
+		cfg, client, repos.Users, repos.Units, repos.Subscriptions, repos.ChatStates, repos.DeliveryChannels,
+		repos.JobStates,
+	)
+	for _, option := range options {
+		option(b)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = b.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return server
+}
+
+// newTestQuery creates a callback query of a message in the chat.
+func newTestQuery(chatID int64, data string) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "query",
+		Data:    data,
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: chatID}},
+	}
+}
+
+func TestEncodeDecodeCallback(t *testing.T) {
+	// Act
+	data, err := decodeCallback(encodeCallback(actionCity, optionToken("Москва")))
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, actionCity, data.action)
+	assert.Equal(t, optionToken("Москва"), data.arg(0))
+	assert.Empty(t, data.arg(1))
+}
+
+func TestDecodeCallback_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected error
+	}{
+		{"empty", "", errMalformedCallback},
+		{"no action", "1:", errMalformedCallback},
+		{"free text of older releases", "Return", errMalformedCallback},
+		{"other version", "0:c:abc", errOutdatedCallback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCallback(tt.data)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestEncodeCallback_TooLong(t *testing.T) {
+	assert.Panics(t, func() { encodeCallback(actionCity, strings.Repeat("x", maxCallbackDataLength)) })
+}
+
+// TestFindOption tests that options are found by their token even if their names
+// collide with menu labels or contain the callback separator.
+func TestFindOption(t *testing.T) {
+	options := []string{"Return", "List Subscriptions", "Кладовкин: Ленинский", strings.Repeat("Очень длинное название ", 5)}
+
+	for _, option := range options {
+		t.Run(option, func(t *testing.T) {
+			data, err := decodeCallback(encodeCallback(actionStorage, optionToken(option)))
+			require.NoError(t, err)
+
+			found, ok := findOption(options, data.arg(0))
+			assert.True(t, ok)
+			assert.Equal(t, option, found)
+		})
+	}
+
+	_, ok := findOption(options, optionToken("Unknown"))
+	assert.False(t, ok)
+}
+
+func TestSelectionKeyboard(t *testing.T) {
+	// Act
+	keyboard := selectionKeyboard(actionCity, []string{"Москва", "Санкт-Петербург"})
+
+	// Assert
+	require.Len(t, keyboard.InlineKeyboard, 3)
+	assert.Equal(t, "Москва", keyboard.InlineKeyboard[0][0].Text)
+	assert.Equal(t, encodeCallback(actionCity, optionToken("Москва")), *keyboard.InlineKeyboard[0][0].CallbackData)
+	assert.Equal(t, buttonCancel, keyboard.InlineKeyboard[2][0].Text)
+	assert.Equal(t, encodeCallback(actionCancel), *keyboard.InlineKeyboard[2][0].CallbackData)
+}
+
+func TestUndoStore(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	newStore := func() *undoStore {
+		s := newUndoStore(time.Minute)
+		s.now = func() time.Time { return now }
+		return s
+	}
+	subscription := &m.Subscription{ID: 7, UserID: 1, City: "Москва"}
+
+	t.Run("should restore within the window once", func(t *testing.T) {
+		s := newStore()
+		s.put(100, subscription)
+
+		restored, ok := s.take(100, 7)
+		assert.True(t, ok)
+		assert.Equal(t, *subscription, restored)
+
+		_, ok = s.take(100, 7)
+		assert.False(t, ok)
+	})
+
+	t.Run("should not restore in another chat", func(t *testing.T) {
+		s := newStore()
+		s.put(100, subscription)
+
+		_, ok := s.take(200, 7)
+		assert.False(t, ok)
+	})
+
+	t.Run("should not restore after the window", func(t *testing.T) {
+		s := newStore()
+		s.put(100, subscription)
+		s.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+		_, ok := s.take(100, 7)
+		assert.False(t, ok)
+	})
+}
+
+func TestSubscriptionCardKeyboard(t *testing.T) {
+	b := &Bot{}
+
+	t.Run("active subscription can be paused", func(t *testing.T) {
+		keyboard := b.subscriptionCardKeyboard(&m.Subscription{ID: 7, Status: m.SubscriptionStatusActive})
+
+		require.Len(t, keyboard.InlineKeyboard, 2)
+		row := keyboard.InlineKeyboard[0]
+		require.Len(t, row, 3)
+		assert.Equal(t, buttonPause, row[0].Text)
+		assert.Equal(t, "1:p:7", *row[0].CallbackData)
+		assert.Equal(t, "1:e:7", *row[1].CallbackData)
+		assert.Equal(t, "1:d:7", *row[2].CallbackData)
+		assert.Equal(t, "1:dm:7", *keyboard.InlineKeyboard[1][0].CallbackData)
+		assert.Equal(t, "1:pa:7", *keyboard.InlineKeyboard[1][1].CallbackData)
+	})
+
+	t.Run("paused subscription can be resumed", func(t *testing.T) {
+		keyboard := b.subscriptionCardKeyboard(&m.Subscription{ID: 7, Status: m.SubscriptionStatusPaused})
+
+		assert.Equal(t, buttonResume, keyboard.InlineKeyboard[0][0].Text)
+		assert.Equal(t, "1:r:7", *keyboard.InlineKeyboard[0][0].CallbackData)
+	})
+}
+
+func TestCallbackSubscription(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	subscription := &m.Subscription{UserID: user.ID, City: "Москва", Status: m.SubscriptionStatusActive}
+	require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, subscription))
+	b := newTestBot(store)
+	data, err := decodeCallback(encodeCallback(actionPause, formatID(subscription.ID)))
+	require.NoError(t, err)
+
+	// Act
+	found, ok := b.callbackSubscription(ctx, newTestQuery(100, ""), data)
+
+	// Assert
+	require.True(t, ok)
+	assert.Equal(t, subscription.ID, found.ID)
+	assert.Equal(t, "Москва", found.City)
+}
+
+func TestWizardState(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	b := newTestBot(store)
+	state := &m.ChatState{ChatID: 100, Step: m.ChatStepStorage, City: "Москва"}
+	require.True(t, b.saveChatState(ctx, newTestQuery(100, ""), state))
+
+	// Act
+	found, ok := b.wizardState(ctx, newTestQuery(100, ""), m.ChatStepStorage)
+
+	// Assert
+	require.True(t, ok)
+	assert.Equal(t, "Москва", found.City)
+}
+
+func TestBot_Start(t *testing.T) {
+	// Arrange
+	store := memory.NewStore()
+	server := startTestBot(t, store)
+
+	// Act
+	server.SendMessage(100, "/start")
+
+	// Assert
+	menu := server.WaitForText(t, "sendMessage", "What would you like to do?")
+	assert.Equal(t, int64(100), menu.ChatID())
+	_, ok := menu.Button(buttonNewSubscription)
+	assert.True(t, ok)
+
+	user, err := store.Repositories().Users.GetByTelegramID(context.Background(), 100)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "Test", user.FirstName)
+}
+
+func TestBot_NewSubscriptionWithoutUnits(t *testing.T) {
+	// Arrange
+	store := memory.NewStore()
+	server := startTestBot(t, store)
+	server.SendMessage(100, "/start")
+	menu := server.WaitForText(t, "sendMessage", "What would you like to do?")
+
+	// Act
+	server.Press(t, menu, buttonNewSubscription)
+
+	// Assert
+	answer := server.WaitFor(t, "answerCallbackQuery", nil)
+	assert.Contains(t, answer.Text(), "No storages are known yet")
+	assert.Empty(t, server.Requests("editMessageText"))
+}
+
+func TestBot_UnknownCommand(t *testing.T) {
+	// Arrange
+	server := startTestBot(t, memory.NewStore())
+
+	// Act
+	server.SendMessage(100, "hello")
+
+	// Assert
+	reply := server.WaitFor(t, "sendMessage", nil)
+	assert.Contains(t, reply.Text(), "Unknown command")
+}
+
+func TestBot_BlockedUser(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100, Active: true}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	active := &m.Subscription{UserID: user.ID, City: "Москва", Status: m.SubscriptionStatusActive}
+	paused := &m.Subscription{UserID: user.ID, City: "Казань", Status: m.SubscriptionStatusPaused}
+	require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, active))
+	require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, paused))
+	server := telegramtest.NewServer(t)
+	server.FailMethod("sendMessage", telegramtest.Error{
+		Code: 403, Description: "Forbidden: bot was blocked by the user", Times: 1,
+	})
+	cfg := config.TelegramConfig{BotToken: telegramtest.Token}
+	b := NewBot(
+		cfg, server.NewClient(t), repos.Users, repos.Units, repos.Subscriptions, repos.ChatStates, repos.DeliveryChannels,
+		repos.JobStates,
+	)
+
+	// statuses returns whether the user is active and the statuses of the active and the paused subscription.
+	statuses := func() (bool, string, string) {
+		stored, err := repos.Users.GetUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		first, err := repos.Subscriptions.GetSubscriptionByID(ctx, active.ID)
+		require.NoError(t, err)
+		second, err := repos.Subscriptions.GetSubscriptionByID(ctx, paused.ID)
+		require.NoError(t, err)
+		return stored.Active, first.Status, second.Status
+	}
+
+	t.Run("should deactivate a user who blocked the bot", func(t *testing.T) {
+		// Act
+		err := b.SendNotification(ctx, 100, "A unit is available")
+
+		// Assert
+		assert.ErrorIs(t, err, notifier.ErrUndeliverable)
+		isActive, first, second := statuses()
+		assert.False(t, isActive)
+		assert.Equal(t, m.SubscriptionStatusSuspended, first)
+		assert.Equal(t, m.SubscriptionStatusPaused, second)
+	})
+
+	t.Run("should reactivate the user on /start", func(t *testing.T) {
+		// Act
+		b.handleStart(ctx, &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 100}})
+
+		// Assert
+		isActive, first, second := statuses()
+		assert.True(t, isActive)
+		assert.Equal(t, m.SubscriptionStatusActive, first)
+		assert.Equal(t, m.SubscriptionStatusPaused, second)
+		server.WaitForText(t, "sendMessage", "What would you like to do?")
+	})
+}
+
+func TestBot_SendErrorNotification(t *testing.T) {
+	// newAlertingBot creates a bot sending through the fake Bot API server, which fails sendMessage if asked to.
+	newAlertingBot := func(server *telegramtest.Server) *Bot {
+		repos := memory.NewStore().Repositories()
+		cfg := config.TelegramConfig{BotToken: telegramtest.Token, AdminID: testAdminID}
+		return NewBot(
+			cfg, server.NewClient(t), repos.Users, repos.Units, repos.Subscriptions, repos.ChatStates,
+			repos.DeliveryChannels, repos.JobStates,
+		)
+	}
+
+	t.Run("should send the error to the admin", func(t *testing.T) {
+		// Arrange
+		server := telegramtest.NewServer(t)
+		b := newAlertingBot(server)
+
+		// Act
+		err := b.SendErrorNotification(context.Background(), "Scraping has resumed")
+
+		// Assert
+		require.NoError(t, err)
+		request := server.WaitForText(t, "sendMessage", "Scraping has resumed")
+		assert.Equal(t, int64(testAdminID), request.ChatID())
+	})
+
+	t.Run("should stop waiting for its slot when the context is done", func(t *testing.T) {
+		// Arrange
+		server := telegramtest.NewServer(t)
+		server.FailMethod("sendMessage",
+			telegramtest.Error{Code: 429, Description: "Too Many Requests: retry after 30", RetryAfter: 30, Times: 1})
+		b := newAlertingBot(server)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		start := time.Now()
+		err := b.SendErrorNotification(ctx, "Scraping has resumed")
+
+		// Assert
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
+
+func TestBot_Settings(t *testing.T) {
+	// Arrange
+	store := memory.NewStore()
+	server := startTestBot(t, store)
+	server.SendMessage(100, "/start")
+	menu := server.WaitForText(t, "sendMessage", "What would you like to do?")
+
+	// Act
+	server.Press(t, menu, buttonSettings)
+	settings := server.WaitForText(t, "editMessageText", "Time zone: Moscow (UTC+3)")
+	server.Press(t, settings, buttonTimeZone)
+	zones := server.WaitForText(t, "editMessageText", "Select your time zone")
+	server.Press(t, zones, "Yekaterinburg (UTC+5)")
+	server.WaitForText(t, "editMessageText", "Time zone: Yekaterinburg (UTC+5)")
+	server.Press(t, settings, buttonQuietHours)
+	quiet := server.WaitForText(t, "editMessageText", "Select your quiet hours")
+	server.Press(t, quiet, "23:00–08:00")
+	server.WaitForText(t, "editMessageText", "Quiet hours: 23:00–08:00")
+	server.SendMessage(100, "/quiet 22:30-07:00")
+
+	// Assert
+	server.WaitForText(t, "sendMessage", "Quiet hours: 22:30–07:00")
+	user, err := store.Repositories().Users.GetByTelegramID(context.Background(), 100)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "Asia/Yekaterinburg", user.TimeZone)
+	assert.Equal(t, 22*60+30, user.QuietHoursStart)
+	assert.Equal(t, 7*60, user.QuietHoursEnd)
+}
+
+func TestBot_DeliveryMode(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100, Active: true}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	subscription := &m.Subscription{
+		UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+	}
+	require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, subscription))
+	server := startTestBot(t, store)
+	server.SendMessage(100, "/start")
+	menu := server.WaitForText(t, "sendMessage", "What would you like to do?")
+
+	// Act
+	server.Press(t, menu, buttonListSubscriptions)
+	card := server.WaitForText(t, "sendMessage", "Delivery: Instant")
+	server.Press(t, card, buttonDeliveryMode)
+	modes := server.WaitForText(t, "editMessageText", "How should the alerts be delivered?")
+	server.Press(t, modes, buttonWeeklyDigest)
+	days := server.WaitForText(t, "editMessageText", "On which day")
+	server.Press(t, days, "Friday")
+	times := server.WaitForText(t, "editMessageText", "At what time")
+	server.Press(t, times, "18:00")
+
+	// Assert
+	done := server.WaitForText(t, "editMessageText", "Delivery: Weekly digest on Friday at 18:00")
+	assert.Equal(t, card.MessageID(), done.MessageID())
+	stored, err := repos.Subscriptions.GetSubscriptionByID(ctx, subscription.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, m.DeliveryModeWeekly, stored.DeliveryMode)
+	assert.Equal(t, time.Friday, stored.DigestWeekday)
+	assert.Equal(t, 18*60, stored.DigestTime)
+}
+
+func TestBot_PriceAlerts(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	user := &m.User{TelegramID: 100, Active: true}
+	require.NoError(t, repos.Users.CreateUser(ctx, user))
+	subscription := &m.Subscription{
+		UserID: user.ID, City: "Москва", Storage: "Ленинский", UnitSize: "2 м²", Status: m.SubscriptionStatusActive,
+	}
+	require.NoError(t, repos.Subscriptions.CreateSubscription(ctx, subscription))
+	server := startTestBot(t, store)
+	server.SendMessage(100, "/start")
+	menu := server.WaitForText(t, "sendMessage", "What would you like to do?")
+
+	// Act
+	server.Press(t, menu, buttonListSubscriptions)
+	card := server.WaitForText(t, "sendMessage", "Price alerts: off")
+	server.Press(t, card, buttonPriceAlerts)
+	rules := server.WaitForText(t, "editMessageText", "Which price changes should be announced?")
+	server.Press(t, rules, "Drop of 10%")
+	server.WaitForText(t, "editMessageText", "Price alerts: drops of 10% or more")
+	server.Press(t, rules, buttonMaxPrice)
+	server.WaitForText(t, "editMessageText", "Send the maximum monthly price")
+	server.SendMessage(100, "много")
+	server.WaitForText(t, "sendMessage", "Please send the price as a number")
+	server.SendMessage(100, "4 500")
+
+	// Assert
+	server.WaitForText(t, "sendMessage", "Price alerts: up to 4500 ₽/month, drops of 10% or more")
+	stored, err := repos.Subscriptions.GetSubscriptionByID(ctx, subscription.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, 4500.0, stored.MaxPrice)
+	assert.Equal(t, 10, stored.MinPriceDrop)
+	state, err := repos.ChatStates.GetChatState(ctx, 100)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+		ok       bool
+	}{
+		{"5000", 5000, true},
+		{" 5 000 ₽", 5000, true},
+		{"4999,90", 4999.9, true},
+		{"0", 0, false},
+		{"-100", 0, false},
+		{"пять", 0, false},
+		{"NaN", 0, false},
+		{"nan", 0, false},
+		{"Inf", 0, false},
+		{"-Inf", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			price, ok := parsePrice(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, price)
+		})
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedStart int
+		expectedEnd   int
+		expectedOK    bool
+	}{
+		{"23:00-08:00", 23 * 60, 8 * 60, true},
+		{"13:00 – 14:30", 13 * 60, 14*60 + 30, true},
+		{"off", 0, 0, true},
+		{"08:00-08:00", 0, 0, false},
+		{"23:00", 0, 0, false},
+		{"late-early", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			start, end, ok := parseQuietHours(tt.input)
+
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expectedStart, start)
+			assert.Equal(t, tt.expectedEnd, end)
+		})
+	}
+}
+
+func TestBot_Jobs(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	lastRunAt := time.Date(2024, 6, 7, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, store.Repositories().JobStates.SaveJobState(ctx, &m.JobState{
+		Name: "parse", LastRunAt: &lastRunAt, LastDuration: 3 * time.Second, NextRunAt: lastRunAt.Add(30 * time.Minute),
+	}))
+	server := startTestBot(t, store)
+
+	// Act
+	server.SendMessage(100, "/jobs")
+	server.SendMessage(testAdminID, "/jobs")
+
+	// Assert
+	refused := server.WaitFor(t, "sendMessage", func(r telegramtest.Request) bool { return r.ChatID() == 100 })
+	assert.Contains(t, refused.Text(), "Unknown command", "only the admin sees the jobs")
+	jobs := server.WaitForText(t, "sendMessage", "Scheduled jobs:")
+	assert.Equal(t, int64(testAdminID), jobs.ChatID())
+	assert.Contains(t, jobs.Text(), "Next run: 2024-06-07 10:30 UTC")
+}
+
+func TestStorageOptions(t *testing.T) {
+	// Arrange
+	storages := []m.Storage{
+		{Provider: "kladovkin", Name: "Бутово"},
+		{Provider: "kladovkin", Name: "Ленинский"},
+		{Provider: "other", Name: "Ленинский"},
+	}
+
+	// Act
+	options := storageOptions(storages)
+	storage, found := findStorage(storages, optionToken("Ленинский (other)"))
+
+	// Assert
+	assert.Equal(t, []string{"Бутово", "Ленинский (kladovkin)", "Ленинский (other)"}, options)
+	require.True(t, found)
+	assert.Equal(t, m.Storage{Provider: "other", Name: "Ленинский"}, storage)
+}
+
+func TestSubscriptionStatusText(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		expected string
+	}{
+		{"active", m.SubscriptionStatusActive, "Active"},
+		{"paused", m.SubscriptionStatusPaused, "Paused"},
+		{"suspended", m.SubscriptionStatusSuspended, "Suspended while the bot could not reach you"},
+		{"unknown", "archived", "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run("should describe the "+tt.name+" status", func(t *testing.T) {
+			// Act
+			text := subscriptionStatusText(tt.status)
+
+			// Assert
+			assert.Equal(t, tt.expected, text)
+		})
+	}
+}
+
+func TestFormatJobStates(t *testing.T) {
+	// Arrange
+	lastRunAt := time.Date(2024, 6, 7, 3, 30, 0, 0, time.UTC)
+	states := []*m.JobState{
+		{Name: "backup", LastRunAt: &lastRunAt, LastDuration: 1500 * time.Millisecond, LastError: "disk full"},
+		{Name: "parse", NextRunAt: lastRunAt.Add(time.Hour)},
+	}
+
+	// Act
+	text := formatJobStates(states)
+
+	// Assert
+	assert.Equal(t, "Scheduled jobs:\n"+
+		"\n"+
+		"backup\n"+
+		"Last run: 2024-06-07 03:30 UTC (1.5s), failed: disk full\n"+
+		"Next run: never\n"+
+		"\n"+
+		"parse\n"+
+		"Last run: never\n"+
+		"Next run: 2024-06-07 04:30 UTC", text)
+	assert.Equal(t, "No jobs have been scheduled yet.", formatJobStates(nil))
+}
+
+func TestFormatServiceStatuses(t *testing.T) {
+	// Arrange
+	failedAt := time.Date(2024, 6, 7, 3, 30, 0, 0, time.UTC)
+	statuses := []supervisor.Status{
+		{Name: "scheduler", Running: true},
+		{Name: "event bus", Restarts: 1, LastError: "database is locked", LastErrorAt: failedAt},
+		{Name: "notifier", Stopped: true, Restarts: 10, LastError: "panic: nil map", LastErrorAt: failedAt},
+	}
+
+	// Act
+	text := formatServiceStatuses(statuses)
+
+	// Assert
+	assert.Equal(t, "Services:\n"+
+		"\n"+
+		"scheduler: running, 0 restarts\n"+
+		"\n"+
+		"event bus: restarting, 1 restarts\n"+
+		"Last error: database is locked at 2024-06-07 03:30 UTC\n"+
+		"\n"+
+		"notifier: stopped, 10 restarts\n"+
+		"Last error: panic: nil map at 2024-06-07 03:30 UTC", text)
+	assert.Equal(t, "No services are supervised.", formatServiceStatuses(nil))
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorKind
+	}{
+		{"network", errors.New("connection reset by peer"), ErrorKindTemporary},
+		{"rate limited", &tgbotapi.Error{Code: 429, Message: "Too Many Requests: retry after 5"}, ErrorKindRateLimited},
+		{"blocked", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, ErrorKindUnreachable},
+		{"chat not found", &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, ErrorKindUnreachable},
+		{"deactivated", &tgbotapi.Error{Code: 403, Message: "Forbidden: user is deactivated"}, ErrorKindUnreachable},
+		{"rejected", &tgbotapi.Error{Code: 400, Message: "Bad Request: message is too long"}, ErrorKindRejected},
+		{"internal", &tgbotapi.Error{Code: 500, Message: "Internal Server Error"}, ErrorKindTemporary},
+		{"wrapped", fmt.Errorf("failed: %w", &tgbotapi.Error{Code: 403, Message: "Forbidden: user is deactivated"}), ErrorKindUnreachable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyError(tt.err))
+		})
+	}
+}
+
+// recordingVerifier records the verification codes instead of emailing them.
+type recordingVerifier struct {
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (v *recordingVerifier) SendVerificationCode(_ context.Context, address, code string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.codes == nil {
+		v.codes = make(map[string]string)
+	}
+	v.codes[address] = code
+	return nil
+}
+
+func (v *recordingVerifier) code(address string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.codes[address]
+}
+
+func TestBot_DeliveryChannels(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	verifier := &recordingVerifier{}
+	server := startTestBot(t, store, func(b *Bot) { b.SetEmailVerifier(verifier) })
+	server.SendMessage(100, "/start")
+	menu := server.WaitForText(t, "sendMessage", "What would you like to do?")
+
+	// Act
+	server.SendMessage(100, "/email not-an-address")
+	server.WaitForText(t, "sendMessage", "is not a valid email address")
+	server.SendMessage(100, "/email User <user@example.com>")
+	pending := server.WaitForText(t, "sendMessage", "A confirmation code has been emailed to user@example.com")
+	assert.Contains(t, pending.Text(), "Email: unconfirmed (user@example.com)")
+	server.SendMessage(100, "/verify WRONG")
+	server.WaitForText(t, "sendMessage", "This code is not valid")
+	server.SendMessage(100, "/verify "+strings.ToLower(verifier.code("user@example.com")))
+	channels := server.WaitForText(t, "sendMessage", "Email notifications will be sent to user@example.com")
+	server.Press(t, channels, "Telegram: on")
+	server.WaitForText(t, "answerCallbackQuery", "Telegram: off")
+	server.Press(t, menu, buttonChannels)
+
+	// Assert
+	listed := server.WaitFor(t, "editMessageText", func(r telegramtest.Request) bool {
+		return r.MessageID() == menu.MessageID()
+	})
+	assert.Contains(t, listed.Text(), "Email: on (user@example.com)")
+	_, ok := listed.Button("Webhook: off")
+	assert.True(t, ok)
+
+	user, err := repos.Users.GetByTelegramID(ctx, 100)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	stored, err := repos.DeliveryChannels.GetDeliveryChannels(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, m.ChannelEmail, stored[0].Type)
+	assert.Equal(t, "user@example.com", stored[0].Address)
+	assert.True(t, stored[0].Enabled)
+	assert.True(t, stored[0].Confirmed())
+	assert.Equal(t, m.ChannelTelegram, stored[1].Type)
+	assert.False(t, stored[1].Enabled)
+}
+
+func TestBot_EmailUnavailable(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := memory.NewStore()
+	server := startTestBot(t, store)
+	server.SendMessage(100, "/start")
+	server.WaitForText(t, "sendMessage", "What would you like to do?")
+
+	// Act
+	server.SendMessage(100, "/email user@example.com")
+
+	// Assert
+	server.WaitForText(t, "sendMessage", "Email notifications are not available.")
+	user, err := store.Repositories().Users.GetByTelegramID(ctx, 100)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	stored, err := store.Repositories().DeliveryChannels.GetDeliveryChannels(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func TestBot_EmailThrottled(t *testing.T) {
+	// Arrange
+	verifier := &recordingVerifier{}
+	server := startTestBot(t, memory.NewStore(), func(b *Bot) { b.SetEmailVerifier(verifier) })
+	server.SendMessage(100, "/start")
+	server.WaitForText(t, "sendMessage", "What would you like to do?")
+	server.SendMessage(100, "/email user@example.com")
+	server.WaitForText(t, "sendMessage", "A confirmation code has been emailed to user@example.com")
+
+	// Act
+	server.SendMessage(100, "/email other@example.com")
+
+	// Assert
+	server.WaitForText(t, "sendMessage", "A confirmation email was sent recently. Please try again in 5m0s.")
+	assert.Empty(t, verifier.code("other@example.com"))
+}
+
+func TestVerificationThrottle(t *testing.T) {
+	now := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	newThrottle := func() *verificationThrottle {
+		throttle := newVerificationThrottle(time.Minute)
+		throttle.now = func() time.Time { return now }
+		return throttle
+	}
+
+	t.Run("should allow one email per user within the interval", func(t *testing.T) {
+		throttle := newThrottle()
+		_, ok := throttle.reserve(1, "user@example.com")
+		assert.True(t, ok)
+
+		wait, ok := throttle.reserve(1, "other@example.com")
+		assert.False(t, ok)
+		assert.Equal(t, time.Minute, wait)
+	})
+
+	t.Run("should allow one email per address within the interval", func(t *testing.T) {
+		throttle := newThrottle()
+		_, ok := throttle.reserve(1, "user@example.com")
+		assert.True(t, ok)
+
+		_, ok = throttle.reserve(2, "USER@example.com")
+		assert.False(t, ok)
+	})
+
+	t.Run("should allow the next email after the interval", func(t *testing.T) {
+		throttle := newThrottle()
+		_, ok := throttle.reserve(1, "user@example.com")
+		assert.True(t, ok)
+		throttle.now = func() time.Time { return now.Add(time.Minute) }
+
+		_, ok = throttle.reserve(1, "user@example.com")
+		assert.True(t, ok)
+	})
+}
+
+func TestNewVerificationCode(t *testing.T) {
+	code, err := newVerificationCode()
+	require.NoError(t, err)
+	assert.Len(t, code, verificationCodeLength)
+	for _, c := range code {
+		assert.Contains(t, verificationAlphabet, string(c))
+	}
+	other, err := newVerificationCode()
+	require.NoError(t, err)
+	assert.NotEqual(t, code, other)
+}
+
+func TestParseChannelAddress(t *testing.T) {
+	tests := []struct {
+		name        string
+		channelType m.ChannelType
+		address     string
+		expected    string
+		valid       bool
+	}{
+		{"email", m.ChannelEmail, "user@example.com", "user@example.com", true},
+		{"email with a name", m.ChannelEmail, "User <user@example.com>", "user@example.com", true},
+		{"invalid email", m.ChannelEmail, "user", "", false},
+		{"https webhook", m.ChannelWebhook, "https://example.com/hook?token=1", "https://example.com/hook?token=1", true},
+		{"webhook without scheme", m.ChannelWebhook, "example.com/hook", "", false},
+		{"webhook of another scheme", m.ChannelWebhook, "ftp://example.com/hook", "", false},
+		{"webhook on loopback", m.ChannelWebhook, "http://127.0.0.1:8080/hook", "", false},
+		{"webhook on localhost", m.ChannelWebhook, "http://localhost/hook", "", false},
+		{"webhook on a private network", m.ChannelWebhook, "http://10.0.0.5/hook", "", false},
+		{"webhook on the metadata endpoint", m.ChannelWebhook, "http://169.254.169.254/latest/meta-data", "", false},
+		{"webhook on IPv6 loopback", m.ChannelWebhook, "http://[::1]/hook", "", false},
+		{"telegram", m.ChannelTelegram, "100", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, valid := parseChannelAddress(tt.channelType, tt.address)
+			assert.Equal(t, tt.valid, valid)
+			assert.Equal(t, tt.expected, address)
+		})
+	}
+}
+
+// fakeClock is the time of a Scheduler, sleeping advances it.
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// newTestScheduler creates a Scheduler on a fake clock sending through a client of the fake Bot API server.
+func newTestScheduler(t *testing.T, server *telegramtest.Server, cfg config.TelegramConfig) (*Scheduler, *fakeClock) {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewScheduler(server.NewClient(t), cfg)
+	s.now = clock.Now
+	s.sleep = clock.Sleep
+	return s, clock
+}
+
+func TestScheduler_Reserve(t *testing.T) {
+	// Arrange
+	s, clock := newTestScheduler(t, telegramtest.NewServer(t), config.TelegramConfig{RateLimit: 10, ChatRateLimit: 20})
+
+	// Act
+	waits := []time.Duration{
+		s.reserve(1), s.reserve(1), s.reserve(1), s.reserve(1), // The fourth message exceeds the burst of the chat
+		s.reserve(2),
+		s.reserve(0),
+	}
+
+	// Assert
+	assert.Equal(t, []time.Duration{
+		0, 100 * time.Millisecond, 200 * time.Millisecond, 3 * time.Second,
+		3100 * time.Millisecond,
+		3200 * time.Millisecond,
+	}, waits)
+	assert.Empty(t, clock.slept)
+}
+
+func TestScheduler_RetryAfter(t *testing.T) {
+	tooManyRequests := telegramtest.Error{Code: 429, Description: "Too Many Requests: retry after 2", RetryAfter: 2}
+
+	t.Run("should resend a message after the period Telegram asks to wait", func(t *testing.T) {
+		// Arrange
+		server := telegramtest.NewServer(t)
+		tooManyRequests.Times = 1
+		server.FailMethod("sendMessage", tooManyRequests)
+		s, clock := newTestScheduler(t, server, config.TelegramConfig{})
+
+		// Act
+		message, err := s.Send(tgbotapi.NewMessage(100, "Hello"))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", message.Text)
+		assert.Len(t, server.Requests("sendMessage"), 2)
+		assert.Equal(t, []time.Duration{0, 2 * time.Second}, clock.slept)
+	})
+
+	t.Run("should return the error when Telegram keeps asking to wait", func(t *testing.T) {
+		// Arrange
+		server := telegramtest.NewServer(t)
+		tooManyRequests.Times = 0
+		server.FailMethod("sendMessage", tooManyRequests)
+		s, _ := newTestScheduler(t, server, config.TelegramConfig{})
+
+		// Act
+		_, err := s.Send(tgbotapi.NewMessage(100, "Hello"))
+
+		// Assert
+		var apiErr *tgbotapi.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 429, apiErr.Code)
+		assert.Len(t, server.Requests("sendMessage"), maxRateLimitRetries+1)
+	})
+
+	t.Run("should stop waiting when the context is done", func(t *testing.T) {
+		// Arrange
+		server := telegramtest.NewServer(t)
+		server.FailMethod("sendMessage",
+			telegramtest.Error{Code: 429, Description: "Too Many Requests: retry after 30", RetryAfter: 30, Times: 1})
+		s, _ := newTestScheduler(t, server, config.TelegramConfig{})
+		s.sleep = sleep
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// Act
+		start := time.Now()
+		_, err := s.SendContext(ctx, tgbotapi.NewMessage(100, "Hello"))
+
+		// Assert
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		assert.Len(t, server.Requests("sendMessage"), 1)
+	})
+
+	t.Run("should not resend on other errors", func(t *testing.T) {
+		// Arrange
+		server := telegramtest.NewServer(t)
+		server.FailMethod("sendMessage", telegramtest.Error{Code: 403, Description: "Forbidden: bot was blocked by the user"})
+		s, _ := newTestScheduler(t, server, config.TelegramConfig{})
+
+		// Act
+		_, err := s.Send(tgbotapi.NewMessage(100, "Hello"))
+
+		// Assert
+		assert.Error(t, err)
+		assert.Len(t, server.Requests("sendMessage"), 1)
+	})
+}
@@ -0,0 +1,138 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// Callback data is encoded as "<version>:<action>[:<arg>...]".
+// The version is bumped whenever the meaning of the actions or their arguments changes,
+// so buttons of messages sent by an older release are recognized as outdated instead of being misread.
+const (
+	callbackVersion   = "1"
+	callbackSeparator = ":"
+
+	// maxCallbackDataLength is the limit the Telegram Bot API imposes on callback data.
+	maxCallbackDataLength = 64
+)
+
+// Callback actions.
+const (
+	actionMainMenu          = "m"  // Show the main menu
+	actionNewSubscription   = "n"  // Start the subscription wizard
+	actionListSubscriptions = "l"  // List the subscriptions of the user
+	actionCity              = "c"  // Select a city, args: option token
+	actionStorage           = "s"  // Select a storage, args: option token
+	actionUnitSize          = "u"  // Select a unit size, args: option token
+	actionConfirm           = "ok" // Confirm the subscription
+	actionCancel            = "x"  // Cancel the wizard
+	actionPause             = "p"  // Pause a subscription, args: subscription ID
+	actionResume            = "r"  // Resume a subscription, args: subscription ID
+	actionEdit              = "e"  // Edit a subscription in the wizard, args: subscription ID
+	actionDelete            = "d"  // Delete a subscription, args: subscription ID
+	actionUndoDelete        = "z"  // Restore a deleted subscription, args: subscription ID
+	actionChannels          = "ch" // Show the delivery channels of the user
+	actionToggleChannel     = "t"  // Turn a delivery channel on or off, args: channel type
+	actionSettings          = "st" // Show the settings of the user
+	actionTimeZone          = "tz" // Show the time zones, or set the time zone, args: [IANA name]
+	actionQuietHours        = "q"  // Show the quiet hours, or set them, args: ["<start>-<end>" or "off"]
+	actionDeliveryMode      = "dm" // Choose the delivery mode, args: subscription ID, [mode], [weekday], [time]
+	actionShowSubscription  = "v"  // Show a subscription card, args: subscription ID
+	actionPriceAlerts       = "pa" // Show or change the price rules, args: subscription ID, [rule], [value]
+)
+
+var (
+	// errMalformedCallback is returned for callback data that cannot be decoded.
+	errMalformedCallback = errors.New("malformed callback data")
+	// errOutdatedCallback is returned for callback data of another encoding version.
+	errOutdatedCallback = errors.New("outdated callback data")
+)
+
+// callbackData is the decoded data of an inline keyboard button.
+type callbackData struct {
+	action string
+	args   []string
+}
+
+// arg returns the i-th argument, or an empty string if there is none.
+func (d callbackData) arg(i int) string {
+	if i < len(d.args) {
+		return d.args[i]
+	}
+	return ""
+}
+
+// encodeCallback encodes the action and its arguments into callback data.
+// It panics if the result exceeds the Telegram limit, since that is a programming error.
+func encodeCallback(action string, args ...string) string {
+	parts := append([]string{callbackVersion, action}, args...)
+	data := strings.Join(parts, callbackSeparator)
+	if len(data) > maxCallbackDataLength {
+		panic(fmt.Sprintf("callback data %q exceeds %d bytes", data, maxCallbackDataLength))
+	}
+	return data
+}
+
+// decodeCallback decodes callback data created by encodeCallback.
+func decodeCallback(data string) (callbackData, error) {
+	parts := strings.Split(data, callbackSeparator)
+	if len(parts) < 2 || parts[1] == "" {
+		return callbackData{}, errMalformedCallback
+	}
+	if parts[0] != callbackVersion {
+		return callbackData{}, errOutdatedCallback
+	}
+	return callbackData{action: parts[1], args: parts[2:]}, nil
+}
+
+// optionToken returns a short token identifying an option such as a city name.
+// Names are not put into callback data directly, because they may contain the separator
+// and easily exceed the callback data limit.
+func optionToken(option string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(option))
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
+
+// storageOptions returns the labels of the storages.
+// A storage name offered by several providers is followed by the provider, so the labels stay unique.
+func storageOptions(storages []m.Storage) []string {
+	providers := make(map[string]int, len(storages))
+	for _, storage := range storages {
+		providers[storage.Name]++
+	}
+
+	options := make([]string, len(storages))
+	for i, storage := range storages {
+		options[i] = storage.Name
+		if providers[storage.Name] > 1 {
+			options[i] = fmt.Sprintf("%s (%s)", storage.Name, storage.Provider)
+		}
+	}
+	return options
+}
+
+// findStorage returns the storage whose label is identified by the token.
+func findStorage(storages []m.Storage, token string) (m.Storage, bool) {
+	for i, option := range storageOptions(storages) {
+		if optionToken(option) == token {
+			return storages[i], true
+		}
+	}
+	return m.Storage{}, false
+}
+
+// findOption returns the option identified by the token.
+func findOption(options []string, token string) (string, bool) {
+	for _, option := range options {
+		if optionToken(option) == token {
+			return option, true
+		}
+	}
+	return "", false
+}
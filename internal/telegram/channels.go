@@ -0,0 +1,340 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/netguard"
+)
+
+// The delivery channels menu shows how the notifications reach the user and turns the channels on and off.
+// Email and webhooks need an address, which is set with the /email and /webhook commands.
+// An email address receives notifications only after the user sends back the code emailed to it with /verify,
+// so the bot cannot be made to email third parties. The confirmation emails are throttled per user and per address.
+
+// verificationCodeLength is the length of the codes confirming the email addresses.
+const verificationCodeLength = 10
+
+// verificationAlphabet are the characters of the codes, without the ones easily mistaken for each other.
+const verificationAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// channelNames are the names of the channel types shown to the users.
+var channelNames = map[m.ChannelType]string{
+	m.ChannelTelegram: "Telegram",
+	m.ChannelEmail:    "Email",
+	m.ChannelWebhook:  "Webhook",
+}
+
+// channelAddresses describe the address each channel needs.
+var channelAddresses = map[m.ChannelType]string{
+	m.ChannelEmail:   "email address",
+	m.ChannelWebhook: "http(s) URL",
+}
+
+// channelCommands are the commands setting up the channels that need an address.
+var channelCommands = map[m.ChannelType]string{
+	m.ChannelEmail:   "/email",
+	m.ChannelWebhook: "/webhook",
+}
+
+func (b *Bot) handleListChannels(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	user, ok := b.callbackUser(ctx, query)
+	if !ok {
+		return
+	}
+
+	channels, err := b.channelRepo.GetDeliveryChannels(ctx, user.ID)
+	if err != nil {
+		slog.Error("Failed to retrieve delivery channels", "userID", user.ID, "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving delivery channels. Please try again later.")
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "")
+	b.editMessage(ctx, query.Message, channelsText(channels), b.channelsKeyboard(channels))
+}
+
+// handleToggleChannel turns a delivery channel on or off.
+func (b *Bot) handleToggleChannel(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	channelType := m.ChannelType(data.arg(0))
+	if _, known := channelNames[channelType]; !known {
+		b.answerCallback(ctx, query.ID, "Unknown delivery channel.")
+		return
+	}
+
+	user, ok := b.callbackUser(ctx, query)
+	if !ok {
+		return
+	}
+
+	channels, err := b.channelRepo.GetDeliveryChannels(ctx, user.ID)
+	if err != nil {
+		slog.Error("Failed to retrieve delivery channels", "userID", user.ID, "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving delivery channels. Please try again later.")
+		return
+	}
+
+	channel := findChannel(channels, channelType)
+	if channel == nil {
+		if channelType != m.ChannelTelegram {
+			b.answerCallback(ctx, query.ID, fmt.Sprintf("Send %s <address> to set it up.", channelCommands[channelType]))
+			return
+		}
+		// Telegram is on until the user turns it off
+		channel = &m.DeliveryChannel{UserID: user.ID, Type: m.ChannelTelegram, Enabled: true}
+		channels = append(channels, channel)
+	}
+
+	channel.Enabled = !channel.Enabled
+	if err := b.channelRepo.SaveDeliveryChannel(ctx, channel); err != nil {
+		slog.Error("Failed to save delivery channel", "userID", user.ID, "channel", channelType, "error", err)
+		b.answerCallback(ctx, query.ID, "Error saving the delivery channel. Please try again later.")
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, fmt.Sprintf("%s: %s", channelNames[channelType], channelStatus(channel)))
+	b.editMessage(ctx, query.Message, channelsText(channels), b.channelsKeyboard(channels))
+}
+
+// handleChannelCommand sets the address of a delivery channel, or removes the channel for "off".
+func (b *Bot) handleChannelCommand(ctx context.Context, message *tgbotapi.Message, channelType m.ChannelType) {
+	chatID := message.Chat.ID
+	command := channelCommands[channelType]
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		b.sendMessage(ctx, chatID, fmt.Sprintf("Usage: %s <address>, or %s off to stop the notifications.", command, command))
+		return
+	}
+
+	user, err := b.userRepo.GetByTelegramID(ctx, chatID)
+	if err != nil {
+		slog.Error("Failed to retrieve user", "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error retrieving user. Please try again later.")
+		return
+	}
+	if user == nil {
+		b.sendMessage(ctx, chatID, "Please start the bot with /start first.")
+		return
+	}
+
+	if channelType == m.ChannelEmail && b.emailVerifier == nil && !strings.EqualFold(arg, "off") {
+		b.sendMessage(ctx, chatID, "Email notifications are not available.")
+		return
+	}
+
+	var text string
+	if strings.EqualFold(arg, "off") {
+		if err := b.channelRepo.DeleteDeliveryChannel(ctx, user.ID, channelType); err != nil {
+			slog.Error("Failed to delete delivery channel", "userID", user.ID, "channel", channelType, "error", err)
+			b.sendErrorMessage(ctx, chatID, "Error saving the delivery channel. Please try again later.")
+			return
+		}
+		text = fmt.Sprintf("%s notifications are turned off.", channelNames[channelType])
+	} else {
+		address, valid := parseChannelAddress(channelType, arg)
+		if !valid {
+			b.sendMessage(ctx, chatID, fmt.Sprintf("%q is not a valid %s.", arg, channelAddresses[channelType]))
+			return
+		}
+		channel := &m.DeliveryChannel{UserID: user.ID, Type: channelType, Address: address, Enabled: true}
+		if channelType == m.ChannelEmail {
+			// Checked before the new code replaces the one emailed last time
+			if wait, ok := b.verifications.reserve(user.ID, address); !ok {
+				b.sendMessage(ctx, chatID, fmt.Sprintf("A confirmation email was sent recently. "+
+					"Please try again in %s.", wait.Round(time.Second)))
+				return
+			}
+			if channel.VerificationCode, err = newVerificationCode(); err != nil {
+				slog.Error("Failed to generate the verification code", "userID", user.ID, "error", err)
+				b.sendErrorMessage(ctx, chatID, "Error saving the delivery channel. Please try again later.")
+				return
+			}
+		}
+		if err := b.channelRepo.SaveDeliveryChannel(ctx, channel); err != nil {
+			slog.Error("Failed to save delivery channel", "userID", user.ID, "channel", channelType, "error", err)
+			b.sendErrorMessage(ctx, chatID, "Error saving the delivery channel. Please try again later.")
+			return
+		}
+		text = fmt.Sprintf("%s notifications will be sent to %s.", channelNames[channelType], address)
+		if !channel.Confirmed() {
+			if err := b.emailVerifier.SendVerificationCode(ctx, address, channel.VerificationCode); err != nil {
+				slog.Error("Failed to send the verification code", "userID", user.ID, "error", err)
+				b.sendErrorMessage(ctx, chatID, "Error sending the confirmation email. Please try again later.")
+				return
+			}
+			text = fmt.Sprintf("A confirmation code has been emailed to %s. "+
+				"Send /verify <code> to receive the notifications there.", address)
+		}
+	}
+
+	b.sendChannels(ctx, chatID, user.ID, text)
+}
+
+// handleVerifyCommand confirms the email address of the user with the code emailed to it.
+func (b *Bot) handleVerifyCommand(ctx context.Context, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	code := strings.ToUpper(strings.TrimSpace(message.CommandArguments()))
+	if code == "" {
+		b.sendMessage(ctx, chatID, "Usage: /verify <code>, with the code emailed to your address.")
+		return
+	}
+
+	user, err := b.userRepo.GetByTelegramID(ctx, chatID)
+	if err != nil {
+		slog.Error("Failed to retrieve user", "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error retrieving user. Please try again later.")
+		return
+	}
+	if user == nil {
+		b.sendMessage(ctx, chatID, "Please start the bot with /start first.")
+		return
+	}
+
+	channels, err := b.channelRepo.GetDeliveryChannels(ctx, user.ID)
+	if err != nil {
+		slog.Error("Failed to retrieve delivery channels", "userID", user.ID, "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error retrieving delivery channels. Please try again later.")
+		return
+	}
+	channel := findChannel(channels, m.ChannelEmail)
+	if channel == nil || channel.Confirmed() {
+		b.sendMessage(ctx, chatID, "There is no email address waiting for confirmation.")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(channel.VerificationCode)) != 1 {
+		b.sendMessage(ctx, chatID, "This code is not valid. Please check the email and try again.")
+		return
+	}
+
+	channel.VerificationCode = ""
+	if err := b.channelRepo.SaveDeliveryChannel(ctx, channel); err != nil {
+		slog.Error("Failed to save delivery channel", "userID", user.ID, "channel", channel.Type, "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error saving the delivery channel. Please try again later.")
+		return
+	}
+	b.sendChannels(ctx, chatID, user.ID, fmt.Sprintf("Email notifications will be sent to %s.", channel.Address))
+}
+
+// sendChannels sends the text followed by the delivery channels of the user and their keyboard.
+func (b *Bot) sendChannels(ctx context.Context, chatID, userID int64, text string) {
+	channels, err := b.channelRepo.GetDeliveryChannels(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to retrieve delivery channels", "userID", userID, "error", err)
+		b.sendMessage(ctx, chatID, text)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, text+"\n\n"+channelsText(channels))
+	msg.ReplyMarkup = b.channelsKeyboard(channels)
+	if _, err := b.api.SendContext(ctx, msg); err != nil {
+		slog.Error("Failed to send delivery channels", "chatID", chatID, "error", err)
+	}
+}
+
+// parseChannelAddress validates the address of a delivery channel and returns it in its canonical form.
+func parseChannelAddress(channelType m.ChannelType, address string) (string, bool) {
+	switch channelType {
+	case m.ChannelEmail:
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return "", false
+		}
+		return parsed.Address, true
+	case m.ChannelWebhook:
+		parsed, err := url.Parse(address)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return "", false
+		}
+		// The bot must not be made to call the hosts of its own network
+		if netguard.CheckHost(parsed.Hostname()) != nil {
+			return "", false
+		}
+		return parsed.String(), true
+	default:
+		return "", false
+	}
+}
+
+// channelsText describes the delivery channels of a user.
+func channelsText(channels []*m.DeliveryChannel) string {
+	var b strings.Builder
+	b.WriteString("Delivery channels:\n")
+	for _, channelType := range m.ChannelTypes {
+		channel := findChannel(channels, channelType)
+		switch {
+		case channel == nil && channelType == m.ChannelTelegram:
+			fmt.Fprintf(&b, "\n%s: on", channelNames[channelType])
+		case channel == nil:
+			fmt.Fprintf(&b, "\n%s: not set up, send %s <address>", channelNames[channelType], channelCommands[channelType])
+		case channel.Address != "":
+			fmt.Fprintf(&b, "\n%s: %s (%s)", channelNames[channelType], channelStatus(channel), channel.Address)
+		default:
+			fmt.Fprintf(&b, "\n%s: %s", channelNames[channelType], channelStatus(channel))
+		}
+	}
+	b.WriteString("\n\nPress a channel to turn it on or off.")
+	return b.String()
+}
+
+// channelsKeyboard creates the keyboard with a toggle button per channel and the "Back" button.
+func (b *Bot) channelsKeyboard(channels []*m.DeliveryChannel) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(m.ChannelTypes)+1)
+	for _, channelType := range m.ChannelTypes {
+		status := "off"
+		if channel := findChannel(channels, channelType); channel != nil {
+			status = channelStatus(channel)
+		} else if channelType == m.ChannelTelegram {
+			status = "on"
+		}
+		text := fmt.Sprintf("%s: %s", channelNames[channelType], status)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, encodeCallback(actionToggleChannel, string(channelType))),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionMainMenu)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// findChannel returns the channel of the type, or nil if the user has not set it up.
+func findChannel(channels []*m.DeliveryChannel, channelType m.ChannelType) *m.DeliveryChannel {
+	for _, channel := range channels {
+		if channel.Type == channelType {
+			return channel
+		}
+	}
+	return nil
+}
+
+// newVerificationCode returns a random code confirming an email address.
+// The alphabet has 32 characters, so mapping the random bytes onto it is not biased.
+func newVerificationCode() (string, error) {
+	code := make([]byte, verificationCodeLength)
+	if _, err := rand.Read(code); err != nil {
+		return "", fmt.Errorf("failed to generate a verification code: %w", err)
+	}
+	for i, c := range code {
+		code[i] = verificationAlphabet[int(c)%len(verificationAlphabet)]
+	}
+	return string(code), nil
+}
+
+// channelStatus returns "on", "off" or "unconfirmed" for an email address waiting for its confirmation.
+func channelStatus(channel *m.DeliveryChannel) string {
+	if !channel.Confirmed() {
+		return "unconfirmed"
+	}
+	if channel.Enabled {
+		return "on"
+	}
+	return "off"
+}
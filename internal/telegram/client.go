@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+)
+
+// Client is the part of the Telegram Bot API the bot uses.
+// *tgbotapi.BotAPI implements it, tests use a client talking to a fake Bot API server.
+type Client interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+}
+
+var _ Client = (*tgbotapi.BotAPI)(nil)
+
+// NewClient creates a client of the Bot API at the configured endpoint.
+// It checks the token by calling getMe.
+func NewClient(cfg config.TelegramConfig) (*tgbotapi.BotAPI, error) {
+	// The long polling requests wait up to a minute for updates
+	httpClient := &http.Client{Timeout: 90 * time.Second}
+	return tgbotapi.NewBotAPIWithClient(cfg.BotToken, cfg.APIEndpoint, httpClient)
+}
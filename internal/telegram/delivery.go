@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/localtime"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// The delivery mode of a subscription is chosen from its card: the alerts are sent right away,
+// or collected into a daily or weekly digest sent at the chosen time in the time zone of the user.
+// The choice is made step by step, every step adds an argument to the callback data:
+// the mode, the weekday of a weekly digest and the send time.
+
+// defaultDigestTime is the send time of the digests of a new subscription, in minutes after midnight.
+const defaultDigestTime = 9 * 60
+
+// digestTimePresets are the send times of the digests offered, in minutes after midnight.
+var digestTimePresets = []int{7 * 60, 9 * 60, 12 * 60, 18 * 60, 21 * 60}
+
+// weekdays are the weekdays offered for a weekly digest, starting on Monday.
+var weekdays = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday,
+}
+
+// handleDeliveryMode walks the user through choosing the delivery mode of a subscription and saves it.
+func (b *Bot) handleDeliveryMode(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	subscription, ok := b.callbackSubscription(ctx, query, data)
+	if !ok {
+		return
+	}
+	id := formatID(subscription.ID)
+	mode, weekdayArg, timeArg := data.arg(1), data.arg(2), data.arg(3)
+
+	switch {
+	case mode == "":
+		b.answerCallback(ctx, query.ID, "")
+		b.editMessage(ctx, query.Message, subscriptionCardText(subscription)+"\n\nHow should the alerts be delivered?",
+			b.deliveryModeKeyboard(id))
+		return
+	case mode == m.DeliveryModeInstant:
+		subscription.DeliveryMode = m.DeliveryModeInstant
+	case mode == m.DeliveryModeWeekly && weekdayArg == "":
+		b.answerCallback(ctx, query.ID, "")
+		b.editMessage(ctx, query.Message, "On which day should the weekly digest be sent?", b.weekdayKeyboard(id))
+		return
+	case (mode == m.DeliveryModeDaily || mode == m.DeliveryModeWeekly) && timeArg == "":
+		b.answerCallback(ctx, query.ID, "")
+		b.editMessage(ctx, query.Message, "At what time should the digest be sent?",
+			b.digestTimeKeyboard(id, mode, weekdayArg))
+		return
+	case mode == m.DeliveryModeDaily || mode == m.DeliveryModeWeekly:
+		minutes, err := strconv.Atoi(timeArg)
+		if err != nil || minutes < 0 || minutes >= localtime.MinutesPerDay {
+			b.answerCallback(ctx, query.ID, "Unknown time.")
+			return
+		}
+		if mode == m.DeliveryModeWeekly {
+			weekday, err := strconv.Atoi(weekdayArg)
+			if err != nil || weekday < int(time.Sunday) || weekday > int(time.Saturday) {
+				b.answerCallback(ctx, query.ID, "Unknown day.")
+				return
+			}
+			subscription.DigestWeekday = time.Weekday(weekday)
+		}
+		subscription.DeliveryMode = mode
+		subscription.DigestTime = minutes
+	default:
+		b.answerCallback(ctx, query.ID, "Unknown delivery mode.")
+		return
+	}
+
+	subscription.UpdatedAt = time.Now()
+	if err := b.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+		slog.Error("Failed to update subscription", "subscriptionID", subscription.ID, "error", err)
+		b.answerCallback(ctx, query.ID, "Error updating the subscription. Please try again later.")
+		return
+	}
+	b.answerCallback(ctx, query.ID, "Saved")
+	b.showSubscriptionCard(ctx, query.Message, subscription)
+}
+
+// handleShowSubscription shows the card of a subscription in place of the message.
+func (b *Bot) handleShowSubscription(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	subscription, ok := b.callbackSubscription(ctx, query, data)
+	if !ok {
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "")
+	b.showSubscriptionCard(ctx, query.Message, subscription)
+}
+
+// deliveryModeKeyboard creates the keyboard with the delivery modes of the subscription and the "Back" button.
+func (b *Bot) deliveryModeKeyboard(id string) tgbotapi.InlineKeyboardMarkup {
+	mode := func(text, mode string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, encodeCallback(actionDeliveryMode, id, mode))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(mode(buttonInstant, m.DeliveryModeInstant)),
+		tgbotapi.NewInlineKeyboardRow(
+			mode(buttonDailyDigest, m.DeliveryModeDaily),
+			mode(buttonWeeklyDigest, m.DeliveryModeWeekly),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionShowSubscription, id)),
+		),
+	)
+}
+
+// weekdayKeyboard creates the keyboard with the weekdays of a weekly digest and the "Back" button.
+func (b *Bot) weekdayKeyboard(id string) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, 3)
+	for i, weekday := range weekdays {
+		data := encodeCallback(actionDeliveryMode, id, m.DeliveryModeWeekly, strconv.Itoa(int(weekday)))
+		button := tgbotapi.NewInlineKeyboardButtonData(weekday.String(), data)
+		if i%4 == 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], button)
+		}
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionDeliveryMode, id)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// digestTimeKeyboard creates the keyboard with the send times of a digest and the "Back" button.
+func (b *Bot) digestTimeKeyboard(id, mode, weekday string) tgbotapi.InlineKeyboardMarkup {
+	row := make([]tgbotapi.InlineKeyboardButton, 0, len(digestTimePresets))
+	for _, minutes := range digestTimePresets {
+		data := encodeCallback(actionDeliveryMode, id, mode, weekday, strconv.Itoa(minutes))
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(localtime.FormatClock(minutes), data))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		row,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionDeliveryMode, id)),
+		),
+	)
+}
+
+// deliveryModeText describes the delivery mode of a subscription, e.g. "Weekly digest on Monday at 09:00".
+func deliveryModeText(subscription *m.Subscription) string {
+	switch subscription.DeliveryMode {
+	case m.DeliveryModeDaily:
+		return "Daily digest at " + localtime.FormatClock(subscription.DigestTime)
+	case m.DeliveryModeWeekly:
+		return fmt.Sprintf("Weekly digest on %s at %s",
+			subscription.DigestWeekday, localtime.FormatClock(subscription.DigestTime))
+	default:
+		return "Instant"
+	}
+}
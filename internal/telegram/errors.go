@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ErrorKind classifies a failed Bot API call by what retrying it can achieve.
+type ErrorKind int
+
+const (
+	// ErrorKindTemporary is a failure worth retrying, e.g. a network error or an internal error of Telegram.
+	ErrorKindTemporary ErrorKind = iota
+	// ErrorKindRateLimited is a 429 Too Many Requests, worth retrying after the period Telegram asks to wait.
+	ErrorKindRateLimited
+	// ErrorKindUnreachable is a chat the bot cannot send to any more,
+	// e.g. the user blocked the bot or deleted their account.
+	ErrorKindUnreachable
+	// ErrorKindRejected is a call Telegram refused, resending it unchanged fails again.
+	ErrorKindRejected
+)
+
+// unreachableDescriptions are the parts of the error descriptions telling that a chat cannot be reached.
+var unreachableDescriptions = []string{
+	"bot was blocked by the user",
+	"chat not found",
+	"user is deactivated",
+	"bot was kicked",
+	"bot can't initiate conversation",
+}
+
+// ClassifyError classifies the error of a Bot API call.
+func ClassifyError(err error) ErrorKind {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return ErrorKindTemporary
+	}
+
+	description := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case containsAny(description, unreachableDescriptions):
+		return ErrorKindUnreachable
+	case apiErr.Code >= http.StatusBadRequest && apiErr.Code < http.StatusInternalServerError:
+		return ErrorKindRejected
+	default:
+		return ErrorKindTemporary
+	}
+}
+
+// containsAny reports whether s contains any of the substrings.
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,43 +2,115 @@ package telegram
 
 import (
 	"context"
-	"fmt"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"errors"
 	"log/slog"
 	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
 )
 
 func (b *Bot) handleMessage(ctx context.Context, message *tgbotapi.Message) {
-	if message.IsCommand() {
-		// Handle command messages (like /start)
-		switch message.Command() {
-		case "start":
-			b.handleStart(ctx, message)
-		default:
-			b.handleUnknownCommand(ctx, message)
-		}
-	} else {
-		// Handle text messages (including button clicks)
-		switch message.Text {
-		case "New Subscription":
-			b.handleNewSubscription(ctx, message)
-		case "List Subscriptions":
-			b.handleListSubscriptions(ctx, message)
-		case "Return":
-			b.handleReturn(ctx, message)
-		default:
-			b.handleUnknownCommand(ctx, message)
+	if !message.IsCommand() {
+		b.handleText(ctx, message)
+		return
+	}
+
+	// Handle command messages (like /start)
+	switch message.Command() {
+	case "start":
+		b.handleStart(ctx, message)
+	case "email":
+		b.handleChannelCommand(ctx, message, m.ChannelEmail)
+	case "webhook":
+		b.handleChannelCommand(ctx, message, m.ChannelWebhook)
+	case "verify":
+		b.handleVerifyCommand(ctx, message)
+	case "timezone", "quiet":
+		b.handleSettingsCommand(ctx, message)
+	case "jobs":
+		b.handleJobsCommand(ctx, message)
+	case "services":
+		b.handleServicesCommand(ctx, message)
+	default:
+		b.handleUnknownCommand(ctx, message)
+	}
+}
+
+// handleCallbackQuery dispatches a press of an inline keyboard button.
+// The query is always answered, so the client stops showing the progress indicator.
+func (b *Bot) handleCallbackQuery(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	if query.Message == nil {
+		// The button belongs to an inline mode message the bot cannot edit
+		b.answerCallback(ctx, query.ID, "")
+		return
+	}
+
+	data, err := decodeCallback(query.Data)
+	if err != nil {
+		if !errors.Is(err, errOutdatedCallback) {
+			slog.Warn("Failed to decode callback data", "data", query.Data, "error", err)
 		}
+		b.answerCallback(ctx, query.ID, "This menu is outdated.")
+		b.showMainMenu(ctx, query.Message, "What would you like to do?")
+		return
+	}
+
+	switch data.action {
+	case actionMainMenu:
+		b.handleMainMenu(ctx, query)
+	case actionNewSubscription:
+		b.handleNewSubscription(ctx, query)
+	case actionListSubscriptions:
+		b.handleListSubscriptions(ctx, query)
+	case actionCity:
+		b.handleCitySelection(ctx, query, data)
+	case actionStorage:
+		b.handleStorageSelection(ctx, query, data)
+	case actionUnitSize:
+		b.handleUnitSizeSelection(ctx, query, data)
+	case actionConfirm:
+		b.handleConfirmation(ctx, query)
+	case actionCancel:
+		b.handleCancel(ctx, query)
+	case actionPause:
+		b.handleSetSubscriptionStatus(ctx, query, data, m.SubscriptionStatusPaused)
+	case actionResume:
+		b.handleSetSubscriptionStatus(ctx, query, data, m.SubscriptionStatusActive)
+	case actionEdit:
+		b.handleEditSubscription(ctx, query, data)
+	case actionDelete:
+		b.handleDeleteSubscription(ctx, query, data)
+	case actionUndoDelete:
+		b.handleUndoDelete(ctx, query, data)
+	case actionChannels:
+		b.handleListChannels(ctx, query)
+	case actionToggleChannel:
+		b.handleToggleChannel(ctx, query, data)
+	case actionSettings:
+		b.handleSettings(ctx, query)
+	case actionTimeZone:
+		b.handleTimeZone(ctx, query, data)
+	case actionQuietHours:
+		b.handleQuietHours(ctx, query, data)
+	case actionDeliveryMode:
+		b.handleDeliveryMode(ctx, query, data)
+	case actionShowSubscription:
+		b.handleShowSubscription(ctx, query, data)
+	case actionPriceAlerts:
+		b.handlePriceAlerts(ctx, query, data)
+	default:
+		slog.Warn("Unknown callback action", "data", query.Data)
+		b.answerCallback(ctx, query.ID, "Unknown action.")
 	}
 }
 
 func (b *Bot) handleStart(ctx context.Context, message *tgbotapi.Message) {
 	// Retrieve the user from the database
-	user, err := b.userRepo.GetByTelegramID(message.Chat.ID)
+	user, err := b.userRepo.GetByTelegramID(ctx, message.Chat.ID)
 	if err != nil {
 		slog.Error("Failed to retrieve user", "error", err)
-		b.sendErrorMessage(message.Chat.ID, "Error retrieving user. Please try again later.")
+		b.sendErrorMessage(ctx, message.Chat.ID, "Error retrieving user. Please try again later.")
 		return
 	}
 
@@ -50,159 +122,64 @@ func (b *Bot) handleStart(ctx context.Context, message *tgbotapi.Message) {
 			UserName:   message.Chat.UserName,
 			FirstName:  message.Chat.FirstName,
 			LastName:   message.Chat.LastName,
+			Active:     true,
 			CreatedAt:  time.Now(),
 			UpdatedAt:  time.Now(),
 		}
-		err = b.userRepo.CreateUser(user)
+		err = b.userRepo.CreateUser(ctx, user)
 		if err != nil {
 			slog.Error("Failed to create user", "error", err)
-			b.sendErrorMessage(message.Chat.ID, "Error creating user. Please try again later.")
+			b.sendErrorMessage(ctx, message.Chat.ID, "Error creating user. Please try again later.")
 			return
 		}
+	} else if !user.Active {
+		b.reactivateUser(ctx, user)
 	}
 
-	// Send the welcome message
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Welcome! What would you like to do?")
-	msg.ReplyMarkup = b.mainMenu()
-	b.api.Send(msg)
-}
-
-func (b *Bot) handleNewSubscription(ctx context.Context, message *tgbotapi.Message) {
-	// Query available cities from the database
-	cities, err := b.unitRepo.GetCities()
-	if err != nil {
-		slog.Error("Failed to retrieve cities", "error", err)
-		b.sendErrorMessage(message.Chat.ID, "Error retrieving cities. Please try again later.")
-		return
+	// Leave the wizard if the chat is in it
+	if err := b.chatStateRepo.DeleteChatState(ctx, message.Chat.ID); err != nil {
+		slog.Error("Failed to delete chat state", "error", err)
 	}
 
-	// Send cities as reply keyboard
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Select a city:")
-	msg.ReplyMarkup = b.citySelectionKeyboard(cities)
-	b.api.Send(msg)
-}
-
-func (b *Bot) handleCitySelection(ctx context.Context, message *tgbotapi.Message) {
-	// Retrieve storages based on the selected city
-	storages, err := b.unitRepo.GetStoragesByCity(message.Text)
-	if err != nil {
-		slog.Error("Failed to retrieve storages", "error", err)
-		b.sendErrorMessage(message.Chat.ID, "Error retrieving storages. Please try again later.")
+	// Send the welcome message, removing the reply keyboard of older releases
+	welcome := tgbotapi.NewMessage(message.Chat.ID, "Welcome!")
+	welcome.ReplyMarkup = tgbotapi.NewRemoveKeyboard(false)
+	if _, err := b.api.SendContext(ctx, welcome); err != nil {
+		slog.Error("Failed to send welcome message", "chatID", message.Chat.ID, "error", err)
 		return
 	}
 
-	// Send storages as reply keyboard
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Select a storage:")
-	msg.ReplyMarkup = b.storageSelectionKeyboard(storages)
-	b.api.Send(msg)
-}
-
-func (b *Bot) handleStorageSelection(ctx context.Context, message *tgbotapi.Message) {
-	// Retrieve unit sizes based on the selected storage
-	unitSizes, err := b.unitRepo.GetUnitSizesByStorage(message.Text)
-	if err != nil {
-		slog.Error("Failed to retrieve unit sizes", "error", err)
-		b.sendErrorMessage(message.Chat.ID, "Error retrieving unit sizes. Please try again later.")
-		return
+	msg := tgbotapi.NewMessage(message.Chat.ID, "What would you like to do?")
+	msg.ReplyMarkup = b.mainMenu()
+	if _, err := b.api.SendContext(ctx, msg); err != nil {
+		slog.Error("Failed to send main menu", "chatID", message.Chat.ID, "error", err)
 	}
-
-	// Send unit sizes as reply keyboard
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Select a unit size:")
-	msg.ReplyMarkup = b.unitSizeSelectionKeyboard(unitSizes)
-	b.api.Send(msg)
-}
-
-func (b *Bot) handleUnitSizeSelection(ctx context.Context, message *tgbotapi.Message) {
-	// Here you would save the subscription details
-	// For example, save the user ID and unit size to the subscription table
-
-	// Confirm the subscription
-	msg := tgbotapi.NewMessage(message.Chat.ID, "You have been subscribed.")
-	b.api.Send(msg)
 }
 
-func (b *Bot) handleListSubscriptions(ctx context.Context, message *tgbotapi.Message) {
-	// Get the user ID from the message
-	user, err := b.userRepo.GetByTelegramID(message.Chat.ID)
+// handleText handles a message that is not a command.
+// Menus are inline keyboards, free text is only expected when the chat was asked for a value.
+func (b *Bot) handleText(ctx context.Context, message *tgbotapi.Message) {
+	state, err := b.chatStateRepo.GetChatState(ctx, message.Chat.ID)
 	if err != nil {
-		slog.Error("Failed to retrieve user", "error", err)
-		b.sendErrorMessage(message.Chat.ID, "Error retrieving user. Please try again later.")
-		return
+		slog.Error("Failed to retrieve chat state", "error", err)
 	}
-
-	// Check if the user is not found
-	if user == nil {
-		slog.Error("User not found", "telegram_id", message.Chat.ID)
-		b.sendErrorMessage(message.Chat.ID, "User not found. Please start the bot again.")
-		return
-	}
-
-	// Retrieve the list of subscriptions for the user
-	subscriptions, err := b.subscriptionRepo.GetSubscriptionsByUserID(user.ID)
-	if err != nil {
-		slog.Error("Failed to retrieve subscriptions", "error", err)
-		b.sendErrorMessage(message.Chat.ID, "Error retrieving subscriptions. Please try again later.")
+	if state != nil && state.Step == m.ChatStepMaxPrice {
+		b.handleMaxPriceInput(ctx, message, state)
 		return
 	}
-
-	// Create a response message listing all subscriptions
-	var response string
-	for _, subscription := range subscriptions {
-		response += fmt.Sprintf("City: %s, Storage: %s, Unit: %s\n", subscription.City, subscription.Storage, subscription.UnitSize)
-	}
-
-	msg := tgbotapi.NewMessage(message.Chat.ID, response)
-	b.api.Send(msg)
+	b.handleUnknownCommand(ctx, message)
 }
 
-func (b *Bot) handleReturn(ctx context.Context, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Returning to the main menu.")
-	msg.ReplyMarkup = b.mainMenu() // Show the main menu again
-	b.api.Send(msg)
+func (b *Bot) handleMainMenu(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	b.answerCallback(ctx, query.ID, "")
+	b.showMainMenu(ctx, query.Message, "What would you like to do?")
 }
 
 func (b *Bot) handleUnknownCommand(ctx context.Context, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Unknown command. Please use the menu.")
-	b.api.Send(msg)
-}
-
-// mainMenu creates the main menu keyboard.
-func (b *Bot) mainMenu() tgbotapi.ReplyKeyboardMarkup {
-	return tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("New Subscription"),
-			tgbotapi.NewKeyboardButton("List Subscriptions"),
-		),
-	)
+	b.sendMessage(ctx, message.Chat.ID, "Unknown command. Please use the menu or /start.")
 }
 
-func (b *Bot) citySelectionKeyboard(cities []string) tgbotapi.ReplyKeyboardMarkup {
-	var rows [][]tgbotapi.KeyboardButton
-
-	// Add each city as a button in a row
-	for _, city := range cities {
-		rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(city)))
-	}
-
-	// Add the "Return" button as the last row
-	returnButton := tgbotapi.NewKeyboardButton("Return")
-	rows = append(rows, tgbotapi.NewKeyboardButtonRow(returnButton))
-
-	return tgbotapi.NewReplyKeyboard(rows...)
-}
-
-func (b *Bot) storageSelectionKeyboard(storages []string) tgbotapi.ReplyKeyboardMarkup {
-	var rows [][]tgbotapi.KeyboardButton
-	for _, storage := range storages {
-		rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(storage)))
-	}
-	return tgbotapi.NewReplyKeyboard(rows...)
-}
-
-func (b *Bot) unitSizeSelectionKeyboard(unitSizes []string) tgbotapi.ReplyKeyboardMarkup {
-	var rows [][]tgbotapi.KeyboardButton
-	for _, size := range unitSizes {
-		rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(size)))
-	}
-	return tgbotapi.NewReplyKeyboard(rows...)
+// showMainMenu replaces the message with the given text and the main menu.
+func (b *Bot) showMainMenu(ctx context.Context, message *tgbotapi.Message, text string) {
+	b.editMessage(ctx, message, text, b.mainMenu())
 }
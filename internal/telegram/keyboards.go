@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// Button labels.
+const (
+	buttonNewSubscription   = "New Subscription"
+	buttonListSubscriptions = "List Subscriptions"
+	buttonBack              = "« Back"
+	buttonCancel            = "Cancel"
+	buttonConfirm           = "Confirm"
+	buttonPause             = "Pause"
+	buttonResume            = "Resume"
+	buttonEdit              = "Edit"
+	buttonDelete            = "Delete"
+	buttonUndo              = "Undo"
+	buttonChannels          = "Delivery Channels"
+	buttonSettings          = "Settings"
+	buttonTimeZone          = "Time Zone"
+	buttonQuietHours        = "Quiet Hours"
+	buttonQuietHoursOff     = "No Quiet Hours"
+	buttonDeliveryMode      = "Delivery"
+	buttonInstant           = "Instant"
+	buttonDailyDigest       = "Daily Digest"
+	buttonWeeklyDigest      = "Weekly Digest"
+	buttonPriceAlerts       = "Price Alerts"
+	buttonMaxPrice          = "Max Price"
+	buttonNoMaxPrice        = "No Max Price"
+	buttonNoPriceDrops      = "No Drop Alerts"
+)
+
+// mainMenu creates the main menu keyboard.
+func (b *Bot) mainMenu() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonNewSubscription, encodeCallback(actionNewSubscription)),
+			tgbotapi.NewInlineKeyboardButtonData(buttonListSubscriptions, encodeCallback(actionListSubscriptions)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonChannels, encodeCallback(actionChannels)),
+			tgbotapi.NewInlineKeyboardButtonData(buttonSettings, encodeCallback(actionSettings)),
+		),
+	)
+}
+
+func (b *Bot) citySelectionKeyboard(cities []string) tgbotapi.InlineKeyboardMarkup {
+	return selectionKeyboard(actionCity, cities)
+}
+
+func (b *Bot) storageSelectionKeyboard(storages []m.Storage) tgbotapi.InlineKeyboardMarkup {
+	return selectionKeyboard(actionStorage, storageOptions(storages))
+}
+
+func (b *Bot) unitSizeSelectionKeyboard(unitSizes []string) tgbotapi.InlineKeyboardMarkup {
+	return selectionKeyboard(actionUnitSize, unitSizes)
+}
+
+// confirmationKeyboard creates the keyboard of the confirmation step.
+func (b *Bot) confirmationKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonConfirm, encodeCallback(actionConfirm)),
+			tgbotapi.NewInlineKeyboardButtonData(buttonCancel, encodeCallback(actionCancel)),
+		),
+	)
+}
+
+// cancelKeyboard creates a keyboard with a single button leaving the step the chat is in.
+func (b *Bot) cancelKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonCancel, encodeCallback(actionCancel)),
+		),
+	)
+}
+
+// backKeyboard creates a keyboard with a single button leading back to the main menu.
+func (b *Bot) backKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionMainMenu)),
+		),
+	)
+}
+
+// selectionKeyboard creates a keyboard with one option per row and the "Cancel" button as the last row.
+// Each option button carries the action and the token of the option.
+func selectionKeyboard(action string, options []string) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(options)+1)
+	for _, option := range options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(option, encodeCallback(action, optionToken(option))),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(buttonCancel, encodeCallback(actionCancel)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -1,24 +1,77 @@
 package telegram
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/notifier"
 )
 
 // sendErrorMessage sends an error message to the user.
-func (b *Bot) sendErrorMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	b.api.Send(msg)
+func (b *Bot) sendErrorMessage(ctx context.Context, chatID int64, text string) {
+	if _, err := b.api.SendContext(ctx, tgbotapi.NewMessage(chatID, text)); err != nil {
+		slog.Error("Failed to send error message", "chatID", chatID, "error", err)
+	}
+}
+
+// sendMessage sends a plain text message to the chat.
+func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string) {
+	if _, err := b.api.SendContext(ctx, tgbotapi.NewMessage(chatID, text)); err != nil {
+		slog.Error("Failed to send message", "chatID", chatID, "error", err)
+	}
+}
+
+// answerCallback answers a callback query, an empty text only stops the progress indicator on the button.
+func (b *Bot) answerCallback(ctx context.Context, queryID, text string) {
+	if _, err := b.api.RequestContext(ctx, tgbotapi.NewCallback(queryID, text)); err != nil {
+		slog.Error("Failed to answer callback query", "error", err)
+	}
+}
+
+// editMessage replaces the text and the inline keyboard of a message sent by the bot.
+func (b *Bot) editMessage(
+	ctx context.Context, message *tgbotapi.Message, text string, markup tgbotapi.InlineKeyboardMarkup,
+) {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, markup)
+	if _, err := b.api.SendContext(ctx, edit); err != nil {
+		slog.Error("Failed to edit message", "chatID", message.Chat.ID, "messageID", message.MessageID, "error", err)
+	}
+}
+
+// editMessageText replaces the text of a message sent by the bot and removes its inline keyboard.
+func (b *Bot) editMessageText(ctx context.Context, message *tgbotapi.Message, text string) {
+	edit := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+	if _, err := b.api.SendContext(ctx, edit); err != nil {
+		slog.Error("Failed to edit message", "chatID", message.Chat.ID, "messageID", message.MessageID, "error", err)
+	}
 }
 
 // SendNotification sends a notification to the user.
-func (b *Bot) SendNotification(userID int64, text string) error {
-	msg := tgbotapi.NewMessage(userID, text)
-	_, err := b.api.Send(msg)
-	return err
+// A user who cannot be reached any more is deactivated. The errors resending cannot fix wrap notifier.ErrUndeliverable.
+func (b *Bot) SendNotification(ctx context.Context, chatID int64, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	_, err := b.api.SendContext(ctx, msg)
+	if err == nil {
+		return nil
+	}
+
+	switch ClassifyError(err) {
+	case ErrorKindUnreachable:
+		b.deactivateUser(ctx, chatID)
+		return fmt.Errorf("%w: %w", notifier.ErrUndeliverable, err)
+	case ErrorKindRejected:
+		return fmt.Errorf("%w: %w", notifier.ErrUndeliverable, err)
+	default:
+		return err
+	}
 }
 
 // SendErrorNotification sends an error notification to the admin.
-func (b *Bot) SendErrorNotification(text string) {
+func (b *Bot) SendErrorNotification(ctx context.Context, text string) error {
 	msg := tgbotapi.NewMessage(b.cfg.AdminID, text)
-	b.api.Send(msg)
+	_, err := b.api.SendContext(ctx, msg)
+	return err
 }
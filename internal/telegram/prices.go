@@ -0,0 +1,168 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/notifier"
+)
+
+// The price rules of a subscription are set from its card. The price drops worth an alert are offered as buttons,
+// the maximum price is asked for and sent by the user as a message.
+//
+// Price rules set by the buttons of the price alerts menu.
+const (
+	priceRuleDrop       = "drop"  // Set the minimum price drop, args: percent, zero turns it off
+	priceRuleMaxPrice   = "max"   // Ask for the maximum price
+	priceRuleNoMaxPrice = "nomax" // Remove the maximum price
+)
+
+// maxPriceExample shows the users how to write the maximum price.
+const maxPriceExample = "e.g. 5000"
+
+// priceDropPresets are the minimum price drops offered, in percent.
+var priceDropPresets = []int{5, 10, 20}
+
+// handlePriceAlerts shows the price rules of a subscription, or changes the rule given in the callback data.
+func (b *Bot) handlePriceAlerts(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	subscription, ok := b.callbackSubscription(ctx, query, data)
+	if !ok {
+		return
+	}
+	id := formatID(subscription.ID)
+
+	switch data.arg(1) {
+	case "":
+		b.answerCallback(ctx, query.ID, "")
+		b.editMessage(ctx, query.Message, subscriptionCardText(subscription)+"\n\nWhich price changes should be announced?",
+			b.priceAlertsKeyboard(id))
+		return
+	case priceRuleMaxPrice:
+		state := &m.ChatState{ChatID: query.Message.Chat.ID, Step: m.ChatStepMaxPrice, SubscriptionID: subscription.ID}
+		if !b.saveChatState(ctx, query, state) {
+			return
+		}
+		b.answerCallback(ctx, query.ID, "")
+		b.editMessage(ctx, query.Message, "Send the maximum monthly price in rubles, "+maxPriceExample+".\n\n"+
+			"Units above it are not announced, and you are alerted when the price of a unit falls to it.",
+			b.cancelKeyboard())
+		return
+	case priceRuleNoMaxPrice:
+		subscription.MaxPrice = 0
+	case priceRuleDrop:
+		percent, err := strconv.Atoi(data.arg(2))
+		if err != nil || percent < 0 || percent >= 100 {
+			b.answerCallback(ctx, query.ID, "Unknown price drop.")
+			return
+		}
+		subscription.MinPriceDrop = percent
+	default:
+		b.answerCallback(ctx, query.ID, "Unknown price rule.")
+		return
+	}
+
+	subscription.UpdatedAt = time.Now()
+	if err := b.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+		slog.Error("Failed to update subscription", "subscriptionID", subscription.ID, "error", err)
+		b.answerCallback(ctx, query.ID, "Error updating the subscription. Please try again later.")
+		return
+	}
+	b.answerCallback(ctx, query.ID, "Saved")
+	b.showSubscriptionCard(ctx, query.Message, subscription)
+}
+
+// handleMaxPriceInput sets the maximum price of the subscription the chat was asked about.
+func (b *Bot) handleMaxPriceInput(ctx context.Context, message *tgbotapi.Message, state *m.ChatState) {
+	chatID := message.Chat.ID
+
+	price, ok := parsePrice(message.Text)
+	if !ok {
+		b.sendMessage(ctx, chatID, "Please send the price as a number, "+maxPriceExample+".")
+		return
+	}
+
+	subscription, err := b.subscriptionRepo.GetSubscriptionByID(ctx, state.SubscriptionID)
+	if err != nil {
+		slog.Error("Failed to retrieve subscription", "subscriptionID", state.SubscriptionID, "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error retrieving the subscription. Please try again later.")
+		return
+	}
+	if err := b.chatStateRepo.DeleteChatState(ctx, chatID); err != nil {
+		slog.Error("Failed to delete chat state", "error", err)
+	}
+	if subscription == nil {
+		b.sendMessage(ctx, chatID, "This subscription no longer exists.")
+		return
+	}
+
+	subscription.MaxPrice = price
+	subscription.UpdatedAt = time.Now()
+	if err := b.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+		slog.Error("Failed to update subscription", "subscriptionID", subscription.ID, "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error updating the subscription. Please try again later.")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, subscriptionCardText(subscription))
+	msg.ReplyMarkup = b.subscriptionCardKeyboard(subscription)
+	if _, err := b.api.SendContext(ctx, msg); err != nil {
+		slog.Error("Failed to send subscription card", "subscriptionID", subscription.ID, "error", err)
+	}
+}
+
+// priceAlertsKeyboard creates the keyboard of the price alerts menu of a subscription.
+func (b *Bot) priceAlertsKeyboard(id string) tgbotapi.InlineKeyboardMarkup {
+	rule := func(text string, args ...string) tgbotapi.InlineKeyboardButton {
+		data := encodeCallback(actionPriceAlerts, append([]string{id}, args...)...)
+		return tgbotapi.NewInlineKeyboardButtonData(text, data)
+	}
+	drops := make([]tgbotapi.InlineKeyboardButton, 0, len(priceDropPresets))
+	for _, percent := range priceDropPresets {
+		drops = append(drops, rule(fmt.Sprintf("Drop of %d%%", percent), priceRuleDrop, strconv.Itoa(percent)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		drops,
+		tgbotapi.NewInlineKeyboardRow(rule(buttonNoPriceDrops, priceRuleDrop, "0")),
+		tgbotapi.NewInlineKeyboardRow(
+			rule(buttonMaxPrice, priceRuleMaxPrice),
+			rule(buttonNoMaxPrice, priceRuleNoMaxPrice),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionShowSubscription, id)),
+		),
+	)
+}
+
+// priceRulesText describes the price rules of a subscription, e.g. "up to 5000 ₽/month, drops of 10% or more".
+func priceRulesText(subscription *m.Subscription) string {
+	var rules []string
+	if subscription.MaxPrice > 0 {
+		rules = append(rules, "up to "+notifier.FormatPrice(subscription.MaxPrice)+" ₽/month")
+	}
+	if subscription.MinPriceDrop > 0 {
+		rules = append(rules, fmt.Sprintf("drops of %d%% or more", subscription.MinPriceDrop))
+	}
+	if len(rules) == 0 {
+		return "off"
+	}
+	return strings.Join(rules, ", ")
+}
+
+// parsePrice parses a positive price sent by a user, e.g. "5000", "5 000" or "4999,90".
+func parsePrice(text string) (float64, bool) {
+	text = strings.NewReplacer(" ", "", " ", "", ",", ".", "₽", "").Replace(strings.TrimSpace(text))
+	price, err := strconv.ParseFloat(text, 64)
+	// NaN fails every comparison, so it is rejected explicitly
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) || price <= 0 || price > 1e9 {
+		return 0, false
+	}
+	return price, true
+}
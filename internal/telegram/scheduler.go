@@ -0,0 +1,208 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+)
+
+const (
+	// maxRateLimitRetries bounds the resends of a call Telegram answered with 429 Too Many Requests.
+	maxRateLimitRetries = 3
+	// maxRetryWait is the longest retry_after worth waiting for, a longer one is returned to the caller.
+	maxRetryWait = time.Minute
+	// chatBurst is the number of calls to a chat made at once before the per-chat limit spaces them out,
+	// so a reply of several messages is not held back.
+	chatBurst = 3
+	// maxTrackedChats bounds the chats whose next send slot is remembered before the stale ones are dropped.
+	maxTrackedChats = 1000
+)
+
+// Scheduler is a Client sending through another client within the rate limits of Telegram.
+// The calls are spaced out across all chats and per chat, and a call answered with
+// 429 Too Many Requests is resent after the retry_after period of the response.
+// The waits end early when the context of the call is done.
+type Scheduler struct {
+	client         Client
+	globalInterval time.Duration
+	chatInterval   time.Duration
+	now            func() time.Time
+	sleep          func(ctx context.Context, d time.Duration) error
+
+	mu         sync.Mutex
+	next       time.Time           // Earliest time of the next call
+	nextByChat map[int64]time.Time // Time each chat has caught up with its limit
+}
+
+var _ Client = (*Scheduler)(nil)
+
+// NewScheduler creates a Scheduler sending through the client with the configured rate limits.
+// A zero limit disables it.
+func NewScheduler(client Client, cfg config.TelegramConfig) *Scheduler {
+	s := &Scheduler{
+		client:     client,
+		now:        time.Now,
+		sleep:      sleep,
+		nextByChat: make(map[int64]time.Time),
+	}
+	if cfg.RateLimit > 0 {
+		s.globalInterval = time.Second / time.Duration(cfg.RateLimit)
+	}
+	if cfg.ChatRateLimit > 0 {
+		s.chatInterval = time.Minute / time.Duration(cfg.ChatRateLimit)
+	}
+	return s
+}
+
+// Send sends a message or an edit within the rate limits.
+func (s *Scheduler) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return s.SendContext(context.Background(), c)
+}
+
+// SendContext sends a message or an edit within the rate limits, waiting for its slot until the context is done.
+func (s *Scheduler) SendContext(ctx context.Context, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var message tgbotapi.Message
+	err := s.do(ctx, chatIDOf(c), func() (err error) {
+		message, err = s.client.Send(c)
+		return err
+	})
+	return message, err
+}
+
+// Request makes a call within the rate limits.
+func (s *Scheduler) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return s.RequestContext(context.Background(), c)
+}
+
+// RequestContext makes a call within the rate limits, waiting for its slot until the context is done.
+func (s *Scheduler) RequestContext(ctx context.Context, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var response *tgbotapi.APIResponse
+	err := s.do(ctx, chatIDOf(c), func() (err error) {
+		response, err = s.client.Request(c)
+		return err
+	})
+	return response, err
+}
+
+// GetUpdatesChan starts receiving updates, which is not rate limited.
+func (s *Scheduler) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return s.client.GetUpdatesChan(config)
+}
+
+// StopReceivingUpdates stops receiving updates.
+func (s *Scheduler) StopReceivingUpdates() {
+	s.client.StopReceivingUpdates()
+}
+
+// do makes the call in its slot and resends it while Telegram asks to retry later.
+// The error of the last attempt is returned, or the error of the context if it is done while waiting.
+func (s *Scheduler) do(ctx context.Context, chatID int64, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := s.sleep(ctx, s.reserve(chatID)); err != nil {
+			return err
+		}
+		err := call()
+
+		wait, limited := retryAfter(err)
+		if !limited || attempt == maxRateLimitRetries || wait > maxRetryWait {
+			return err
+		}
+		slog.Warn("Telegram rate limit exceeded, retrying", "chatID", chatID, "retryAfter", wait)
+		s.pause(wait)
+	}
+}
+
+// reserve takes the next free slot of the chat and returns how long to wait for it.
+// A chat may be up to chatBurst calls ahead of its limit. A zero chat ID only takes a slot of the global limit.
+func (s *Scheduler) reserve(chatID int64) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	slot := laterOf(now, s.next)
+	if chatID != 0 {
+		caughtUp := s.nextByChat[chatID]
+		slot = laterOf(slot, caughtUp.Add(-(chatBurst-1)*s.chatInterval))
+		if len(s.nextByChat) >= maxTrackedChats {
+			s.dropStaleChats(now)
+		}
+		s.nextByChat[chatID] = laterOf(caughtUp, slot).Add(s.chatInterval)
+	}
+	s.next = slot.Add(s.globalInterval)
+	return slot.Sub(now)
+}
+
+// pause holds back every call for the period Telegram asked to wait.
+func (s *Scheduler) pause(wait time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next = laterOf(s.next, s.now().Add(wait))
+}
+
+// dropStaleChats forgets the chats that have caught up with their limit. The mutex must be held.
+func (s *Scheduler) dropStaleChats(now time.Time) {
+	for chatID, next := range s.nextByChat {
+		if !next.After(now) {
+			delete(s.nextByChat, chatID)
+		}
+	}
+}
+
+// retryAfter reports whether the error is a 429 Too Many Requests and the period Telegram asks to wait.
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests {
+		return 0, false
+	}
+	return time.Duration(apiErr.RetryAfter) * time.Second, true
+}
+
+// chatIDOf returns the chat a call is addressed to, zero if it is not addressed to a chat.
+func chatIDOf(c tgbotapi.Chattable) int64 {
+	switch c := c.(type) {
+	case tgbotapi.MessageConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return c.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return c.ChatID
+	default:
+		return 0
+	}
+}
+
+// sleep waits for the duration unless the context is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// laterOf returns the later of the times.
+func laterOf(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
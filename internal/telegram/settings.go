@@ -0,0 +1,294 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/movax01h/kladovkin-telegram-bot/internal/localtime"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// The settings menu shows the time zone and the quiet hours of the user and changes them.
+// The time zones of Russia and a few quiet hours are offered as buttons, the /timezone and /quiet commands
+// take any other.
+
+// timeZone is a time zone offered in the settings.
+type timeZone struct {
+	name  string // IANA name
+	label string
+}
+
+// timeZones are the time zones of Russia, from west to east.
+var timeZones = []timeZone{
+	{"Europe/Kaliningrad", "Kaliningrad"},
+	{"Europe/Moscow", "Moscow"},
+	{"Europe/Samara", "Samara"},
+	{"Asia/Yekaterinburg", "Yekaterinburg"},
+	{"Asia/Omsk", "Omsk"},
+	{"Asia/Novosibirsk", "Novosibirsk"},
+	{"Asia/Krasnoyarsk", "Krasnoyarsk"},
+	{"Asia/Irkutsk", "Irkutsk"},
+	{"Asia/Yakutsk", "Yakutsk"},
+	{"Asia/Vladivostok", "Vladivostok"},
+	{"Asia/Magadan", "Magadan"},
+	{"Asia/Kamchatka", "Kamchatka"},
+}
+
+// quietHoursPresets are the quiet hours offered in the settings, as the start and end minutes after midnight.
+var quietHoursPresets = [][2]int{
+	{22 * 60, 7 * 60},
+	{23 * 60, 8 * 60},
+	{0, 9 * 60},
+}
+
+// quietHoursOff is the callback argument turning the quiet hours off.
+const quietHoursOff = "off"
+
+func (b *Bot) handleSettings(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	user, ok := b.callbackUser(ctx, query)
+	if !ok {
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "")
+	b.editMessage(ctx, query.Message, settingsText(user, time.Now()), b.settingsKeyboard())
+}
+
+// handleTimeZone shows the time zones to choose from, or sets the time zone given in the callback data.
+func (b *Bot) handleTimeZone(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	if data.arg(0) == "" {
+		b.answerCallback(ctx, query.ID, "")
+		b.editMessage(ctx, query.Message, "Select your time zone:", b.timeZoneKeyboard(time.Now()))
+		return
+	}
+
+	name := data.arg(0)
+	if !validTimeZone(name) {
+		b.answerCallback(ctx, query.ID, "Unknown time zone.")
+		return
+	}
+	b.updateSettings(ctx, query, func(user *m.User) { user.TimeZone = name })
+}
+
+// handleQuietHours shows the quiet hours to choose from, or sets the quiet hours given in the callback data.
+func (b *Bot) handleQuietHours(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	if data.arg(0) == "" {
+		b.answerCallback(ctx, query.ID, "")
+		b.editMessage(ctx, query.Message, quietHoursPrompt, b.quietHoursKeyboard())
+		return
+	}
+
+	start, end, ok := decodeQuietHours(data.arg(0))
+	if !ok {
+		b.answerCallback(ctx, query.ID, "Unknown quiet hours.")
+		return
+	}
+	b.updateSettings(ctx, query, func(user *m.User) { user.QuietHoursStart, user.QuietHoursEnd = start, end })
+}
+
+// updateSettings changes the settings of the user of the query and shows them.
+func (b *Bot) updateSettings(ctx context.Context, query *tgbotapi.CallbackQuery, change func(user *m.User)) {
+	user, ok := b.callbackUser(ctx, query)
+	if !ok {
+		return
+	}
+
+	change(user)
+	user.UpdatedAt = time.Now()
+	if err := b.userRepo.UpdateUser(ctx, user); err != nil {
+		slog.Error("Failed to update user settings", "userID", user.ID, "error", err)
+		b.answerCallback(ctx, query.ID, "Error saving the settings. Please try again later.")
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "Saved")
+	b.editMessage(ctx, query.Message, settingsText(user, time.Now()), b.settingsKeyboard())
+}
+
+// handleSettingsCommand sets the time zone with /timezone or the quiet hours with /quiet.
+func (b *Bot) handleSettingsCommand(ctx context.Context, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	arg := strings.TrimSpace(message.CommandArguments())
+
+	var change func(user *m.User)
+	switch message.Command() {
+	case "timezone":
+		if !validTimeZone(arg) {
+			b.sendMessage(ctx, chatID, "Usage: /timezone <time zone>, e.g. /timezone Asia/Yekaterinburg.")
+			return
+		}
+		change = func(user *m.User) { user.TimeZone = arg }
+	case "quiet":
+		start, end, ok := parseQuietHours(arg)
+		if !ok {
+			b.sendMessage(ctx, chatID, "Usage: /quiet <from>-<to>, e.g. /quiet 23:00-08:00, or /quiet off.")
+			return
+		}
+		change = func(user *m.User) { user.QuietHoursStart, user.QuietHoursEnd = start, end }
+	}
+
+	user, err := b.userRepo.GetByTelegramID(ctx, chatID)
+	if err != nil {
+		slog.Error("Failed to retrieve user", "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error retrieving user. Please try again later.")
+		return
+	}
+	if user == nil {
+		b.sendMessage(ctx, chatID, "Please start the bot with /start first.")
+		return
+	}
+
+	change(user)
+	user.UpdatedAt = time.Now()
+	if err := b.userRepo.UpdateUser(ctx, user); err != nil {
+		slog.Error("Failed to update user settings", "userID", user.ID, "error", err)
+		b.sendErrorMessage(ctx, chatID, "Error saving the settings. Please try again later.")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, settingsText(user, time.Now()))
+	msg.ReplyMarkup = b.settingsKeyboard()
+	if _, err := b.api.SendContext(ctx, msg); err != nil {
+		slog.Error("Failed to send settings", "chatID", chatID, "error", err)
+	}
+}
+
+// quietHoursPrompt is the text of the quiet hours menu.
+const quietHoursPrompt = "Select your quiet hours. Notifications due in them are delivered when they end.\n\n" +
+	"Send /quiet <from>-<to> for other hours, e.g. /quiet 23:30-07:30."
+
+// settingsText describes the settings of a user at the time.
+func settingsText(user *m.User, now time.Time) string {
+	loc := localtime.UserLocation(user)
+	quietHours := quietHoursText(user.QuietHoursStart, user.QuietHoursEnd)
+	return fmt.Sprintf("Settings:\n\nTime zone: %s (%s)\nQuiet hours: %s",
+		timeZoneLabel(loc.String()), localtime.FormatOffset(loc, now), quietHours)
+}
+
+// settingsKeyboard creates the keyboard of the settings menu.
+func (b *Bot) settingsKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonTimeZone, encodeCallback(actionTimeZone)),
+			tgbotapi.NewInlineKeyboardButtonData(buttonQuietHours, encodeCallback(actionQuietHours)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionMainMenu)),
+		),
+	)
+}
+
+// timeZoneKeyboard creates the keyboard with the time zones of Russia, two per row, and the "Back" button.
+func (b *Bot) timeZoneKeyboard(now time.Time) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(timeZones)/2+1)
+	for i, zone := range timeZones {
+		text := fmt.Sprintf("%s (%s)", zone.label, localtime.FormatOffset(localtime.Location(zone.name), now))
+		button := tgbotapi.NewInlineKeyboardButtonData(text, encodeCallback(actionTimeZone, zone.name))
+		if i%2 == 0 {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+		} else {
+			rows[len(rows)-1] = append(rows[len(rows)-1], button)
+		}
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionSettings)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// quietHoursKeyboard creates the keyboard with the quiet hours presets, the "Off" button and the "Back" button.
+func (b *Bot) quietHoursKeyboard() tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(quietHoursPresets)+2)
+	for _, preset := range quietHoursPresets {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			quietHoursText(preset[0], preset[1]), encodeCallback(actionQuietHours, encodeQuietHours(preset[0], preset[1])),
+		)))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonQuietHoursOff, encodeCallback(actionQuietHours, quietHoursOff)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonBack, encodeCallback(actionSettings)),
+		),
+	)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// validTimeZone reports whether the name is the IANA name of a time zone.
+func validTimeZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// timeZoneLabel returns the name of a time zone shown to the users.
+func timeZoneLabel(name string) string {
+	for _, zone := range timeZones {
+		if zone.name == name {
+			return zone.label
+		}
+	}
+	return name
+}
+
+// quietHoursText formats the quiet hours, e.g. 23:00–08:00.
+func quietHoursText(start, end int) string {
+	if start == end {
+		return "off"
+	}
+	return localtime.FormatClock(start) + "–" + localtime.FormatClock(end)
+}
+
+// encodeQuietHours encodes the quiet hours for callback data as "<start>-<end>" in minutes after midnight.
+func encodeQuietHours(start, end int) string {
+	return strconv.Itoa(start) + "-" + strconv.Itoa(end)
+}
+
+// decodeQuietHours decodes the quiet hours encoded by encodeQuietHours, or quietHoursOff.
+func decodeQuietHours(arg string) (int, int, bool) {
+	if arg == quietHoursOff {
+		return 0, 0, true
+	}
+	from, to, found := strings.Cut(arg, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.Atoi(from)
+	if err != nil || start < 0 || start >= localtime.MinutesPerDay {
+		return 0, 0, false
+	}
+	end, err := strconv.Atoi(to)
+	if err != nil || end < 0 || end >= localtime.MinutesPerDay {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// parseQuietHours parses the quiet hours written as "<from>-<to>", e.g. 23:00-08:00, or "off".
+func parseQuietHours(arg string) (int, int, bool) {
+	if strings.EqualFold(arg, quietHoursOff) {
+		return 0, 0, true
+	}
+	from, to, found := strings.Cut(strings.ReplaceAll(arg, "–", "-"), "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := localtime.ParseClock(from)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := localtime.ParseClock(to)
+	if err != nil || start == end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,252 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// Every subscription is shown as a card: a message with the subscription details
+// and the Pause/Resume, Edit, Delete, Delivery and Price Alerts buttons acting on that subscription.
+
+func (b *Bot) handleListSubscriptions(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+
+	user, ok := b.callbackUser(ctx, query)
+	if !ok {
+		return
+	}
+
+	// Retrieve the list of subscriptions for the user
+	subscriptions, err := b.subscriptionRepo.GetSubscriptionsByUserID(ctx, user.ID)
+	if err != nil {
+		slog.Error("Failed to retrieve subscriptions", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving subscriptions. Please try again later.")
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "")
+	if len(subscriptions) == 0 {
+		b.editMessage(ctx, query.Message, "You have no subscriptions yet.", b.backKeyboard())
+		return
+	}
+
+	b.editMessage(ctx, query.Message, fmt.Sprintf("Your subscriptions: %d", len(subscriptions)), b.backKeyboard())
+	for _, subscription := range subscriptions {
+		msg := tgbotapi.NewMessage(chatID, subscriptionCardText(subscription))
+		msg.ReplyMarkup = b.subscriptionCardKeyboard(subscription)
+		if _, err := b.api.SendContext(ctx, msg); err != nil {
+			slog.Error("Failed to send subscription card", "subscriptionID", subscription.ID, "error", err)
+		}
+	}
+}
+
+// handleSetSubscriptionStatus pauses or resumes a subscription and updates its card.
+func (b *Bot) handleSetSubscriptionStatus(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData, status string) {
+	subscription, ok := b.callbackSubscription(ctx, query, data)
+	if !ok {
+		return
+	}
+
+	if subscription.Status != status {
+		subscription.Status = status
+		if err := b.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+			slog.Error("Failed to update subscription", "subscriptionID", subscription.ID, "error", err)
+			b.answerCallback(ctx, query.ID, "Error updating the subscription. Please try again later.")
+			return
+		}
+	}
+
+	if status == m.SubscriptionStatusPaused {
+		b.answerCallback(ctx, query.ID, "Paused")
+	} else {
+		b.answerCallback(ctx, query.ID, "Resumed")
+	}
+	b.showSubscriptionCard(ctx, query.Message, subscription)
+}
+
+// handleEditSubscription starts the wizard for an existing subscription in place of its card.
+func (b *Bot) handleEditSubscription(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	subscription, ok := b.callbackSubscription(ctx, query, data)
+	if !ok {
+		return
+	}
+
+	cities, err := b.unitRepo.GetCities(ctx)
+	if err != nil {
+		slog.Error("Failed to retrieve cities", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving cities. Please try again later.")
+		return
+	}
+	if len(cities) == 0 {
+		b.answerCallback(ctx, query.ID, "No storages are known yet. Please try again later.")
+		return
+	}
+
+	state := &m.ChatState{ChatID: query.Message.Chat.ID, Step: m.ChatStepCity, SubscriptionID: subscription.ID}
+	if !b.saveChatState(ctx, query, state) {
+		return
+	}
+
+	text := "Editing the subscription:\n" + subscriptionCardText(subscription) + "\n\nSelect a city:"
+	b.answerCallback(ctx, query.ID, "")
+	b.editMessage(ctx, query.Message, text, b.citySelectionKeyboard(cities))
+}
+
+// handleDeleteSubscription deletes a subscription and offers to undo the deletion for a short time.
+func (b *Bot) handleDeleteSubscription(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	subscription, ok := b.callbackSubscription(ctx, query, data)
+	if !ok {
+		return
+	}
+
+	if err := b.subscriptionRepo.DeleteSubscription(ctx, subscription.ID); err != nil {
+		slog.Error("Failed to delete subscription", "subscriptionID", subscription.ID, "error", err)
+		b.answerCallback(ctx, query.ID, "Error deleting the subscription. Please try again later.")
+		return
+	}
+	b.undo.put(query.Message.Chat.ID, subscription)
+
+	text := "Subscription deleted:\n" + describeSubscription(subscription.City, subscription.Storage, subscription.UnitSize)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonUndo, encodeCallback(actionUndoDelete, formatID(subscription.ID))),
+		),
+	)
+	b.answerCallback(ctx, query.ID, "Deleted")
+	b.editMessage(ctx, query.Message, text, keyboard)
+}
+
+// handleUndoDelete restores a subscription deleted within the undo window.
+func (b *Bot) handleUndoDelete(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	id, err := strconv.ParseInt(data.arg(0), 10, 64)
+	if err != nil {
+		b.answerCallback(ctx, query.ID, "Unknown subscription.")
+		return
+	}
+
+	deleted, ok := b.undo.take(query.Message.Chat.ID, id)
+	if !ok {
+		b.answerCallback(ctx, query.ID, "It is too late to undo the deletion.")
+		b.editMessageText(ctx, query.Message, "Subscription deleted.")
+		return
+	}
+
+	restored := deleted
+	restored.ID = 0
+	restored.UpdatedAt = time.Now()
+	if err := b.subscriptionRepo.CreateSubscription(ctx, &restored); err != nil {
+		slog.Error("Failed to restore subscription", "subscriptionID", id, "error", err)
+		b.undo.put(query.Message.Chat.ID, &deleted)
+		b.answerCallback(ctx, query.ID, "Error restoring the subscription. Please try again later.")
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "Restored")
+	b.showSubscriptionCard(ctx, query.Message, &restored)
+}
+
+// callbackUser returns the user of the chat the query comes from.
+// Otherwise it answers the query and returns false.
+func (b *Bot) callbackUser(ctx context.Context, query *tgbotapi.CallbackQuery) (*m.User, bool) {
+	chatID := query.Message.Chat.ID
+
+	user, err := b.userRepo.GetByTelegramID(ctx, chatID)
+	if err != nil {
+		slog.Error("Failed to retrieve user", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving user. Please try again later.")
+		return nil, false
+	}
+	if user == nil {
+		slog.Error("User not found", "telegram_id", chatID)
+		b.answerCallback(ctx, query.ID, "User not found. Please start the bot again with /start.")
+		return nil, false
+	}
+	return user, true
+}
+
+// callbackSubscription returns the subscription the query refers to, if it belongs to the user of the chat.
+// Otherwise it answers the query and returns false.
+func (b *Bot) callbackSubscription(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) (*m.Subscription, bool) {
+	id, err := strconv.ParseInt(data.arg(0), 10, 64)
+	if err != nil {
+		b.answerCallback(ctx, query.ID, "Unknown subscription.")
+		return nil, false
+	}
+
+	user, ok := b.callbackUser(ctx, query)
+	if !ok {
+		return nil, false
+	}
+
+	subscription, err := b.subscriptionRepo.GetSubscriptionByID(ctx, id)
+	if err != nil {
+		slog.Error("Failed to retrieve subscription", "subscriptionID", id, "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving the subscription. Please try again later.")
+		return nil, false
+	}
+	if subscription == nil || subscription.UserID != user.ID {
+		b.answerCallback(ctx, query.ID, "This subscription no longer exists.")
+		b.editMessageText(ctx, query.Message, "This subscription no longer exists.")
+		return nil, false
+	}
+	return subscription, true
+}
+
+// showSubscriptionCard replaces the message with the card of the subscription.
+func (b *Bot) showSubscriptionCard(ctx context.Context, message *tgbotapi.Message, subscription *m.Subscription) {
+	b.editMessage(ctx, message, subscriptionCardText(subscription), b.subscriptionCardKeyboard(subscription))
+}
+
+// subscriptionCardKeyboard creates the action buttons of a subscription card.
+func (b *Bot) subscriptionCardKeyboard(subscription *m.Subscription) tgbotapi.InlineKeyboardMarkup {
+	id := formatID(subscription.ID)
+
+	toggle := tgbotapi.NewInlineKeyboardButtonData(buttonPause, encodeCallback(actionPause, id))
+	if subscription.Status != m.SubscriptionStatusActive {
+		toggle = tgbotapi.NewInlineKeyboardButtonData(buttonResume, encodeCallback(actionResume, id))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			toggle,
+			tgbotapi.NewInlineKeyboardButtonData(buttonEdit, encodeCallback(actionEdit, id)),
+			tgbotapi.NewInlineKeyboardButtonData(buttonDelete, encodeCallback(actionDelete, id)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(buttonDeliveryMode, encodeCallback(actionDeliveryMode, id)),
+			tgbotapi.NewInlineKeyboardButtonData(buttonPriceAlerts, encodeCallback(actionPriceAlerts, id)),
+		),
+	)
+}
+
+// subscriptionCardText formats the details of a subscription card.
+func subscriptionCardText(subscription *m.Subscription) string {
+	return describeSubscription(subscription.City, subscription.Storage, subscription.UnitSize) +
+		"\nStatus: " + subscriptionStatusText(subscription.Status) + "\nDelivery: " + deliveryModeText(subscription) +
+		"\nPrice alerts: " + priceRulesText(subscription)
+}
+
+// subscriptionStatusText describes the status of a subscription.
+func subscriptionStatusText(status string) string {
+	switch status {
+	case m.SubscriptionStatusActive:
+		return "Active"
+	case m.SubscriptionStatusPaused:
+		return "Paused"
+	case m.SubscriptionStatusSuspended:
+		return "Suspended while the bot could not reach you"
+	default:
+		return "Unknown"
+	}
+}
+
+// formatID formats an ID for callback data.
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,384 @@
+// Package telegramtest provides a fake Telegram Bot API server for tests.
+// The server records the requests of the bot and hands out the updates injected by the test,
+// so whole conversations can run offline.
+package telegramtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Token is the bot token the server accepts.
+const Token = "123456:test-token"
+
+// waitTimeout bounds the waiting for a request of the bot.
+const waitTimeout = 5 * time.Second
+
+// Request is a Bot API call made by the bot.
+type Request struct {
+	Method string
+	Params url.Values
+
+	messageID int // The message sent or edited by the call
+}
+
+// ChatID returns the chat the request is addressed to.
+func (r Request) ChatID() int64 {
+	id, _ := strconv.ParseInt(r.Params.Get("chat_id"), 10, 64)
+	return id
+}
+
+// MessageID returns the message sent or edited by the call.
+func (r Request) MessageID() int {
+	return r.messageID
+}
+
+// Text returns the text of the sent or edited message, or of the callback query answer.
+func (r Request) Text() string {
+	return r.Params.Get("text")
+}
+
+// Button returns the callback data of the inline keyboard button with the text.
+func (r Request) Button(text string) (string, bool) {
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(r.Params.Get("reply_markup")), &markup); err != nil {
+		return "", false
+	}
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.Text == text && button.CallbackData != nil {
+				return *button.CallbackData, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Server is a fake Telegram Bot API server.
+type Server struct {
+	server *httptest.Server
+
+	mu            sync.Mutex
+	requests      []Request
+	updates       []tgbotapi.Update
+	nextUpdateID  int
+	nextMessageID int
+	errors        map[string]Error
+	changed       chan struct{} // Closed and replaced when a request or an update arrives
+	closed        chan struct{}
+}
+
+// Error is a Bot API error the server answers a method with.
+type Error struct {
+	Code        int
+	Description string
+	RetryAfter  int
+	Times       int // Number of calls failing before the method works again, every call fails if zero
+}
+
+// NewServer starts a fake Bot API server, which is closed when the test finishes.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		errors:        make(map[string]Error),
+		changed:       make(chan struct{}),
+		closed:        make(chan struct{}),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Endpoint returns the API endpoint format of the server for tgbotapi.NewBotAPIWithClient.
+func (s *Server) Endpoint() string {
+	return s.server.URL + "/bot%s/%s"
+}
+
+// NewClient creates a Bot API client of the server.
+func (s *Server) NewClient(t *testing.T) *tgbotapi.BotAPI {
+	t.Helper()
+
+	client, err := tgbotapi.NewBotAPIWithClient(Token, s.Endpoint(), s.server.Client())
+	if err != nil {
+		t.Fatalf("failed to create the Bot API client: %s", err)
+	}
+	return client
+}
+
+// Close stops the server, the pending long polling requests return first.
+func (s *Server) Close() {
+	s.mu.Lock()
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+	s.mu.Unlock()
+
+	s.server.Close()
+}
+
+// FailMethod makes the server answer every call of the method with the error.
+func (s *Server) FailMethod(method string, apiErr Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors[method] = apiErr
+}
+
+// SendMessage injects a text message of the user. A text starting with a slash is a command.
+func (s *Server) SendMessage(chatID int64, text string) {
+	message := &tgbotapi.Message{
+		From: &tgbotapi.User{ID: chatID, FirstName: "Test"},
+		Chat: &tgbotapi.Chat{ID: chatID, Type: "private", FirstName: "Test"},
+		Date: int(time.Now().Unix()),
+		Text: text,
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+
+	s.inject(func(update *tgbotapi.Update, messageID int) {
+		message.MessageID = messageID
+		update.Message = message
+	})
+}
+
+// PressButton injects a press of the inline keyboard button with the callback data on a message of the bot.
+func (s *Server) PressButton(chatID int64, messageID int, data string) {
+	s.inject(func(update *tgbotapi.Update, _ int) {
+		update.CallbackQuery = &tgbotapi.CallbackQuery{
+			ID:   "query-" + strconv.Itoa(update.UpdateID),
+			From: &tgbotapi.User{ID: chatID, FirstName: "Test"},
+			Message: &tgbotapi.Message{
+				MessageID: messageID,
+				Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+			},
+			Data: data,
+		}
+	})
+}
+
+// Press injects a press of the inline keyboard button with the text on the message sent or edited by the call.
+// The test fails if the message has no such button.
+func (s *Server) Press(t *testing.T, r Request, text string) {
+	t.Helper()
+
+	data, ok := r.Button(text)
+	if !ok {
+		t.Fatalf("the message %q has no %q button", r.Text(), text)
+	}
+	s.PressButton(r.ChatID(), r.MessageID(), data)
+}
+
+// inject queues an update for the bot.
+func (s *Server) inject(fill func(update *tgbotapi.Update, messageID int)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	update := tgbotapi.Update{UpdateID: s.nextUpdateID}
+	s.nextUpdateID++
+	fill(&update, s.nextMessageID)
+	s.nextMessageID++
+
+	s.updates = append(s.updates, update)
+	s.notifyLocked()
+}
+
+// Requests returns the calls of the method made so far, all calls for an empty method.
+func (s *Server) Requests(method string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []Request
+	for _, request := range s.requests {
+		if method == "" || request.Method == method {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+// WaitFor returns the first call of the method matching the predicate, waiting for it if none was made yet.
+// The test fails if no such call arrives in time.
+func (s *Server) WaitFor(t *testing.T, method string, match func(r Request) bool) Request {
+	t.Helper()
+
+	deadline := time.After(waitTimeout)
+	seen := 0
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		requests := s.requests
+		s.mu.Unlock()
+
+		for ; seen < len(requests); seen++ {
+			if request := requests[seen]; request.Method == method && (match == nil || match(request)) {
+				return request
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("no %s request arrived in time, got %v", method, s.Requests(""))
+			return Request{}
+		}
+	}
+}
+
+// WaitForText waits for a call of the method with a text containing the substring.
+func (s *Server) WaitForText(t *testing.T, method, substring string) Request {
+	t.Helper()
+
+	return s.WaitFor(t, method, func(r Request) bool { return strings.Contains(r.Text(), substring) })
+}
+
+// notifyLocked wakes up the waiting requests and tests. The mutex must be held.
+func (s *Server) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// handle serves a Bot API call of the form /bot<token>/<method>.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	prefix := "/bot" + Token + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, Error{Code: http.StatusUnauthorized, Description: "Unauthorized"})
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, prefix)
+	if err := r.ParseForm(); err != nil {
+		writeError(w, Error{Code: http.StatusBadRequest, Description: err.Error()})
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, tgbotapi.User{ID: 123456, IsBot: true, FirstName: "Test Bot", UserName: "test_bot"})
+	case "getUpdates":
+		s.handleGetUpdates(w, r)
+	default:
+		s.handleCall(w, method, r.PostForm)
+	}
+}
+
+// handleGetUpdates answers a long polling request with the queued updates past the offset.
+func (s *Server) handleGetUpdates(w http.ResponseWriter, r *http.Request) {
+	offset, _ := strconv.Atoi(r.PostForm.Get("offset"))
+	timeout, _ := strconv.Atoi(r.PostForm.Get("timeout"))
+	deadline := time.After(time.Duration(timeout) * time.Second)
+
+	for {
+		s.mu.Lock()
+		var updates []tgbotapi.Update
+		for _, update := range s.updates {
+			if update.UpdateID >= offset {
+				updates = append(updates, update)
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			writeResult(w, updates)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			writeResult(w, []tgbotapi.Update{})
+			return
+		case <-s.closed:
+			writeResult(w, []tgbotapi.Update{})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleCall records a call of the bot and answers it like Telegram does.
+func (s *Server) handleCall(w http.ResponseWriter, method string, params url.Values) {
+	request := Request{Method: method, Params: params}
+	if id := params.Get("message_id"); id != "" {
+		request.messageID, _ = strconv.Atoi(id)
+	}
+
+	s.mu.Lock()
+	apiErr, failing := s.errors[method]
+	if failing && apiErr.Times > 0 {
+		apiErr.Times--
+		s.errors[method] = apiErr
+		if apiErr.Times == 0 {
+			delete(s.errors, method)
+		}
+	}
+	if method == "sendMessage" && !failing {
+		request.messageID = s.nextMessageID
+		s.nextMessageID++
+	}
+	s.requests = append(s.requests, request)
+	s.notifyLocked()
+	s.mu.Unlock()
+
+	if failing {
+		writeError(w, apiErr)
+		return
+	}
+
+	switch method {
+	case "sendMessage", "editMessageText", "editMessageReplyMarkup":
+		writeResult(w, tgbotapi.Message{
+			MessageID: request.messageID,
+			Chat:      &tgbotapi.Chat{ID: request.ChatID(), Type: "private"},
+			Date:      int(time.Now().Unix()),
+			Text:      params.Get("text"),
+		})
+	default:
+		writeResult(w, true)
+	}
+}
+
+// writeResult writes a successful Bot API response.
+func writeResult(w http.ResponseWriter, result any) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, Error{Code: http.StatusInternalServerError, Description: err.Error()})
+		return
+	}
+	writeJSON(w, tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+// writeError writes a failed Bot API response.
+func writeError(w http.ResponseWriter, apiErr Error) {
+	response := tgbotapi.APIResponse{Ok: false, ErrorCode: apiErr.Code, Description: apiErr.Description}
+	if apiErr.RetryAfter > 0 {
+		response.Parameters = &tgbotapi.ResponseParameters{RetryAfter: apiErr.RetryAfter}
+	}
+	writeJSON(w, response)
+}
+
+// writeJSON writes the response. Telegram answers errors with the HTTP status of the error code,
+// the client only looks at the body.
+func writeJSON(w http.ResponseWriter, response tgbotapi.APIResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Ok && response.ErrorCode != 0 {
+		w.WriteHeader(response.ErrorCode)
+	}
+	_ = json.NewEncoder(w).Encode(response)
+}
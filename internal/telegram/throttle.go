@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// verificationInterval is how long a user, and an email address, waits between two confirmation emails.
+const verificationInterval = 5 * time.Minute
+
+// verificationThrottle limits the confirmation emails sent for a user and to an address,
+// so the bot cannot be made to flood a mailbox or burn the sending quota.
+// It lives in memory, the limits start over after a restart.
+type verificationThrottle struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	now       func() time.Time
+	users     map[int64]time.Time  // When the next email may be sent for the user
+	addresses map[string]time.Time // When the next email may be sent to the address
+}
+
+// newVerificationThrottle creates a verificationThrottle allowing one email per interval.
+func newVerificationThrottle(interval time.Duration) *verificationThrottle {
+	return &verificationThrottle{
+		interval:  interval,
+		now:       time.Now,
+		users:     make(map[int64]time.Time),
+		addresses: make(map[string]time.Time),
+	}
+}
+
+// reserve takes the slot of an email for the user to the address, dropping the slots whose interval has passed.
+// It returns false with the time left to wait if an email was sent for the user or to the address recently.
+func (t *verificationThrottle) reserve(userID int64, address string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for id, next := range t.users {
+		if !now.Before(next) {
+			delete(t.users, id)
+		}
+	}
+	for addr, next := range t.addresses {
+		if !now.Before(next) {
+			delete(t.addresses, addr)
+		}
+	}
+
+	address = strings.ToLower(address)
+	next := t.users[userID]
+	if t.addresses[address].After(next) {
+		next = t.addresses[address]
+	}
+	if now.Before(next) {
+		return next.Sub(now), false
+	}
+
+	t.users[userID] = now.Add(t.interval)
+	t.addresses[address] = now.Add(t.interval)
+	return 0, true
+}
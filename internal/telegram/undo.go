@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"sync"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// undoWindow is how long a deleted subscription can be restored.
+const undoWindow = 2 * time.Minute
+
+// deletedSubscription is a deleted subscription that can still be restored.
+type deletedSubscription struct {
+	subscription m.Subscription
+	chatID       int64
+	expiresAt    time.Time
+}
+
+// undoStore keeps recently deleted subscriptions, so their deletion can be undone for a short time.
+// It lives in memory, a deletion cannot be undone after a restart.
+type undoStore struct {
+	mu      sync.Mutex
+	window  time.Duration
+	now     func() time.Time
+	deleted map[int64]deletedSubscription
+}
+
+// newUndoStore creates an undoStore keeping deleted subscriptions for the given window.
+func newUndoStore(window time.Duration) *undoStore {
+	return &undoStore{
+		window:  window,
+		now:     time.Now,
+		deleted: make(map[int64]deletedSubscription),
+	}
+}
+
+// put remembers a subscription deleted from the chat, dropping the ones whose window has passed.
+func (s *undoStore) put(chatID int64, subscription *m.Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, entry := range s.deleted {
+		if now.After(entry.expiresAt) {
+			delete(s.deleted, id)
+		}
+	}
+
+	s.deleted[subscription.ID] = deletedSubscription{
+		subscription: *subscription,
+		chatID:       chatID,
+		expiresAt:    now.Add(s.window),
+	}
+}
+
+// take returns and forgets the subscription deleted from the chat.
+// It returns false if the subscription is unknown, was deleted in another chat or its window has passed.
+func (s *undoStore) take(chatID, subscriptionID int64) (m.Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.deleted[subscriptionID]
+	if !exists || entry.chatID != chatID {
+		return m.Subscription{}, false
+	}
+	delete(s.deleted, subscriptionID)
+
+	if s.now().After(entry.expiresAt) {
+		return m.Subscription{}, false
+	}
+	return entry.subscription, true
+}
@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// deactivateUser marks the user of a chat the bot cannot reach any more, who blocked the bot or deleted their account,
+// as inactive and suspends their active subscriptions, so no more notifications are sent to them.
+func (b *Bot) deactivateUser(ctx context.Context, chatID int64) {
+	user, err := b.userRepo.GetByTelegramID(ctx, chatID)
+	if err != nil {
+		slog.Error("Failed to retrieve user", "chatID", chatID, "error", err)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	if user.Active {
+		user.Active = false
+		user.UpdatedAt = time.Now()
+		if err := b.userRepo.UpdateUser(ctx, user); err != nil {
+			slog.Error("Failed to deactivate user", "userID", user.ID, "error", err)
+			return
+		}
+		slog.Info("User cannot be reached, deactivated", "userID", user.ID, "chatID", chatID)
+	}
+
+	// Also when the user was deactivated before, in case suspending failed then
+	b.setSubscriptionsStatus(ctx, user.ID, m.SubscriptionStatusActive, m.SubscriptionStatusSuspended)
+}
+
+// reactivateUser marks a user who came back with /start as active and resumes their suspended subscriptions.
+func (b *Bot) reactivateUser(ctx context.Context, user *m.User) {
+	user.Active = true
+	user.UpdatedAt = time.Now()
+	if err := b.userRepo.UpdateUser(ctx, user); err != nil {
+		slog.Error("Failed to reactivate user", "userID", user.ID, "error", err)
+		return
+	}
+	slog.Info("User is back, reactivated", "userID", user.ID)
+
+	b.setSubscriptionsStatus(ctx, user.ID, m.SubscriptionStatusSuspended, m.SubscriptionStatusActive)
+}
+
+// setSubscriptionsStatus moves the subscriptions of the user from one status to another.
+func (b *Bot) setSubscriptionsStatus(ctx context.Context, userID int64, from, to string) {
+	subscriptions, err := b.subscriptionRepo.GetSubscriptionsByUserID(ctx, userID)
+	if err != nil {
+		slog.Error("Failed to retrieve subscriptions", "userID", userID, "error", err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.Status != from {
+			continue
+		}
+		subscription.Status = to
+		subscription.UpdatedAt = time.Now()
+		if err := b.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+			slog.Error("Failed to update subscription", "subscriptionID", subscription.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+)
+
+// The subscription wizard walks a chat through city → storage → unit size → confirmation.
+// The progress is kept in the chat state, so a button of an outdated step is rejected.
+// The same wizard edits an existing subscription when the chat state refers to one.
+
+func (b *Bot) handleNewSubscription(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+
+	// Query available cities from the database
+	cities, err := b.unitRepo.GetCities(ctx)
+	if err != nil {
+		slog.Error("Failed to retrieve cities", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving cities. Please try again later.")
+		return
+	}
+	if len(cities) == 0 {
+		b.answerCallback(ctx, query.ID, "No storages are known yet. Please try again later.")
+		return
+	}
+
+	// Start the wizard from the city selection
+	if !b.saveChatState(ctx, query, &m.ChatState{ChatID: chatID, Step: m.ChatStepCity}) {
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "")
+	b.editMessage(ctx, query.Message, "Select a city:", b.citySelectionKeyboard(cities))
+}
+
+func (b *Bot) handleCitySelection(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	state, ok := b.wizardState(ctx, query, m.ChatStepCity)
+	if !ok {
+		return
+	}
+
+	cities, err := b.unitRepo.GetCities(ctx)
+	if err != nil {
+		slog.Error("Failed to retrieve cities", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving cities. Please try again later.")
+		return
+	}
+	city, found := findOption(cities, data.arg(0))
+	if !found {
+		b.answerCallback(ctx, query.ID, "This city is no longer available.")
+		return
+	}
+
+	// Retrieve storages based on the selected city
+	storages, err := b.unitRepo.GetStoragesByCity(ctx, city)
+	if err != nil {
+		slog.Error("Failed to retrieve storages", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving storages. Please try again later.")
+		return
+	}
+
+	state.City = city
+	state.Step = m.ChatStepStorage
+	if !b.saveChatState(ctx, query, state) {
+		return
+	}
+
+	b.answerCallback(ctx, query.ID, "")
+	b.editMessage(ctx, query.Message, fmt.Sprintf("City: %s\n\nSelect a storage:", city),
+		b.storageSelectionKeyboard(storages))
+}
+
+func (b *Bot) handleStorageSelection(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	state, ok := b.wizardState(ctx, query, m.ChatStepStorage)
+	if !ok {
+		return
+	}
+
+	// Make sure the storage belongs to the selected city
+	storages, err := b.unitRepo.GetStoragesByCity(ctx, state.City)
+	if err != nil {
+		slog.Error("Failed to retrieve storages", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving storages. Please try again later.")
+		return
+	}
+	storage, found := findStorage(storages, data.arg(0))
+	if !found {
+		b.answerCallback(ctx, query.ID, "This storage is no longer available.")
+		return
+	}
+
+	// Retrieve unit sizes based on the selected storage
+	unitSizes, err := b.unitRepo.GetUnitSizesByStorage(ctx, storage, state.City)
+	if err != nil {
+		slog.Error("Failed to retrieve unit sizes", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving unit sizes. Please try again later.")
+		return
+	}
+
+	// The subscription is limited to the provider of the storage
+	state.Provider = storage.Provider
+	state.Storage = storage.Name
+	state.Step = m.ChatStepUnitSize
+	if !b.saveChatState(ctx, query, state) {
+		return
+	}
+
+	text := fmt.Sprintf("City: %s\nStorage: %s\n\nSelect a unit size:", state.City, storage.Name)
+	b.answerCallback(ctx, query.ID, "")
+	b.editMessage(ctx, query.Message, text, b.unitSizeSelectionKeyboard(unitSizes))
+}
+
+func (b *Bot) handleUnitSizeSelection(ctx context.Context, query *tgbotapi.CallbackQuery, data callbackData) {
+	state, ok := b.wizardState(ctx, query, m.ChatStepUnitSize)
+	if !ok {
+		return
+	}
+
+	// Make sure the unit size exists in the selected storage
+	storage := m.Storage{Provider: state.Provider, Name: state.Storage}
+	unitSizes, err := b.unitRepo.GetUnitSizesByStorage(ctx, storage, state.City)
+	if err != nil {
+		slog.Error("Failed to retrieve unit sizes", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving unit sizes. Please try again later.")
+		return
+	}
+	unitSize, found := findOption(unitSizes, data.arg(0))
+	if !found {
+		b.answerCallback(ctx, query.ID, "This unit size is no longer available.")
+		return
+	}
+
+	state.UnitSize = unitSize
+	state.Step = m.ChatStepConfirm
+	if !b.saveChatState(ctx, query, state) {
+		return
+	}
+
+	// Ask for the confirmation
+	text := "Subscribe to the units in:\n" + describeSubscription(state.City, state.Storage, state.UnitSize)
+	b.answerCallback(ctx, query.ID, "")
+	b.editMessage(ctx, query.Message, text, b.confirmationKeyboard())
+}
+
+func (b *Bot) handleConfirmation(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	state, ok := b.wizardState(ctx, query, m.ChatStepConfirm)
+	if !ok {
+		return
+	}
+
+	user, err := b.userRepo.GetByTelegramID(ctx, state.ChatID)
+	if err != nil {
+		slog.Error("Failed to retrieve user", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving user. Please try again later.")
+		return
+	}
+	if user == nil {
+		slog.Error("User not found", "telegram_id", state.ChatID)
+		b.answerCallback(ctx, query.ID, "User not found. Please start the bot again with /start.")
+		return
+	}
+
+	if state.SubscriptionID != 0 {
+		b.updateSubscription(ctx, query, state, user)
+		return
+	}
+
+	// Save the subscription details
+	subscription := &m.Subscription{
+		UserID:        user.ID,
+		Provider:      state.Provider,
+		City:          state.City,
+		Storage:       state.Storage,
+		UnitSize:      state.UnitSize,
+		Status:        m.SubscriptionStatusActive,
+		DeliveryMode:  m.DeliveryModeInstant,
+		DigestTime:    defaultDigestTime,
+		DigestWeekday: time.Monday,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if err := b.subscriptionRepo.CreateSubscription(ctx, subscription); err != nil {
+		slog.Error("Failed to create subscription", "error", err)
+		b.answerCallback(ctx, query.ID, "Error saving the subscription. Please try again later.")
+		return
+	}
+
+	// The wizard is finished
+	if err := b.chatStateRepo.DeleteChatState(ctx, state.ChatID); err != nil {
+		slog.Error("Failed to delete chat state", "error", err)
+	}
+
+	// Confirm the subscription
+	b.answerCallback(ctx, query.ID, "Subscribed!")
+	text := "You have been subscribed to the units in:\n" + describeSubscription(state.City, state.Storage, state.UnitSize)
+	b.showMainMenu(ctx, query.Message, text)
+}
+
+// updateSubscription applies the criteria selected in the wizard to the edited subscription.
+func (b *Bot) updateSubscription(ctx context.Context, query *tgbotapi.CallbackQuery, state *m.ChatState, user *m.User) {
+	subscription, err := b.subscriptionRepo.GetSubscriptionByID(ctx, state.SubscriptionID)
+	if err != nil {
+		slog.Error("Failed to retrieve subscription", "subscriptionID", state.SubscriptionID, "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving the subscription. Please try again later.")
+		return
+	}
+
+	if subscription == nil || subscription.UserID != user.ID {
+		b.answerCallback(ctx, query.ID, "This subscription no longer exists.")
+		b.editMessageText(ctx, query.Message, "This subscription no longer exists.")
+	} else {
+		subscription.Provider = state.Provider
+		subscription.City = state.City
+		subscription.Storage = state.Storage
+		subscription.UnitSize = state.UnitSize
+		if err := b.subscriptionRepo.UpdateSubscription(ctx, subscription); err != nil {
+			slog.Error("Failed to update subscription", "subscriptionID", subscription.ID, "error", err)
+			b.answerCallback(ctx, query.ID, "Error saving the subscription. Please try again later.")
+			return
+		}
+		b.answerCallback(ctx, query.ID, "Saved")
+		b.showSubscriptionCard(ctx, query.Message, subscription)
+	}
+
+	// The wizard is finished
+	if err := b.chatStateRepo.DeleteChatState(ctx, state.ChatID); err != nil {
+		slog.Error("Failed to delete chat state", "error", err)
+	}
+}
+
+func (b *Bot) handleCancel(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	chatID := query.Message.Chat.ID
+
+	state, err := b.chatStateRepo.GetChatState(ctx, chatID)
+	if err != nil {
+		slog.Error("Failed to retrieve chat state", "error", err)
+	}
+
+	// Leave the wizard if the chat is in it
+	if err := b.chatStateRepo.DeleteChatState(ctx, chatID); err != nil {
+		slog.Error("Failed to delete chat state", "error", err)
+	}
+
+	// Cancelling an edit brings the card of the subscription back
+	if state != nil && state.SubscriptionID != 0 {
+		subscription, err := b.subscriptionRepo.GetSubscriptionByID(ctx, state.SubscriptionID)
+		if err != nil {
+			slog.Error("Failed to retrieve subscription", "subscriptionID", state.SubscriptionID, "error", err)
+		}
+		if subscription != nil {
+			b.answerCallback(ctx, query.ID, "")
+			b.showSubscriptionCard(ctx, query.Message, subscription)
+			return
+		}
+	}
+
+	b.answerCallback(ctx, query.ID, "")
+	b.showMainMenu(ctx, query.Message, "What would you like to do?")
+}
+
+// wizardState returns the chat state if the chat is at the expected wizard step.
+// Otherwise it answers the query and returns false, e.g. for a button of an outdated message.
+func (b *Bot) wizardState(ctx context.Context, query *tgbotapi.CallbackQuery, step m.ChatStep) (*m.ChatState, bool) {
+	state, err := b.chatStateRepo.GetChatState(ctx, query.Message.Chat.ID)
+	if err != nil {
+		slog.Error("Failed to retrieve chat state", "error", err)
+		b.answerCallback(ctx, query.ID, "Error retrieving your progress. Please try again later.")
+		return nil, false
+	}
+	if state == nil || state.Step != step {
+		b.answerCallback(ctx, query.ID, "This menu is outdated.")
+		return nil, false
+	}
+	return state, true
+}
+
+// saveChatState persists the wizard progress of a chat and reports the failure to the user.
+func (b *Bot) saveChatState(ctx context.Context, query *tgbotapi.CallbackQuery, state *m.ChatState) bool {
+	if err := b.chatStateRepo.SaveChatState(ctx, state); err != nil {
+		slog.Error("Failed to save chat state", "error", err)
+		b.answerCallback(ctx, query.ID, "Error saving your progress. Please try again later.")
+		return false
+	}
+	return true
+}
+
+// describeSubscription formats the criteria of a subscription.
+func describeSubscription(city, storage, unitSize string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "City: %s\n", city)
+	fmt.Fprintf(&sb, "Storage: %s\n", storage)
+	fmt.Fprintf(&sb, "Unit: %s", unitSize)
+	return sb.String()
+}
@@ -7,6 +7,15 @@ import (
 	"path/filepath"
 )
 
+// EnsureParentDir creates the directory of the file at path if it does not exist.
+func EnsureParentDir(path string) error {
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return os.MkdirAll(dir, 0755)
+	}
+	return nil
+}
+
 // OpenLogFile opens the log file and returns an io.Writer for logging.
 func OpenLogFile(logFilePath string) (io.Writer, func() error, error) {
 	// If no log file path is provided, return stdout
@@ -1 +1,106 @@
 package integration
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/movax01h/kladovkin-telegram-bot/config"
+	m "github.com/movax01h/kladovkin-telegram-bot/internal/models"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/database"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/repository/repositorytest"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/telegram"
+	"github.com/movax01h/kladovkin-telegram-bot/internal/telegram/telegramtest"
+)
+
+// openDatabase opens a migrated SQLite database in a temporary directory.
+func openDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db, err := database.Open(&config.DatabaseConfig{
+		Driver: config.DriverSQLite,
+		Path:   filepath.Join(t.TempDir(), "data", "bot.db"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Migrate())
+	return db
+}
+
+// startBot runs the bot against the fake Bot API server until the test finishes.
+func startBot(t *testing.T, db *database.Database, server *telegramtest.Server) {
+	t.Helper()
+
+	cfg := config.TelegramConfig{BotToken: telegramtest.Token, APIEndpoint: server.Endpoint()}
+	client, err := telegram.NewClient(cfg)
+	require.NoError(t, err)
+	repos := db.Repositories
+	bot := telegram.NewBot(
+		cfg, client, repos.Users, repos.Units, repos.Subscriptions, repos.ChatStates, repos.DeliveryChannels,
+		repos.JobStates,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bot.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// TestSubscriptionWizard walks a new user from /start through the wizard and checks the stored subscription.
+func TestSubscriptionWizard(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	const chatID = 100
+	db := openDatabase(t)
+	units := []m.Unit{
+		repositorytest.NewUnit("1", "Москва", "Ленинский", "2 м²"),
+		repositorytest.NewUnit("2", "Москва", "Ленинский", "4 м²"),
+		repositorytest.NewUnit("3", "Санкт-Петербург", "Обводный", "2 м²"),
+	}
+	require.NoError(t, db.Repositories.Units.UpsertUnits(ctx, units))
+	server := telegramtest.NewServer(t)
+	startBot(t, db, server)
+
+	// Act
+	server.SendMessage(chatID, "/start")
+	menu := server.WaitForText(t, "sendMessage", "What would you like to do?")
+	server.Press(t, menu, "New Subscription")
+	cities := server.WaitForText(t, "editMessageText", "Select a city")
+	server.Press(t, cities, "Москва")
+	storages := server.WaitForText(t, "editMessageText", "Select a storage")
+	server.Press(t, storages, "Ленинский")
+	sizes := server.WaitForText(t, "editMessageText", "Select a unit size")
+	server.Press(t, sizes, "2 м²")
+	confirmation := server.WaitForText(t, "editMessageText", "Subscribe to the units in")
+	server.Press(t, confirmation, "Confirm")
+
+	// Assert
+	done := server.WaitForText(t, "editMessageText", "You have been subscribed")
+	assert.Equal(t, menu.MessageID(), done.MessageID())
+
+	user, err := db.Repositories.Users.GetByTelegramID(ctx, chatID)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+
+	subscriptions, err := db.Repositories.Subscriptions.GetSubscriptionsByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+	assert.Equal(t, "kladovkin", subscriptions[0].Provider, "the provider is taken from the storage")
+	assert.Equal(t, "Москва", subscriptions[0].City)
+	assert.Equal(t, "Ленинский", subscriptions[0].Storage)
+	assert.Equal(t, "2 м²", subscriptions[0].UnitSize)
+	assert.Equal(t, m.SubscriptionStatusActive, subscriptions[0].Status)
+
+	state, err := db.Repositories.ChatStates.GetChatState(ctx, chatID)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}